@@ -8,7 +8,12 @@ import (
 	"crypto/sha512"
 	"encoding/base64"
 	"encoding/hex"
+	"fmt"
+	"hash"
 	"hash/crc32"
+	"io"
+	"os"
+	"strings"
 )
 
 // MD5 computes the MD5 hash and returns hex-encoded string
@@ -92,3 +97,45 @@ func SignSHA512(data, key string) string {
 	mac.Write([]byte(data))
 	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
 }
+
+// NewHash returns a streaming hash for md5, sha1, sha256, sha512 or crc32
+// 按算法名称（md5、sha1、sha256、sha512、crc32）创建流式哈希
+func NewHash(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "crc32":
+		return crc32.NewIEEE(), nil
+	}
+	return nil, fmt.Errorf("ahash: unsupported algorithm %q", algorithm)
+}
+
+// Reader hashes everything read from r and returns hex-encoded string
+// 流式计算 Reader 内容的哈希，返回十六进制编码字符串
+func Reader(algorithm string, r io.Reader) (string, error) {
+	h, err := NewHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// File hashes a file without loading it into memory and returns hex-encoded string
+// 流式计算文件哈希（不整体读入内存），返回十六进制编码字符串
+func File(algorithm, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return Reader(algorithm, f)
+}
@@ -21,6 +21,7 @@ var (
 	ErrUnexpectedSigningMethod = errors.New("unexpected signing method") // 未知的签名算法 / Unexpected signing method error
 	ErrKeyNotSet               = errors.New("key not set")               // 未设置密钥 / Key not set error
 	ErrKeyConfiguration        = errors.New("key configuration error")   // 密钥配置错误 / Key configuration error
+	ErrTokenExpired            = errors.New("expired")                   // Token已过期 / Token expired error
 )
 
 // JwtManager JWT 管理器结构体 / JwtManager manages JWT operations.
@@ -212,7 +213,7 @@ func wrapJWTError(err error) error {
 	case errors.Is(err, jwt.ErrTokenMalformed):
 		return fmt.Errorf("%w: malformed", ErrInvalidToken)
 	case errors.Is(err, jwt.ErrTokenExpired):
-		return fmt.Errorf("%w: expired", ErrInvalidToken)
+		return fmt.Errorf("%w: %w", ErrInvalidToken, ErrTokenExpired)
 	case errors.Is(err, jwt.ErrTokenNotValidYet):
 		return fmt.Errorf("%w: not active yet", ErrInvalidToken)
 	default:
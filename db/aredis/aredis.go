@@ -129,6 +129,16 @@ func (c *ClientRedis) Close() {
 	}
 }
 
+// Universal 获取底层客户端，用于需要错误信息或管道的场景
+// Universal returns the underlying client for callers that need errors or pipelines
+func (c *ClientRedis) Universal() redis.UniversalClient {
+	// 哨兵模式 Mode 为 false 但使用 Clients / Sentinel mode keeps Mode false yet uses Clients
+	if c.Mode || c.ClusterClient == nil {
+		return c.Clients
+	}
+	return c.ClusterClient
+}
+
 // Ping <心跳>
 func (c *ClientRedis) Ping() string {
 	var pong string
@@ -0,0 +1,34 @@
+package aredis
+
+// HSet 设置哈希表字段，values 为字段与值交替出现的列表
+// HSet sets hash fields; values alternate between field and value
+func (c *ClientRedis) HSet(key string, values ...interface{}) error {
+	if c.Mode {
+		return c.Clients.HSet(c.Ctx, key, values...).Err()
+	}
+	return c.ClusterClient.HSet(c.Ctx, key, values...).Err()
+}
+
+// HGetAll 获取哈希表的全部字段 / HGetAll returns every field of a hash
+func (c *ClientRedis) HGetAll(key string) (map[string]string, error) {
+	if c.Mode {
+		return c.Clients.HGetAll(c.Ctx, key).Result()
+	}
+	return c.ClusterClient.HGetAll(c.Ctx, key).Result()
+}
+
+// HDel 删除哈希表字段 / HDel removes hash fields
+func (c *ClientRedis) HDel(key string, fields ...string) (int64, error) {
+	if c.Mode {
+		return c.Clients.HDel(c.Ctx, key, fields...).Result()
+	}
+	return c.ClusterClient.HDel(c.Ctx, key, fields...).Result()
+}
+
+// HLen 获取哈希表的字段数量 / HLen returns the number of fields in a hash
+func (c *ClientRedis) HLen(key string) (int64, error) {
+	if c.Mode {
+		return c.Clients.HLen(c.Ctx, key).Result()
+	}
+	return c.ClusterClient.HLen(c.Ctx, key).Result()
+}
@@ -0,0 +1,9 @@
+package aredis
+
+import "github.com/redis/go-redis/v9"
+
+// Pipelined 在一个管道中批量执行 fn 中排队的命令，减少网络往返，返回各命令结果与第一个错误
+// Pipelined runs the commands queued by fn in one pipeline to save round trips, returning every result and the first error
+func (c *ClientRedis) Pipelined(fn func(pipe redis.Pipeliner) error) ([]redis.Cmder, error) {
+	return c.Universal().Pipelined(c.Ctx, fn)
+}
@@ -0,0 +1,24 @@
+package aredis
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Publish 发布消息到频道 / Publish sends a message to a channel
+func (c *ClientRedis) Publish(channel string, message interface{}) error {
+	if c.Mode {
+		return c.Clients.Publish(c.Ctx, channel, message).Err()
+	}
+	return c.ClusterClient.Publish(c.Ctx, channel, message).Err()
+}
+
+// Subscribe 订阅频道，使用完毕后需调用 Close
+// Subscribe subscribes to channels; call Close on the result when done
+func (c *ClientRedis) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	if c.Mode {
+		return c.Clients.Subscribe(ctx, channels...)
+	}
+	return c.ClusterClient.Subscribe(ctx, channels...)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/small-ek/antgo/frame/ant"
 	_ "github.com/small-ek/antgo/frame/serve/gin"
 	"github.com/small-ek/antgo/i18n"
+	"github.com/small-ek/antgo/net/httpx/gateway"
 	"github.com/small-ek/antgo/net/httpx/middleware/agin"
 	"github.com/small-ek/antgo/os/config"
 	"io/ioutil"
@@ -28,8 +29,12 @@ func LoadSrv() {
 	}).Serve(load())
 
 	defer eng.Close()
+	defer gateway.CloseAll(proxies...)
 }
 
+// proxies 挂载的网关代理，服务停止时关闭
+var proxies []*gateway.Proxy
+
 func load() *gin.Engine {
 	var app = gin.New()
 	//开发者模式
@@ -39,6 +44,12 @@ func load() *gin.Engine {
 	}
 	app.Use(agin.Recovery()).Use(agin.Logger()).Use(i18n.Middleware())
 
+	//挂载 gateway.routes 中的代理路由
+	var err error
+	if proxies, err = gateway.Mount(app); err != nil {
+		panic(err)
+	}
+
 	app.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "Hello World!",
@@ -186,5 +186,7 @@ func loadApp() {
 		// 初始化数据库连接.
 		initRedis() // Initialize Redis if configured.
 		// 如果配置了 Redis，则初始化 Redis.
+		initHttpClients() // Initialize named HTTP clients.
+		// 初始化命名 HTTP 客户端.
 	}
 }
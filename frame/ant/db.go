@@ -1,6 +1,7 @@
 package ant
 
 import (
+	"context"
 	"github.com/small-ek/antgo/db/adb"
 	"github.com/small-ek/antgo/os/config"
 	"gorm.io/gorm"
@@ -24,6 +25,11 @@ func Db(name ...string) *gorm.DB {
 	return adb.Master[key]
 }
 
+// DbWithContext Get database connection bound to ctx, so request deadlines cancel queries
+func DbWithContext(ctx context.Context, name ...string) *gorm.DB {
+	return Db(name...).WithContext(ctx)
+}
+
 // CloseDb Close database connection
 func CloseDb() {
 	adb.Close()
@@ -0,0 +1,44 @@
+package ant
+
+import (
+	"fmt"
+
+	"github.com/small-ek/antgo/net/ahttp"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"github.com/small-ek/antgo/utils/conv"
+	"go.uber.org/zap"
+)
+
+// initHttpClients 按 http_clients.<name> 创建命名 HTTP 客户端 / initHttpClients builds the named clients under http_clients.<name>
+func initHttpClients() {
+	for name, value := range config.GetStringMap("http_clients") {
+		var opts ahttp.ClientOptions
+		if err := conv.ToStruct(value, &opts); err != nil {
+			alog.Write.Error("Invalid HTTP client config", zap.String("name", name), zap.Error(err))
+			continue
+		}
+		client, err := opts.Build()
+		if err != nil {
+			alog.Write.Error("Build HTTP client failed", zap.String("name", name), zap.Error(err))
+			continue
+		}
+		ahttp.Register(name, client)
+	}
+}
+
+// Http 获取命名 HTTP 客户端，不传名称时返回 "default" 客户端或全局客户端，名称未配置时 panic 并给出名称
+// Http returns a named HTTP client; without a name it returns "default" or the shared client, and panics naming unknown clients
+func Http(name ...string) *ahttp.HttpClient {
+	key := "default"
+	if len(name) > 0 && name[0] != "" {
+		key = name[0]
+	}
+	if client, ok := ahttp.Get(key); ok {
+		return client
+	}
+	if key == "default" {
+		return ahttp.New(nil)
+	}
+	panic(fmt.Sprintf("http client %q is not configured under http_clients", key))
+}
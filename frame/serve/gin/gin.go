@@ -3,10 +3,12 @@ package gin
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/small-ek/antgo/frame/ant"
 	"github.com/small-ek/antgo/frame/serve"
 	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
 	"go.uber.org/zap"
 	"net/http"
 	"os"
@@ -44,6 +46,12 @@ func (g *Gin) SetApp(app interface{}) error {
 	// 设置生产模式提升性能
 	// Set production mode to enhance performance
 	gin.SetMode(gin.ReleaseMode)
+
+	// 设置可信代理，仅信任 system.trusted_proxies 中的地址转发的 X-Forwarded-For，未配置时不信任任何代理
+	// Only trust X-Forwarded-For from system.trusted_proxies; no proxy is trusted when unset
+	if err := engine.SetTrustedProxies(config.GetStringSlice("system.trusted_proxies")); err != nil {
+		return fmt.Errorf("gin adapter SetApp: invalid system.trusted_proxies: %w", err)
+	}
 	g.app = engine
 	return nil
 }
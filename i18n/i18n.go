@@ -403,24 +403,37 @@ func TDate(c *gin.Context, t time.Time) string {
 	return t.Format(bundle.datetimeLayout)
 }
 
+// TDefault 获取翻译文本，未注入语言包或缺少翻译时返回默认文本
+// Get translated text, returning fallback when no bundle is injected or the key is missing
+func TDefault(c *gin.Context, key, fallback string, args ...interface{}) string {
+	value, _ := c.Get(ContextKeyLanguage)
+	if bundle, ok := value.(*LanguageBundle); !ok || bundle == nil {
+		return fallback
+	}
+	if msg := T(c, key, args...); msg != "" && msg != key {
+		return msg
+	}
+	return fallback
+}
+
 /****************************** 语言包方法 Bundle Methods ******************************/
 
 // translate 执行翻译逻辑
 // Perform translation logic
 func (b *LanguageBundle) translate(key string, args ...interface{}) string {
-	// 当前语言包查找 | Lookup in current bundle
-	if val, exists := b.flatTranslations[key]; exists {
-		return formatString(conv.String(val), args)
-	}
-
-	// 回退语言处理 | Fallback handling
-	if fbBundle := getBundleByLanguage(globalConfiguration.FallbackLang); fbBundle != nil {
-		return fbBundle.translate(key, args...)
-	}
-
-	// 默认语言处理 | Default language handling
-	if defBundle := getBundleByLanguage(globalConfiguration.DefaultLang); defBundle != nil {
-		return defBundle.translate(key, args...)
+	// 依次查找当前、回退与默认语言包，只查一层避免互相递归
+	// Look up the current, fallback and default bundles in turn without recursing between them
+	for _, bundle := range []*LanguageBundle{
+		b,
+		getBundleByLanguage(globalConfiguration.FallbackLang),
+		getBundleByLanguage(globalConfiguration.DefaultLang),
+	} {
+		if bundle == nil {
+			continue
+		}
+		if val, exists := bundle.flatTranslations[key]; exists {
+			return formatString(conv.String(val), args)
+		}
 	}
 
 	return key // 最终退回键名 | Fallback to key
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 )
@@ -32,9 +33,28 @@ type Config struct {
 	DialerKeepAlive       time.Duration // Dialer 的 Keep-Alive 时间 / Dialer keep-alive time
 	RetryWaitTime         time.Duration // 重试等待时间 / RetryWaitTime
 	RetryMaxWaitTime      time.Duration // 最大重试等待时间 / RetryMaxWaitTime
+	RetryPolicy           *RetryPolicy  // 重试策略，为空时由 RetryAttempts/RetryWaitTime/RetryMaxWaitTime 生成 / Retry policy, derived from the Retry* fields when nil
 	ProxyURL              string        // HTTP 代理地址, eg: "http://127.0.0.1:7890"
 	ProxyUser             string        // 代理认证用户名
 	ProxyPass             string        // 代理认证密码
+
+	Breaker  *BreakerConfig    // 熔断与隔离配置，为空时不启用 / Circuit breaker and bulkhead, disabled when nil
+	Balancer *BalancerConfig   // 多节点负载均衡，设置后忽略 BaseURL / Multi-endpoint load balancing, overrides BaseURL when set
+	BaseURL  string            // 基础地址 / Base URL prepended to relative request URLs
+	Headers  map[string]string // 默认请求头 / Default request headers
+	Auth     AuthConfig        // 认证方式 / Authentication
+}
+
+// AuthConfig 客户端认证配置 / AuthConfig configures client authentication
+type AuthConfig struct {
+	Type     string        `json:"type"`     // basic、bearer、api_key 或 oauth2 / basic, bearer, api_key or oauth2
+	Username string        `json:"username"` // basic 用户名 / Basic auth username
+	Password string        `json:"password"` // basic 密码 / Basic auth password
+	Token    string        `json:"token"`    // bearer 令牌 / Bearer token
+	Key      string        `json:"key"`      // API Key
+	Header   string        `json:"header"`   // API Key 请求头，默认 "X-API-Key" / API key header, defaults to "X-API-Key"
+	Query    string        `json:"query"`    // 设置后 API Key 作为该查询参数发送 / Send the API key as this query parameter instead
+	OAuth2   *OAuth2Config `json:"oauth2"`   // OAuth2 令牌获取配置 / OAuth2 token settings
 }
 
 // HttpClient 是对 Resty 客户端的封装，支持自定义配置和日志 / HttpClient is a wrapper for the Resty client with custom configuration and logging support
@@ -43,6 +63,10 @@ type HttpClient struct {
 	httpTransport *http.Transport // HTTP 传输层配置 / HTTP transport layer configuration
 	logger        *zap.Logger     // 日志记录器 / Logger instance
 	config        *Config
+	breaker       *breakerTransport // 熔断传输层 / Circuit breaker transport
+	transport     http.RoundTripper // 自定义底层传输，用于测试替身 / Custom base transport, e.g. test doubles
+	oauth2        *oauth2Transport  // OAuth2 令牌传输层 / OAuth2 token transport
+	balancer      *balancer         // 负载均衡传输层 / Load balancing transport
 	mu            sync.RWMutex
 }
 
@@ -51,36 +75,87 @@ var (
 	once            sync.Once
 )
 
-// New 创建一个新的 HTTP 客户端实例 / NewHttpClient creates a new HTTP client instance
+// New 返回全局共享的 HTTP 客户端，仅首次调用的配置生效；需要不同配置时使用 NewClient 或命名客户端
+// New returns the shared HTTP client and only the first config takes effect; use NewClient or named clients for others
 func New(config *Config) *HttpClient {
 	once.Do(func() {
-		if config == nil {
-			config = DefaultConfig()
-		}
+		singletonClient = NewClient(config)
+	})
+	return singletonClient
+}
+
+// NewClient 创建独立的 HTTP 客户端实例 / NewClient creates an independent HTTP client instance
+func NewClient(config *Config) *HttpClient {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	transport := buildTransport(config)
+	h := &HttpClient{
+		httpClient:    newRestyClient(config, transport),
+		httpTransport: transport,
+		config:        config,
+	}
+	h.init()
+	if config.BaseURL != "" {
+		h.httpClient.SetBaseURL(config.BaseURL)
+	}
+	for key, value := range config.Headers {
+		h.httpClient.SetHeader(key, value)
+	}
+	h.applyAuth(config.Auth)
+	if config.Breaker != nil {
+		h.SetCircuitBreaker(*config.Breaker)
+	}
+	if config.Balancer != nil {
+		h.SetBalancer(*config.Balancer)
+	}
+	if config.ProxyURL != "" {
+		h.SetProxy(config.ProxyURL, config.ProxyUser, config.ProxyPass)
+	}
+	return h
+}
 
-		singletonClient = &HttpClient{
-			httpClient:    newRestyClient(config),
-			httpTransport: buildTransport(config),
-			config:        config,
+// applyAuth 设置认证信息 / applyAuth configures authentication
+func (h *HttpClient) applyAuth(auth AuthConfig) {
+	switch strings.ToLower(auth.Type) {
+	case "basic":
+		h.httpClient.SetBasicAuth(auth.Username, auth.Password)
+	case "bearer":
+		h.httpClient.SetAuthToken(auth.Token)
+	case "api_key", "apikey":
+		if auth.Query != "" {
+			h.httpClient.SetQueryParam(auth.Query, auth.Key)
+			return
 		}
-		singletonClient.init()
-		if config.ProxyURL != "" && config.ProxyUser != "" && config.ProxyPass != "" {
-			singletonClient = singletonClient.SetProxy(config.ProxyURL, config.ProxyUser, config.ProxyPass)
+		header := auth.Header
+		if header == "" {
+			header = "X-API-Key"
 		}
-	})
-	return singletonClient
+		h.httpClient.SetHeader(header, auth.Key)
+	case "oauth2":
+		if auth.OAuth2 != nil {
+			h.SetOAuth2(*auth.OAuth2)
+		}
+	}
 }
 
 // newRestyClient 配置 Resty 客户端 / Configures the Resty client
-func newRestyClient(config *Config) *resty.Client {
+func newRestyClient(config *Config, transport http.RoundTripper) *resty.Client {
 	client := resty.NewWithClient(&http.Client{
-		Transport: buildTransport(config),
+		Transport: transport,
 		Timeout:   config.Timeout,
 	})
 
-	client.SetRetryCount(config.RetryAttempts)
-	client.SetRetryWaitTime(config.RetryWaitTime)
-	client.SetRetryMaxWaitTime(config.RetryMaxWaitTime)
+	policy := RetryPolicy{
+		MaxAttempts: config.RetryAttempts,
+		BaseDelay:   config.RetryWaitTime,
+		MaxDelay:    config.RetryMaxWaitTime,
+	}
+	if config.RetryPolicy != nil {
+		policy = *config.RetryPolicy
+	}
+	applyRetryPolicy(client, policy)
 
 	return client
 }
@@ -155,7 +230,7 @@ func (h *HttpClient) SetProxy(proxyURL, user, pass string) *HttpClient {
 		u.User = url.UserPassword(user, pass)
 	}
 	h.httpTransport.Proxy = http.ProxyURL(u)
-	h.httpClient.SetTransport(h.httpTransport)
+	h.applyTransport()
 
 	h.httpClient.OnBeforeRequest(func(c *resty.Client, r *resty.Request) error {
 		if h.config.ProxyUser != "" && h.config.ProxyPass != "" {
@@ -190,11 +265,89 @@ func (h *HttpClient) SetTLSClientCert(certFile, keyFile string) error {
 
 	// 更新 Transport 的 TLS 配置
 	h.httpTransport.TLSClientConfig = newTLSConfig
-	h.httpClient.SetTransport(h.httpTransport)
+	h.applyTransport()
 
 	return nil
 }
 
+// SetCircuitBreaker 按主机或接口启用熔断与并发隔离，被拒绝的请求立即返回 *RejectedError
+// SetCircuitBreaker enables the per-host or per-endpoint breaker and bulkhead; rejected calls fail fast with *RejectedError
+func (h *HttpClient) SetCircuitBreaker(cfg BreakerConfig) *HttpClient {
+	h.breaker = newBreakerTransport(cfg)
+	h.applyTransport()
+	return h
+}
+
+// BreakerStats 返回熔断器状态快照，供健康检查接口输出 / BreakerStats returns breaker snapshots for health reports
+func (h *HttpClient) BreakerStats() []BreakerStats {
+	if h.breaker == nil {
+		return nil
+	}
+	return h.breaker.stats()
+}
+
+// SetTransport 替换底层传输（如 ahttptest 的模拟或录制传输），熔断仍包装在外层，传入 nil 恢复默认
+// SetTransport replaces the base transport (e.g. the ahttptest mock or recorder); the breaker still wraps it, nil restores the default
+func (h *HttpClient) SetTransport(rt http.RoundTripper) *HttpClient {
+	h.transport = rt
+	h.applyTransport()
+	return h
+}
+
+// SetBalancer 在多个节点间负载均衡：请求使用相对路径，按策略分配到节点，连接失败时切换节点，
+// 连续 5xx 或超时的节点被暂时摘除。会将基础地址设为 BalancerHost，并替换之前的负载均衡器。
+//
+// SetBalancer balances requests across several endpoints: relative paths are routed by the strategy, connection
+// errors fail over, and endpoints with repeated 5xx or timeouts are ejected for a while. It sets the base URL to
+// BalancerHost and replaces any previous balancer.
+func (h *HttpClient) SetBalancer(cfg BalancerConfig) *HttpClient {
+	if h.balancer != nil {
+		h.balancer.close()
+	}
+	h.balancer = newBalancer(cfg)
+	h.httpClient.SetBaseURL("http://" + BalancerHost)
+	h.applyTransport()
+	return h
+}
+
+// BalancerStats 返回负载均衡节点状态快照 / BalancerStats returns endpoint snapshots of the balancer
+func (h *HttpClient) BalancerStats() []EndpointStats {
+	if h.balancer == nil {
+		return nil
+	}
+	return h.balancer.stats()
+}
+
+// Close 停止健康检查与节点监听等后台任务并关闭解析器 / Close stops background work such as health checks and endpoint watching and closes the resolver
+func (h *HttpClient) Close() {
+	if h.balancer != nil {
+		h.balancer.close()
+	}
+}
+
+// applyTransport 组装传输层：底层传输、熔断、负载均衡、OAuth2 由内向外包装
+// applyTransport assembles the transport chain: base transport, breaker, balancer, then OAuth2 outermost
+func (h *HttpClient) applyTransport() {
+	var rt http.RoundTripper = h.httpTransport
+	if h.transport != nil {
+		rt = h.transport
+	}
+	base := rt
+	if h.breaker != nil {
+		h.breaker.next = rt
+		rt = h.breaker
+	}
+	if h.balancer != nil {
+		h.balancer.setTransports(rt, base)
+		rt = h.balancer
+	}
+	if h.oauth2 != nil {
+		h.oauth2.next = rt
+		rt = h.oauth2
+	}
+	h.httpClient.SetTransport(rt)
+}
+
 // SetLog 设置日志记录器 / SetLog sets the logger
 func (h *HttpClient) SetLog(logger *zap.Logger) *HttpClient {
 	if logger == nil {
@@ -254,11 +407,23 @@ func (h *HttpClient) Request() *resty.Request {
 	return h.httpClient.R()
 }
 
+// RequestWithContext 返回绑定上下文的请求实例，截止时间与 request_id 随上下文传递
+// RequestWithContext returns a request bound to ctx so deadlines and request_id propagate
+func (h *HttpClient) RequestWithContext(ctx context.Context) *resty.Request {
+	return h.httpClient.R().SetContext(ctx)
+}
+
 // Client 返回 Resty 客户端实例 / Returns the Resty client instance
 func (h *HttpClient) Client() *resty.Client {
 	return h.httpClient
 }
 
+// Transport 返回已组装的传输层（含熔断、负载均衡与 OAuth2），可用于 httputil.ReverseProxy 等标准库组件
+// Transport returns the assembled transport chain (breaker, balancer and OAuth2), e.g. for httputil.ReverseProxy
+func (h *HttpClient) Transport() http.RoundTripper {
+	return h.httpClient.GetClient().Transport
+}
+
 // init 初始化时统一设置 User-Agent 头部 / Set the User-Agent header during initialization
 func (h *HttpClient) init() {
 	h.httpClient.SetHeader("User-Agent", "antgo")
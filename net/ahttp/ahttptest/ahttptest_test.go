@@ -0,0 +1,122 @@
+package ahttptest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/small-ek/antgo/net/ahttp"
+)
+
+// newClient 创建不重试的客户端 / newClient creates a client without retries
+func newClient(rt http.RoundTripper) *ahttp.HttpClient {
+	conf := ahttp.DefaultConfig()
+	conf.RetryAttempts = 0
+	return ahttp.NewClient(conf).SetTransport(rt)
+}
+
+// TestMock 测试按方法、URL 与请求体匹配及调用次数断言 / TestMock covers matching and call count assertions
+func TestMock(t *testing.T) {
+	mock := NewMock()
+	mock.On("POST", "/orders").WithBody(`{"sku":"a1","qty":2}`).Times(1).ReplyJSON(http.StatusCreated, map[string]any{"id": 7})
+	mock.On("GET", "https://api.example.com/orders/7?expand=items").Reply(http.StatusOK, "ok")
+	mock.On("GET", "/down").ReplyError(errors.New("connection reset"))
+
+	client := newClient(mock)
+	resp, err := client.Request().SetBody(map[string]any{"qty": 2, "sku": "a1"}).Post("https://api.example.com/orders")
+	if err != nil || resp.StatusCode() != http.StatusCreated || !strings.Contains(resp.String(), `"id":7`) {
+		t.Fatalf("unexpected response: %v %v", resp, err)
+	}
+	if resp, err := client.Request().Get("https://api.example.com/orders/7?expand=items"); err != nil || resp.String() != "ok" {
+		t.Fatalf("unexpected response: %v %v", resp, err)
+	}
+	if _, err := client.Request().Get("https://api.example.com/down"); err == nil {
+		t.Fatal("expected the stubbed network error")
+	}
+
+	mock.AssertCalled(t, "post", "/orders", 1)
+	mock.AssertExpectations(t)
+
+	// Times 用尽后不再匹配 / The stub stops matching once Times is used up
+	if _, err := client.Request().SetBody(`{"sku":"a1","qty":2}`).Post("https://api.example.com/orders"); !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("expected ErrNoMatch, got %v", err)
+	}
+	var rec recorderTB
+	if mock.AssertExpectations(&rec) || rec.errors != 1 {
+		t.Fatalf("expected one unmatched request error, got %d", rec.errors)
+	}
+}
+
+// TestRecorder 测试录制、脱敏与离线回放 / TestRecorder covers recording, redaction and offline replay
+func TestRecorder(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Set-Cookie", "session=abc")
+		_, _ = w.Write([]byte("hello " + r.URL.Query().Get("name")))
+	}))
+	defer ts.Close()
+
+	for _, name := range []string{"cassette.yaml", "cassette.json"} {
+		t.Run(name, func(t *testing.T) {
+			hits.Store(0)
+			path := filepath.Join(t.TempDir(), "fixtures", name)
+
+			rec, err := NewRecorder(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec.Mode() != ModeRecord {
+				t.Fatalf("expected record mode, got %v", rec.Mode())
+			}
+			client := newClient(rec)
+			client.SetCommonHeader("Authorization", "Bearer secret")
+			if resp, err := client.Request().Get(ts.URL + "/greet?name=ant"); err != nil || resp.String() != "hello ant" {
+				t.Fatalf("unexpected response: %v %v", resp, err)
+			}
+			if err := rec.Stop(); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), "secret") || strings.Contains(string(data), "session=abc") {
+				t.Fatalf("cassette leaks credentials:\n%s", data)
+			}
+
+			replay, err := NewRecorder(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replay.Mode() != ModeReplay {
+				t.Fatalf("expected replay mode, got %v", replay.Mode())
+			}
+			client = newClient(replay)
+			if resp, err := client.Request().Get(ts.URL + "/greet?name=ant"); err != nil || resp.String() != "hello ant" {
+				t.Fatalf("unexpected replay: %v %v", resp, err)
+			}
+			if _, err := client.Request().Get(ts.URL + "/greet?name=other"); !errors.Is(err, ErrNoInteraction) {
+				t.Fatalf("expected ErrNoInteraction, got %v", err)
+			}
+			if hits.Load() != 1 {
+				t.Fatalf("expected a single live request, got %d", hits.Load())
+			}
+		})
+	}
+}
+
+// recorderTB 记录断言失败次数 / recorderTB counts assertion failures
+type recorderTB struct {
+	errors int
+}
+
+func (r *recorderTB) Helper() {}
+
+func (r *recorderTB) Errorf(string, ...any) { r.errors++ }
@@ -0,0 +1,299 @@
+package ahttptest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Mode 录制器模式 / Mode is the recorder mode
+type Mode int
+
+const (
+	ModeAuto   Mode = iota // 文件存在时回放，否则录制 / Replay when the cassette exists, record otherwise
+	ModeRecord             // 总是请求真实服务并覆盖文件 / Always hit the real endpoint and overwrite the cassette
+	ModeReplay             // 只回放，不发出网络请求 / Replay only, never touch the network
+)
+
+// ErrNoInteraction 回放时找不到匹配的录制记录 / No recorded interaction matches the request during replay
+var ErrNoInteraction = errors.New("ahttptest: no recorded interaction matches the request")
+
+// redacted 脱敏后的请求头值 / Value written in place of redacted headers
+const redacted = "[REDACTED]"
+
+// defaultRedactHeaders 默认脱敏的请求头 / Headers redacted by default
+var defaultRedactHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-API-Key", "X-Signature",
+}
+
+// Cassette 录制文件内容 / Cassette is the content of a recording file
+type Cassette struct {
+	Interactions []Interaction `json:"interactions" yaml:"interactions"`
+}
+
+// Interaction 一次请求与响应 / Interaction is one recorded exchange
+type Interaction struct {
+	Request  RecordedRequest  `json:"request" yaml:"request"`
+	Response RecordedResponse `json:"response" yaml:"response"`
+}
+
+// RecordedRequest 录制的请求 / RecordedRequest is a recorded request
+type RecordedRequest struct {
+	Method       string              `json:"method" yaml:"method"`
+	URL          string              `json:"url" yaml:"url"`
+	Headers      map[string][]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body         string              `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyEncoding string              `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"` // 二进制内容为 "base64" / "base64" for binary bodies
+}
+
+// RecordedResponse 录制的响应 / RecordedResponse is a recorded response
+type RecordedResponse struct {
+	Status       int                 `json:"status" yaml:"status"`
+	Headers      map[string][]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body         string              `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyEncoding string              `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"`
+}
+
+// RecorderOptions 录制器选项 / RecorderOptions configures a Recorder
+type RecorderOptions struct {
+	Mode          Mode              // 默认 ModeAuto / Defaults to ModeAuto
+	Transport     http.RoundTripper // 录制时使用的真实传输，默认 http.DefaultTransport / Real transport used when recording
+	RedactHeaders []string          // 额外脱敏的请求头 / Extra headers to redact, added to the defaults
+	// Matcher 自定义回放匹配，默认比较方法、URL 与请求体 / Custom replay matcher, defaults to method, URL and body
+	Matcher func(req *http.Request, body []byte, recorded RecordedRequest) bool
+}
+
+// Recorder 录制/回放传输：首次运行录制真实交互到 YAML/JSON 文件，之后离线回放
+// Recorder records real exchanges to a YAML/JSON cassette once and replays them offline afterwards
+type Recorder struct {
+	path     string
+	mode     Mode
+	opts     RecorderOptions
+	redact   map[string]bool
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder 创建录制器，文件扩展名为 .yaml/.yml 时使用 YAML，否则使用 JSON
+// NewRecorder creates a recorder; .yaml/.yml cassettes are YAML, anything else is JSON
+func NewRecorder(path string, opts ...RecorderOptions) (*Recorder, error) {
+	var o RecorderOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Transport == nil {
+		o.Transport = http.DefaultTransport
+	}
+	if o.Matcher == nil {
+		o.Matcher = defaultMatcher
+	}
+
+	r := &Recorder{path: path, mode: o.Mode, opts: o, redact: make(map[string]bool)}
+	for _, h := range slices.Concat(defaultRedactHeaders, o.RedactHeaders) {
+		r.redact[http.CanonicalHeaderKey(h)] = true
+	}
+
+	if r.mode == ModeAuto {
+		r.mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			r.mode = ModeReplay
+		}
+	}
+	if r.mode == ModeReplay {
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Mode 返回实际生效的模式 / Mode returns the effective mode
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// RoundTrip 实现 http.RoundTripper / RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == ModeReplay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body)
+}
+
+// record 请求真实服务并保存交互 / record calls the real endpoint and stores the exchange
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.opts.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	reqBodyText, reqEncoding := encodeBody(body)
+	respBodyText, respEncoding := encodeBody(respBody)
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method:       req.Method,
+			URL:          req.URL.String(),
+			Headers:      r.redactHeaders(req.Header),
+			Body:         reqBodyText,
+			BodyEncoding: reqEncoding,
+		},
+		Response: RecordedResponse{
+			Status:       resp.StatusCode,
+			Headers:      r.redactHeaders(resp.Header),
+			Body:         respBodyText,
+			BodyEncoding: respEncoding,
+		},
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// replay 按顺序回放第一条未使用的匹配记录 / replay returns the first unused matching interaction
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] || !r.opts.Matcher(req, body, in.Request) {
+			continue
+		}
+		r.used[i] = true
+
+		respBody, err := decodeBody(in.Response.Body, in.Response.BodyEncoding)
+		if err != nil {
+			return nil, err
+		}
+		header := http.Header{}
+		for k, v := range in.Response.Headers {
+			header[http.CanonicalHeaderKey(k)] = v
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+			StatusCode:    in.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(respBody)),
+			ContentLength: int64(len(respBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, req.URL)
+}
+
+// Stop 录制模式下写入文件，回放模式下无操作 / Stop writes the cassette when recording and is a no-op when replaying
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		data []byte
+		err  error
+	)
+	if isYAML(r.path) {
+		data, err = yaml.Marshal(r.cassette)
+	} else {
+		data, err = json.MarshalIndent(r.cassette, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0o644)
+}
+
+// load 读取录制文件 / load reads the cassette
+func (r *Recorder) load() error {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	if isYAML(r.path) {
+		err = yaml.Unmarshal(data, &r.cassette)
+	} else {
+		err = json.Unmarshal(data, &r.cassette)
+	}
+	if err != nil {
+		return fmt.Errorf("ahttptest: parse cassette %s: %w", r.path, err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return nil
+}
+
+// redactHeaders 复制请求头并脱敏 / redactHeaders copies the headers, replacing sensitive values
+func (r *Recorder) redactHeaders(h http.Header) map[string][]string {
+	if len(h) == 0 {
+		return nil
+	}
+	out := make(map[string][]string, len(h))
+	for k, v := range h {
+		if r.redact[http.CanonicalHeaderKey(k)] {
+			out[k] = []string{redacted}
+			continue
+		}
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+// defaultMatcher 比较方法、URL 与请求体 / defaultMatcher compares method, URL and body
+func defaultMatcher(req *http.Request, body []byte, recorded RecordedRequest) bool {
+	if req.Method != recorded.Method || req.URL.String() != recorded.URL {
+		return false
+	}
+	want, err := decodeBody(recorded.Body, recorded.BodyEncoding)
+	return err == nil && bodyEqual(want, body)
+}
+
+// encodeBody 文本原样保存，二进制使用 base64 / encodeBody keeps text as is and base64-encodes binary data
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// decodeBody 还原请求体 / decodeBody restores a recorded body
+func decodeBody(body, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
+
+// isYAML 根据扩展名判断格式 / isYAML picks the format from the extension
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
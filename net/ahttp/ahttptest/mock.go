@@ -0,0 +1,265 @@
+// Package ahttptest 提供 ahttp 客户端的测试工具：按请求匹配的模拟传输与录制/回放传输
+// Package ahttptest provides test helpers for ahttp clients: a request-matching mock transport and a record/replay transport
+package ahttptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrNoMatch 没有匹配的模拟响应 / No stub matched the request
+var ErrNoMatch = errors.New("ahttptest: no stub matches the request")
+
+// TB testing.T 与 testing.B 的公共方法 / TB is the subset of testing.TB used for assertions
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Mock 模拟传输，按方法、URL 与请求体匹配并返回预设响应
+// Mock is a RoundTripper that matches requests by method, URL and body and returns canned responses
+type Mock struct {
+	mu        sync.Mutex
+	stubs     []*Stub
+	unmatched []string
+}
+
+// NewMock 创建模拟传输 / NewMock creates a mock transport
+func NewMock() *Mock {
+	return &Mock{}
+}
+
+// Stub 单条模拟规则 / Stub is one mocked exchange
+type Stub struct {
+	method  string
+	url     string
+	body    *string
+	headers http.Header
+	times   int
+
+	status      int
+	respHeaders http.Header
+	respBody    []byte
+	err         error
+	calls       int
+}
+
+// On 添加规则。url 为完整地址时比较完整 URL；以 "/" 开头时只比较路径，含 "?" 时同时比较查询参数
+// On adds a stub. A full url is compared as a whole; a url starting with "/" matches the path, and the query too when it has one
+func (m *Mock) On(method, url string) *Stub {
+	s := &Stub{method: strings.ToUpper(method), url: url, status: http.StatusOK, respHeaders: http.Header{}}
+	m.mu.Lock()
+	m.stubs = append(m.stubs, s)
+	m.mu.Unlock()
+	return s
+}
+
+// WithBody 要求请求体一致，两边都是 JSON 时按语义比较 / WithBody requires the body to match, comparing JSON semantically
+func (s *Stub) WithBody(body string) *Stub {
+	s.body = &body
+	return s
+}
+
+// WithHeader 要求请求头一致 / WithHeader requires a request header value
+func (s *Stub) WithHeader(key, value string) *Stub {
+	if s.headers == nil {
+		s.headers = http.Header{}
+	}
+	s.headers.Add(key, value)
+	return s
+}
+
+// Times 限制匹配次数，用尽后不再匹配，AssertExpectations 要求恰好调用该次数
+// Times limits how often the stub matches; AssertExpectations then requires exactly that many calls
+func (s *Stub) Times(n int) *Stub {
+	s.times = n
+	return s
+}
+
+// Reply 设置响应状态码与响应体 / Reply sets the response status and body
+func (s *Stub) Reply(status int, body string) *Stub {
+	s.status = status
+	s.respBody = []byte(body)
+	return s
+}
+
+// ReplyJSON 以 JSON 响应 / ReplyJSON responds with v encoded as JSON
+func (s *Stub) ReplyJSON(status int, v any) *Stub {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("ahttptest: encode reply: %v", err))
+	}
+	s.status = status
+	s.respBody = data
+	s.respHeaders.Set("Content-Type", "application/json")
+	return s
+}
+
+// ReplyHeader 设置响应头 / ReplyHeader sets a response header
+func (s *Stub) ReplyHeader(key, value string) *Stub {
+	s.respHeaders.Add(key, value)
+	return s
+}
+
+// ReplyError 返回传输错误，模拟网络故障 / ReplyError fails the round trip, simulating a network error
+func (s *Stub) ReplyError(err error) *Stub {
+	s.err = err
+	return s
+}
+
+// RoundTrip 实现 http.RoundTripper / RoundTrip implements http.RoundTripper
+func (m *Mock) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.stubs {
+		if (s.times > 0 && s.calls >= s.times) || !s.matches(req, body) {
+			continue
+		}
+		s.calls++
+		if s.err != nil {
+			return nil, s.err
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", s.status, http.StatusText(s.status)),
+			StatusCode:    s.status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        s.respHeaders.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(s.respBody)),
+			ContentLength: int64(len(s.respBody)),
+			Request:       req,
+		}, nil
+	}
+
+	m.unmatched = append(m.unmatched, req.Method+" "+req.URL.String())
+	return nil, fmt.Errorf("%w: %s %s", ErrNoMatch, req.Method, req.URL)
+}
+
+// matches 判断请求是否匹配 / matches reports whether the request matches the stub
+func (s *Stub) matches(req *http.Request, body []byte) bool {
+	if s.method != "" && s.method != req.Method {
+		return false
+	}
+	if !matchURL(s.url, req) {
+		return false
+	}
+	for key, values := range s.headers {
+		got := req.Header.Values(key)
+		for _, v := range values {
+			if !slices.Contains(got, v) {
+				return false
+			}
+		}
+	}
+	return s.body == nil || bodyEqual([]byte(*s.body), body)
+}
+
+// Calls 返回规则被调用的次数 / Calls returns how often stubs for method and url were hit
+func (m *Mock) Calls(method, url string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	method = strings.ToUpper(method)
+	total := 0
+	for _, s := range m.stubs {
+		if s.method == method && s.url == url {
+			total += s.calls
+		}
+	}
+	return total
+}
+
+// AssertCalled 断言调用次数 / AssertCalled asserts the number of calls for method and url
+func (m *Mock) AssertCalled(t TB, method, url string, n int) bool {
+	t.Helper()
+	if got := m.Calls(method, url); got != n {
+		t.Errorf("ahttptest: %s %s called %d times, want %d", strings.ToUpper(method), url, got, n)
+		return false
+	}
+	return true
+}
+
+// AssertExpectations 断言每条规则都被调用过（设置 Times 时要求恰好该次数），且没有未匹配的请求
+// AssertExpectations asserts every stub was called (exactly Times when set) and no request went unmatched
+func (m *Mock) AssertExpectations(t TB) bool {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ok := true
+	for _, s := range m.stubs {
+		switch {
+		case s.times > 0 && s.calls != s.times:
+			t.Errorf("ahttptest: %s %s called %d times, want %d", s.method, s.url, s.calls, s.times)
+			ok = false
+		case s.times == 0 && s.calls == 0:
+			t.Errorf("ahttptest: %s %s was never called", s.method, s.url)
+			ok = false
+		}
+	}
+	for _, u := range m.unmatched {
+		t.Errorf("ahttptest: unmatched request %s", u)
+		ok = false
+	}
+	return ok
+}
+
+// Reset 清空规则与调用记录 / Reset drops all stubs and recorded calls
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stubs, m.unmatched = nil, nil
+}
+
+// matchURL 比较 URL / matchURL compares the stub url with the request
+func matchURL(pattern string, req *http.Request) bool {
+	if pattern == "" {
+		return true
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return pattern == req.URL.String()
+	}
+	path, query, hasQuery := strings.Cut(pattern, "?")
+	if path != req.URL.Path {
+		return false
+	}
+	return !hasQuery || query == req.URL.RawQuery
+}
+
+// readBody 读取并恢复请求体 / readBody reads the request body and restores it
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// bodyEqual 比较请求体，两边都是 JSON 时忽略格式与键顺序 / bodyEqual compares bodies, ignoring JSON formatting and key order
+func bodyEqual(want, got []byte) bool {
+	if bytes.Equal(want, got) {
+		return true
+	}
+	var w, g any
+	if json.Unmarshal(want, &w) != nil || json.Unmarshal(got, &g) != nil {
+		return false
+	}
+	return reflect.DeepEqual(w, g)
+}
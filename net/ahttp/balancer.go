@@ -0,0 +1,438 @@
+package ahttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/small-ek/antgo/os/alog"
+	"go.uber.org/zap"
+)
+
+// 负载均衡策略 / Load balancing strategies
+const (
+	BalanceRoundRobin    = "round_robin"    // 轮询 / Round robin
+	BalanceWeighted      = "weighted"       // 平滑加权轮询 / Smooth weighted round robin
+	BalanceLeastInflight = "least_inflight" // 最少进行中请求 / Fewest requests in flight
+)
+
+// BalancerHost 启用负载均衡后客户端基础地址使用的虚拟主机，发往该主机的请求被分配到实际节点
+// BalancerHost is the virtual host used as the base URL when balancing; requests to it are routed to real endpoints
+const BalancerHost = "ahttp-balancer.invalid"
+
+// ErrNoEndpoint 没有可用节点 / No endpoint is configured or resolved
+var ErrNoEndpoint = errors.New("ahttp: no upstream endpoint available")
+
+// Endpoint 上游节点 / Endpoint is an upstream base URL
+type Endpoint struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"` // 加权策略使用，默认 1 / Used by the weighted strategy, defaults to 1
+}
+
+// Resolver 动态解析节点列表 / Resolver provides a dynamic endpoint list
+type Resolver interface {
+	// Resolve 返回当前节点列表 / Resolve returns the current endpoints
+	Resolve(ctx context.Context) ([]Endpoint, error)
+	// Watch 阻塞监听变化并回调新列表，ctx 取消时返回 / Watch blocks, reporting new lists until ctx is cancelled
+	Watch(ctx context.Context, update func([]Endpoint))
+}
+
+// BalancerConfig 客户端负载均衡配置 / BalancerConfig configures client-side load balancing
+type BalancerConfig struct {
+	Endpoints           []Endpoint    // 静态节点，设置 Resolver 时被其结果替换 / Static endpoints, replaced by the Resolver when set
+	Strategy            string        // 默认 round_robin / Defaults to round_robin
+	Resolver            Resolver      // 动态节点来源，如 EtcdResolver，实现 io.Closer 时随客户端关闭 / Dynamic endpoint source such as EtcdResolver, closed with the client when it implements io.Closer
+	HealthCheckPath     string        // 主动健康检查路径，为空不检查 / Active health check path, empty disables checks
+	HealthCheckInterval time.Duration // 默认 10s / Defaults to 10s
+	HealthCheckTimeout  time.Duration // 默认 2s / Defaults to 2s
+	EjectAfter          int           // 连续失败（5xx 或超时）多少次后摘除，默认 3 / Consecutive 5xx or timeouts before ejection, defaults to 3
+	EjectDuration       time.Duration // 摘除时长，默认 30s / Ejection time, defaults to 30s
+}
+
+// EndpointStats 节点状态快照 / EndpointStats is a snapshot of one endpoint
+type EndpointStats struct {
+	URL          string    `json:"url"`
+	Weight       int       `json:"weight"`
+	Healthy      bool      `json:"healthy"` // 主动健康检查结果 / Result of the active health check
+	EjectedUntil time.Time `json:"ejected_until,omitempty"`
+	InFlight     int       `json:"in_flight"`
+	Failures     int       `json:"failures"` // 当前连续失败次数 / Current consecutive failures
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *BalancerConfig) setDefaults() {
+	switch cfg.Strategy {
+	case BalanceWeighted, BalanceLeastInflight:
+	default:
+		cfg.Strategy = BalanceRoundRobin
+	}
+	if cfg.HealthCheckInterval <= 0 {
+		cfg.HealthCheckInterval = 10 * time.Second
+	}
+	if cfg.HealthCheckTimeout <= 0 {
+		cfg.HealthCheckTimeout = 2 * time.Second
+	}
+	if cfg.EjectAfter <= 0 {
+		cfg.EjectAfter = 3
+	}
+	if cfg.EjectDuration <= 0 {
+		cfg.EjectDuration = 30 * time.Second
+	}
+}
+
+// lbEndpoint 节点运行状态，字段由 balancer.mu 保护 / lbEndpoint is the runtime state of an endpoint, guarded by balancer.mu
+type lbEndpoint struct {
+	url          *url.URL
+	weight       int
+	current      int
+	inflight     int
+	failures     int
+	down         bool
+	ejectedUntil time.Time
+}
+
+// available 判断节点是否可用 / available reports whether the endpoint may receive traffic
+func (e *lbEndpoint) available(now time.Time) bool {
+	return !e.down && !now.Before(e.ejectedUntil)
+}
+
+// balancer 负载均衡传输层 / balancer is the load balancing transport
+type balancer struct {
+	conf   BalancerConfig
+	next   http.RoundTripper // 实际请求（含熔断） / Real requests, including the breaker
+	probe  http.RoundTripper // 健康检查，不经过熔断 / Health checks, bypassing the breaker
+	cancel context.CancelFunc
+	closed sync.Once
+
+	mu        sync.Mutex
+	endpoints []*lbEndpoint
+	rr        int
+}
+
+// newBalancer 创建负载均衡器并启动健康检查与节点监听 / newBalancer creates the balancer and starts health checks and watching
+func newBalancer(conf BalancerConfig) *balancer {
+	conf.setDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &balancer{conf: conf, cancel: cancel}
+	b.setEndpoints(conf.Endpoints)
+
+	if conf.Resolver != nil {
+		resolveCtx, cancelResolve := context.WithTimeout(ctx, 5*time.Second)
+		endpoints, err := conf.Resolver.Resolve(resolveCtx)
+		cancelResolve()
+		if err != nil {
+			logBalancer("Resolve upstream endpoints failed", zap.Error(err))
+		} else {
+			b.setEndpoints(endpoints)
+		}
+		go conf.Resolver.Watch(ctx, b.setEndpoints)
+	}
+	if conf.HealthCheckPath != "" {
+		go b.healthLoop(ctx)
+	}
+	return b
+}
+
+// setTransports 设置请求与健康检查使用的传输层 / setTransports sets the transports for calls and health checks
+func (b *balancer) setTransports(next, probe http.RoundTripper) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.next, b.probe = next, probe
+}
+
+// close 停止后台任务并关闭解析器 / close stops the background goroutines and closes the resolver
+func (b *balancer) close() {
+	b.closed.Do(func() {
+		b.cancel()
+		if closer, ok := b.conf.Resolver.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				logBalancer("Close endpoint resolver failed", zap.Error(err))
+			}
+		}
+	})
+}
+
+// setEndpoints 替换节点列表，保留已有节点的状态 / setEndpoints replaces the list, keeping the state of known endpoints
+func (b *balancer) setEndpoints(list []Endpoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	existing := make(map[string]*lbEndpoint, len(b.endpoints))
+	for _, e := range b.endpoints {
+		existing[e.url.String()] = e
+	}
+	endpoints := make([]*lbEndpoint, 0, len(list))
+	for _, item := range list {
+		u, err := url.Parse(strings.TrimSpace(item.URL))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			logBalancer("Invalid upstream endpoint", zap.String("url", item.URL))
+			continue
+		}
+		weight := max(item.Weight, 1)
+		if e, ok := existing[u.String()]; ok {
+			e.weight = weight
+			endpoints = append(endpoints, e)
+			continue
+		}
+		endpoints = append(endpoints, &lbEndpoint{url: u, weight: weight})
+	}
+	b.endpoints = endpoints
+}
+
+// pick 选择节点，全部不可用时忽略健康状态 / pick selects an endpoint, ignoring health when none is available
+func (b *balancer) pick(tried map[*lbEndpoint]bool) *lbEndpoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	candidates := make([]*lbEndpoint, 0, len(b.endpoints))
+	for _, e := range b.endpoints {
+		if !tried[e] && e.available(now) {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, e := range b.endpoints {
+			if !tried[e] {
+				candidates = append(candidates, e)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var chosen *lbEndpoint
+	switch b.conf.Strategy {
+	case BalanceWeighted:
+		total := 0
+		for _, e := range candidates {
+			e.current += e.weight
+			total += e.weight
+			if chosen == nil || e.current > chosen.current {
+				chosen = e
+			}
+		}
+		chosen.current -= total
+	case BalanceLeastInflight:
+		// 从轮询位置开始比较，使并列节点交替命中 / Start at the round-robin cursor so ties alternate
+		for i := range candidates {
+			e := candidates[(b.rr+i)%len(candidates)]
+			if chosen == nil || e.inflight < chosen.inflight {
+				chosen = e
+			}
+		}
+		b.rr++
+	default:
+		chosen = candidates[b.rr%len(candidates)]
+		b.rr++
+	}
+	chosen.inflight++
+	return chosen
+}
+
+// release 请求结束，记录结果 / release ends a call and records its outcome
+func (b *balancer) release(e *lbEndpoint, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e.inflight--
+	if !failed {
+		e.failures = 0
+		return
+	}
+	e.failures++
+	if e.failures >= b.conf.EjectAfter {
+		e.failures = 0
+		e.ejectedUntil = time.Now().Add(b.conf.EjectDuration)
+		logBalancer("Upstream endpoint ejected", zap.String("url", e.url.String()), zap.Duration("duration", b.conf.EjectDuration))
+	}
+}
+
+// RoundTrip 将虚拟主机的请求分配到节点，连接失败时切换到下一个节点
+// RoundTrip routes requests for the virtual host to an endpoint and fails over on connection errors
+func (b *balancer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != BalancerHost {
+		return b.next.RoundTrip(req)
+	}
+
+	tried := make(map[*lbEndpoint]bool)
+	var lastErr error
+	for {
+		e := b.pick(tried)
+		if e == nil {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, ErrNoEndpoint
+		}
+		tried[e] = true
+
+		r := req.Clone(req.Context())
+		r.URL = e.resolve(req.URL)
+		if r.Host == BalancerHost {
+			// 未指定 Host 时使用节点地址，网关保留原始 Host 时不覆盖 / Use the endpoint host unless an explicit Host was set
+			r.Host = ""
+		}
+		if len(tried) > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				b.release(e, false)
+				return nil, err
+			}
+			r.Body = body
+		}
+
+		resp, err := b.next.RoundTrip(r)
+		if err == nil {
+			failed := resp.StatusCode >= http.StatusInternalServerError
+			if resp.Body == nil {
+				b.release(e, failed)
+			} else {
+				// 响应体关闭时才结束计数，使最少请求策略覆盖流式响应 / Count until the body closes so streamed responses stay in flight
+				resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: func() { b.release(e, failed) }}
+			}
+			return resp, nil
+		}
+
+		var rejected *RejectedError
+		b.release(e, !errors.As(err, &rejected) && isUpstreamFailure(err))
+		if !canFailover(req, err) {
+			return nil, err
+		}
+		lastErr = err
+	}
+}
+
+// resolve 将请求路径拼接到节点地址 / resolve joins the request path onto the endpoint URL
+func (e *lbEndpoint) resolve(u *url.URL) *url.URL {
+	target := *e.url
+	base := strings.TrimRight(e.url.Path, "/")
+	target.Path = base + u.Path
+	target.RawPath = ""
+	if u.RawPath != "" {
+		target.RawPath = strings.TrimRight(e.url.EscapedPath(), "/") + u.RawPath
+	}
+	target.RawQuery = u.RawQuery
+	target.Fragment = ""
+	return &target
+}
+
+// canFailover 判断失败的请求能否发往其他节点：未发出的请求总是可以，已发出的仅限幂等方法
+// canFailover decides whether a failed call may go to another endpoint: unsent calls always, sent ones only when idempotent
+func canFailover(req *http.Request, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	var rejected *RejectedError
+	var opErr *net.OpError
+	if errors.As(err, &rejected) || (errors.As(err, &opErr) && opErr.Op == "dial") {
+		return true
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isUpstreamFailure 判断是否为超时或连接错误 / isUpstreamFailure reports timeouts and connection failures
+func isUpstreamFailure(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// healthLoop 定期主动检查节点 / healthLoop actively checks endpoints on an interval
+func (b *balancer) healthLoop(ctx context.Context) {
+	ticker := time.NewTicker(b.conf.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		b.checkAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkAll 检查所有节点 / checkAll checks every endpoint
+func (b *balancer) checkAll(ctx context.Context) {
+	b.mu.Lock()
+	endpoints := append([]*lbEndpoint(nil), b.endpoints...)
+	probe := b.probe
+	b.mu.Unlock()
+	if probe == nil {
+		return
+	}
+
+	client := &http.Client{Transport: probe, Timeout: b.conf.HealthCheckTimeout}
+	var wg sync.WaitGroup
+	for _, e := range endpoints {
+		wg.Add(1)
+		go func(e *lbEndpoint) {
+			defer wg.Done()
+			healthy := false
+			target := e.resolve(&url.URL{Path: b.conf.HealthCheckPath})
+			if req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil); err == nil {
+				if resp, err := client.Do(req); err == nil {
+					_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+					_ = resp.Body.Close()
+					healthy = resp.StatusCode >= 200 && resp.StatusCode < 300
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			b.mu.Lock()
+			changed := e.down == healthy
+			e.down = !healthy
+			b.mu.Unlock()
+			if changed {
+				logBalancer("Upstream health changed", zap.String("url", e.url.String()), zap.Bool("healthy", healthy))
+			}
+		}(e)
+	}
+	wg.Wait()
+}
+
+// stats 返回节点状态快照 / stats returns endpoint snapshots
+func (b *balancer) stats() []EndpointStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result := make([]EndpointStats, 0, len(b.endpoints))
+	now := time.Now()
+	for _, e := range b.endpoints {
+		s := EndpointStats{
+			URL:      e.url.String(),
+			Weight:   e.weight,
+			Healthy:  !e.down,
+			InFlight: e.inflight,
+			Failures: e.failures,
+		}
+		if now.Before(e.ejectedUntil) {
+			s.EjectedUntil = e.ejectedUntil
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// logBalancer 记录负载均衡日志 / logBalancer logs balancer events
+func logBalancer(msg string, fields ...zap.Field) {
+	if alog.Write != nil {
+		alog.Write.Warn(msg, fields...)
+	}
+}
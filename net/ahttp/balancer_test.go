@@ -0,0 +1,180 @@
+package ahttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// newUpstream 返回响应名称的测试节点 / newUpstream starts a test endpoint that answers with its name
+func newUpstream(name string, status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(name + r.URL.Path))
+	}))
+}
+
+// newBalancedClient 创建不重试的负载均衡客户端 / newBalancedClient creates a balancing client without retries
+func newBalancedClient(cfg BalancerConfig) *HttpClient {
+	conf := DefaultConfig()
+	conf.RetryAttempts = 0
+	conf.Balancer = &cfg
+	return NewClient(conf)
+}
+
+// hits 发送 n 个请求并统计各节点命中次数 / hits sends n requests and counts answers per endpoint
+func hits(t *testing.T, client *HttpClient, n int) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		resp, err := client.Request().Get("/ping")
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[resp.String()]++
+	}
+	return counts
+}
+
+// TestBalancerStrategies 测试轮询与加权策略 / TestBalancerStrategies covers round robin and weighted selection
+func TestBalancerStrategies(t *testing.T) {
+	a, b := newUpstream("a", http.StatusOK), newUpstream("b", http.StatusOK)
+	defer a.Close()
+	defer b.Close()
+
+	rr := newBalancedClient(BalancerConfig{Endpoints: []Endpoint{{URL: a.URL}, {URL: b.URL + "/"}}})
+	defer rr.Close()
+	if got := hits(t, rr, 4); got["a/ping"] != 2 || got["b/ping"] != 2 {
+		t.Fatalf("unexpected round robin distribution %v", got)
+	}
+
+	weighted := newBalancedClient(BalancerConfig{Strategy: BalanceWeighted, Endpoints: []Endpoint{{URL: a.URL, Weight: 3}, {URL: b.URL, Weight: 1}}})
+	defer weighted.Close()
+	if got := hits(t, weighted, 8); got["a/ping"] != 6 || got["b/ping"] != 2 {
+		t.Fatalf("unexpected weighted distribution %v", got)
+	}
+}
+
+// TestBalancerLeastInflight 测试最少进行中请求策略 / TestBalancerLeastInflight covers least-inflight selection
+func TestBalancerLeastInflight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+	fast := newUpstream("fast", http.StatusOK)
+	defer fast.Close()
+
+	client := newBalancedClient(BalancerConfig{Strategy: BalanceLeastInflight, Endpoints: []Endpoint{{URL: slow.URL}, {URL: fast.URL}}})
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _ = client.Request().Get("/ping")
+	}()
+	<-started
+	if got := hits(t, client, 3); got["fast/ping"] != 3 {
+		t.Fatalf("expected the idle endpoint, got %v", got)
+	}
+	close(release)
+	wg.Wait()
+}
+
+// TestBalancerFailover 测试连接失败切换、被动摘除与主动健康检查 / TestBalancerFailover covers failover, ejection and health checks
+func TestBalancerFailover(t *testing.T) {
+	good, bad := newUpstream("good", http.StatusOK), newUpstream("bad", http.StatusInternalServerError)
+	defer good.Close()
+	defer bad.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	deadURL := dead.URL
+	dead.Close()
+
+	// 连接失败的节点透明切换 / Connection failures fail over transparently
+	client := newBalancedClient(BalancerConfig{Endpoints: []Endpoint{{URL: deadURL}, {URL: good.URL}}, EjectAfter: 2})
+	defer client.Close()
+	if got := hits(t, client, 4); got["good/ping"] != 4 {
+		t.Fatalf("expected failover to the good endpoint, got %v", got)
+	}
+	for _, s := range client.BalancerStats() {
+		if s.URL == deadURL && s.EjectedUntil.IsZero() {
+			t.Fatalf("dead endpoint not ejected: %+v", s)
+		}
+	}
+
+	// 连续 5xx 的节点被摘除 / Endpoints returning 5xx are ejected
+	client = newBalancedClient(BalancerConfig{Endpoints: []Endpoint{{URL: bad.URL}, {URL: good.URL}}, EjectAfter: 2})
+	defer client.Close()
+	if got := hits(t, client, 8); got["bad/ping"] != 2 || got["good/ping"] != 6 {
+		t.Fatalf("unexpected distribution after ejection %v", got)
+	}
+
+	// 健康检查失败的节点不再接收请求 / Endpoints failing the health check stop receiving traffic
+	client = newBalancedClient(BalancerConfig{
+		Endpoints:           []Endpoint{{URL: bad.URL}, {URL: good.URL}},
+		HealthCheckPath:     "/health",
+		HealthCheckInterval: 10 * time.Millisecond,
+	})
+	defer client.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats := client.BalancerStats()
+		if !stats[0].Healthy && stats[1].Healthy {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("health check did not mark the bad endpoint: %+v", stats)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := hits(t, client, 4); got["good/ping"] != 4 {
+		t.Fatalf("unhealthy endpoint still used: %v", got)
+	}
+}
+
+// TestParseEndpoint 测试 etcd 节点值解析 / TestParseEndpoint covers etcd endpoint values
+func TestParseEndpoint(t *testing.T) {
+	if e, ok := parseEndpoint([]byte(" http://10.0.0.1:8080 ")); !ok || e.URL != "http://10.0.0.1:8080" || e.Weight != 1 {
+		t.Fatalf("unexpected plain endpoint %+v", e)
+	}
+	if e, ok := parseEndpoint([]byte(`{"url":"http://10.0.0.2","weight":3}`)); !ok || e.Weight != 3 {
+		t.Fatalf("unexpected JSON endpoint %+v", e)
+	}
+	if _, ok := parseEndpoint([]byte(`{"weight":3}`)); ok {
+		t.Fatal("expected an endpoint without url to be rejected")
+	}
+}
+
+// TestEtcdResolverClose 测试关闭客户端时只关闭解析器自建的 etcd 连接 / TestEtcdResolverClose checks only resolver-owned etcd clients are closed
+func TestEtcdResolverClose(t *testing.T) {
+	owned, err := NewEtcdResolver([]string{"127.0.0.1:1"}, "/services/api", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &balancer{conf: BalancerConfig{Resolver: owned}, cancel: func() {}}
+	b.close()
+	b.close()
+	if owned.Client.Ctx().Err() == nil {
+		t.Fatal("the etcd client created by NewEtcdResolver should be closed")
+	}
+
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{"127.0.0.1:1"}, DialTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	if err := (&EtcdResolver{Client: cli, Prefix: "/services/api"}).Close(); err != nil || cli.Ctx().Err() != nil {
+		t.Fatalf("a caller-supplied client must stay open: %v", err)
+	}
+}
@@ -0,0 +1,374 @@
+package ahttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/small-ek/antgo/os/alog"
+	"go.uber.org/zap"
+)
+
+// BreakerState 熔断器状态 / BreakerState is the state of a circuit breaker
+type BreakerState string
+
+const (
+	StateClosed   BreakerState = "closed"
+	StateOpen     BreakerState = "open"
+	StateHalfOpen BreakerState = "half-open"
+)
+
+var (
+	// ErrCircuitOpen 熔断器打开，请求被拒绝 / The circuit is open and the call was rejected
+	ErrCircuitOpen = errors.New("ahttp: circuit breaker is open")
+	// ErrBulkheadFull 并发数已满，请求被拒绝 / The bulkhead is full and the call was rejected
+	ErrBulkheadFull = errors.New("ahttp: bulkhead is full")
+)
+
+// RejectedError 被熔断或隔离拒绝的请求错误，可用 errors.Is 判断 ErrCircuitOpen / ErrBulkheadFull
+// RejectedError is returned for calls rejected by the breaker or bulkhead; match it with errors.Is
+type RejectedError struct {
+	Key   string       // 熔断键（主机或接口） / Breaker key (host or endpoint)
+	State BreakerState // 拒绝时的状态 / State at rejection time
+	Err   error        // ErrCircuitOpen 或 ErrBulkheadFull / ErrCircuitOpen or ErrBulkheadFull
+}
+
+// Error 实现 error 接口 / Error implements error
+func (e *RejectedError) Error() string {
+	return e.Err.Error() + ": " + e.Key
+}
+
+// Unwrap 返回原因 / Unwrap returns the cause
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// BreakerConfig 熔断与隔离配置 / BreakerConfig configures the circuit breaker and bulkhead
+type BreakerConfig struct {
+	PerEndpoint      bool                                      // 按 主机+路径 熔断，默认按主机；空闲的熔断器按 IdleTimeout 回收 / Key by host and path; idle circuits are evicted after IdleTimeout
+	KeyFunc          func(req *http.Request) string            // 自定义熔断键 / Custom breaker key
+	Window           time.Duration                             // 统计窗口，默认 10s / Statistics window, defaults to 10s
+	MinRequests      int                                       // 窗口内触发熔断的最少请求数，默认 20 / Min requests in a window before tripping
+	ErrorRate        float64                                   // 错误率阈值，默认 0.5 / Error rate threshold, defaults to 0.5
+	SlowCallDuration time.Duration                             // 慢调用阈值，0 为不统计 / Slow call threshold, 0 disables it
+	SlowCallRate     float64                                   // 慢调用率阈值，默认 0.5 / Slow call rate threshold, defaults to 0.5
+	OpenTimeout      time.Duration                             // 打开后进入半开的等待时间，默认 30s / Time before an open circuit goes half-open
+	HalfOpenRequests int                                       // 半开状态允许的探测请求数，全部成功后关闭，默认 5 / Probes allowed when half-open
+	MaxConcurrency   int                                       // 每个键的最大并发数，0 为不限 / Max concurrent calls per key, 0 is unlimited
+	IdleTimeout      time.Duration                             // 关闭状态且无请求的熔断器保留时间，默认 10m / Idle closed circuits are dropped after this, defaults to 10m
+	IsFailure        func(resp *http.Response, err error) bool // 失败判断，默认网络错误或 5xx / Failure check, defaults to errors and 5xx
+}
+
+// BreakerStats 熔断器状态快照，用于健康检查 / BreakerStats is a snapshot for health reports
+type BreakerStats struct {
+	Key      string       `json:"key"`
+	State    BreakerState `json:"state"`
+	Requests int          `json:"requests"`  // 当前窗口请求数 / Requests in the current window
+	Failures int          `json:"failures"`  // 当前窗口失败数 / Failures in the current window
+	Slow     int          `json:"slow"`      // 当前窗口慢调用数 / Slow calls in the current window
+	InFlight int          `json:"in_flight"` // 进行中的请求数 / Calls in flight
+	OpenedAt time.Time    `json:"opened_at,omitempty"`
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *BreakerConfig) setDefaults() {
+	if cfg.KeyFunc == nil {
+		if cfg.PerEndpoint {
+			cfg.KeyFunc = func(req *http.Request) string { return req.URL.Host + req.URL.Path }
+		} else {
+			cfg.KeyFunc = func(req *http.Request) string { return req.URL.Host }
+		}
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 20
+	}
+	if cfg.ErrorRate <= 0 {
+		cfg.ErrorRate = 0.5
+	}
+	if cfg.SlowCallRate <= 0 {
+		cfg.SlowCallRate = 0.5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 5
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 10 * time.Minute
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode >= http.StatusInternalServerError
+		}
+	}
+}
+
+// breakerTransport 在传输层执行熔断与隔离。熔断键可能来自调用方或不断变化的解析结果，
+// 因此空闲超过 IdleTimeout 的关闭状态熔断器会被回收，避免 circuits 无限增长。
+//
+// breakerTransport enforces the breaker and bulkhead at the transport. Keys may come from callers or
+// churning resolvers, so closed circuits idle for longer than IdleTimeout are evicted to keep circuits bounded.
+type breakerTransport struct {
+	conf      BreakerConfig
+	next      http.RoundTripper
+	mu        sync.Mutex
+	circuits  map[string]*circuit
+	lastSweep time.Time
+}
+
+// newBreakerTransport 创建熔断传输层 / newBreakerTransport creates the breaker transport
+func newBreakerTransport(conf BreakerConfig) *breakerTransport {
+	conf.setDefaults()
+	return &breakerTransport{conf: conf, circuits: make(map[string]*circuit)}
+}
+
+// circuit 单个键的熔断状态 / circuit holds the state of one key
+type circuit struct {
+	key         string
+	mu          sync.Mutex
+	state       BreakerState
+	generation  uint64
+	windowStart time.Time
+	requests    int
+	failures    int
+	slow        int
+	openedAt    time.Time
+	probes      int
+	successes   int
+	sem         chan struct{}
+	lastUsed    time.Time // 最近一次取用时间，由 breakerTransport.mu 保护 / Last lookup, guarded by breakerTransport.mu
+}
+
+// get 获取或创建键对应的熔断器 / get returns the circuit of a key, creating it on demand
+func (t *breakerTransport) get(key string) *circuit {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if now.Sub(t.lastSweep) >= t.conf.IdleTimeout {
+		t.evictIdle(now)
+	}
+	c, ok := t.circuits[key]
+	if !ok {
+		c = &circuit{key: key, state: StateClosed, windowStart: now}
+		if t.conf.MaxConcurrency > 0 {
+			c.sem = make(chan struct{}, t.conf.MaxConcurrency)
+		}
+		t.circuits[key] = c
+	}
+	c.lastUsed = now
+	return c
+}
+
+// evictIdle 回收空闲的关闭状态熔断器，打开或半开的熔断器保留状态，调用方需持有 t.mu
+// evictIdle drops idle closed circuits while open and half-open ones keep their state; the caller holds t.mu
+func (t *breakerTransport) evictIdle(now time.Time) {
+	t.lastSweep = now
+	for key, c := range t.circuits {
+		if now.Sub(c.lastUsed) < t.conf.IdleTimeout || len(c.sem) > 0 {
+			continue
+		}
+		if c.currentState() == StateClosed {
+			delete(t.circuits, key)
+		}
+	}
+}
+
+// RoundTrip 执行请求 / RoundTrip executes the request
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := t.get(t.conf.KeyFunc(req))
+
+	generation, err := c.allow(&t.conf)
+	if err != nil {
+		return nil, err
+	}
+	if c.sem != nil {
+		select {
+		case c.sem <- struct{}{}:
+		default:
+			c.cancelProbe(generation)
+			return nil, &RejectedError{Key: c.key, State: c.currentState(), Err: ErrBulkheadFull}
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	latency := time.Since(start)
+	if errors.Is(req.Context().Err(), context.Canceled) {
+		// 调用方主动取消不代表下游故障，不计入统计；超时仍按失败统计
+		// A caller cancelling is not a downstream fault and is not counted; deadlines still count as failures
+		c.cancelProbe(generation)
+	} else {
+		c.record(&t.conf, generation, t.conf.IsFailure(resp, err), t.conf.SlowCallDuration > 0 && latency >= t.conf.SlowCallDuration)
+	}
+
+	if c.sem != nil {
+		// 并发数在响应体关闭时释放，流式响应同样受限 / The slot is released when the body closes, covering streamed responses
+		if resp == nil || resp.Body == nil {
+			<-c.sem
+		} else {
+			resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: func() { <-c.sem }}
+		}
+	}
+	return resp, err
+}
+
+// allow 判断是否放行，返回当前代数 / allow admits the call and returns the current generation
+func (c *circuit) allow(conf *BreakerConfig) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == StateOpen {
+		if time.Since(c.openedAt) < conf.OpenTimeout {
+			return 0, &RejectedError{Key: c.key, State: StateOpen, Err: ErrCircuitOpen}
+		}
+		c.transition(StateHalfOpen)
+	}
+	if c.state == StateHalfOpen {
+		if c.probes >= conf.HalfOpenRequests {
+			return 0, &RejectedError{Key: c.key, State: StateHalfOpen, Err: ErrCircuitOpen}
+		}
+		c.probes++
+	}
+	return c.generation, nil
+}
+
+// cancelProbe 隔离拒绝或调用方取消时归还半开探测名额 / cancelProbe returns a half-open probe slot after a bulkhead rejection or cancellation
+func (c *circuit) cancelProbe(generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == StateHalfOpen && c.generation == generation && c.probes > 0 {
+		c.probes--
+	}
+}
+
+// record 记录调用结果并按阈值切换状态 / record stores the outcome and trips or resets the circuit
+func (c *circuit) record(conf *BreakerConfig, generation uint64, failed, slow bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 忽略状态切换前发出的请求 / Ignore calls admitted before the last transition
+	if generation != c.generation {
+		return
+	}
+
+	switch c.state {
+	case StateHalfOpen:
+		if failed || slow {
+			c.transition(StateOpen)
+			return
+		}
+		c.successes++
+		if c.successes >= conf.HalfOpenRequests {
+			c.transition(StateClosed)
+		}
+	case StateClosed:
+		if now := time.Now(); now.Sub(c.windowStart) >= conf.Window {
+			c.windowStart, c.requests, c.failures, c.slow = now, 0, 0, 0
+		}
+		c.requests++
+		if failed {
+			c.failures++
+		}
+		if slow {
+			c.slow++
+		}
+		if c.requests < conf.MinRequests {
+			return
+		}
+		total := float64(c.requests)
+		if float64(c.failures)/total >= conf.ErrorRate || (conf.SlowCallDuration > 0 && float64(c.slow)/total >= conf.SlowCallRate) {
+			c.transition(StateOpen)
+		}
+	}
+}
+
+// transition 切换状态并记录日志，调用方需持有锁 / transition switches state and logs it; the caller holds the lock
+func (c *circuit) transition(to BreakerState) {
+	from := c.state
+	if alog.Write != nil {
+		alog.Write.Warn("HTTP circuit breaker state changed",
+			zap.String("key", c.key),
+			zap.String("from", string(from)),
+			zap.String("to", string(to)),
+			zap.Int("requests", c.requests),
+			zap.Int("failures", c.failures),
+			zap.Int("slow", c.slow),
+		)
+	}
+
+	c.state = to
+	c.generation++
+	c.probes, c.successes = 0, 0
+	c.windowStart, c.requests, c.failures, c.slow = time.Now(), 0, 0, 0
+	if to == StateOpen {
+		c.openedAt = time.Now()
+	}
+}
+
+// currentState 当前状态 / currentState returns the current state
+func (c *circuit) currentState() BreakerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// stats 返回全部熔断器的快照 / stats returns a snapshot of every circuit
+func (t *breakerTransport) stats() []BreakerStats {
+	t.mu.Lock()
+	circuits := make([]*circuit, 0, len(t.circuits))
+	for _, c := range t.circuits {
+		circuits = append(circuits, c)
+	}
+	t.mu.Unlock()
+
+	result := make([]BreakerStats, 0, len(circuits))
+	for _, c := range circuits {
+		c.mu.Lock()
+		s := BreakerStats{
+			Key:      c.key,
+			State:    c.state,
+			Requests: c.requests,
+			Failures: c.failures,
+			Slow:     c.slow,
+			InFlight: len(c.sem),
+		}
+		if c.state != StateClosed {
+			s.OpenedAt = c.openedAt
+		}
+		c.mu.Unlock()
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// releaseOnClose 关闭响应体时释放并发名额 / releaseOnClose releases the bulkhead slot when the body closes
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// Close 关闭响应体 / Close closes the body
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// Write 写入协议升级（101）后的连接，使 WebSocket 可经由该传输转发
+// Write writes to the connection after a protocol upgrade (101) so WebSocket can pass through the transport
+func (r *releaseOnClose) Write(p []byte) (int, error) {
+	if w, ok := r.ReadCloser.(io.Writer); ok {
+		return w.Write(p)
+	}
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,126 @@
+package ahttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestCircuitBreaker 测试打开、半开与关闭的状态切换 / TestCircuitBreaker covers open, half-open and closed transitions
+func TestCircuitBreaker(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	bt := newBreakerTransport(BreakerConfig{MinRequests: 4, ErrorRate: 0.5, OpenTimeout: 50 * time.Millisecond, HalfOpenRequests: 2})
+	bt.next = http.DefaultTransport
+	client := &http.Client{Transport: bt}
+	get := func() error {
+		resp, err := client.Get(ts.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := get(); err != nil {
+			t.Fatalf("request %d should reach the server: %v", i, err)
+		}
+	}
+	err := get()
+	var rejected *RejectedError
+	if !errors.Is(err, ErrCircuitOpen) || !errors.As(err, &rejected) || rejected.State != StateOpen {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if stats := bt.stats(); len(stats) != 1 || stats[0].State != StateOpen {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	failing.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := get(); err != nil {
+			t.Fatalf("half-open probe %d failed: %v", i, err)
+		}
+	}
+	if stats := bt.stats(); stats[0].State != StateClosed {
+		t.Errorf("expected closed after successful probes, got %s", stats[0].State)
+	}
+}
+
+// TestBreakerIgnoresCancel 测试调用方取消不计为失败 / TestBreakerIgnoresCancel checks caller cancellations are not failures
+func TestBreakerIgnoresCancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	bt := newBreakerTransport(BreakerConfig{MinRequests: 1, ErrorRate: 0.5})
+	bt.next = http.DefaultTransport
+	client := &http.Client{Transport: bt}
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+		time.AfterFunc(10*time.Millisecond, cancel)
+		if _, err := client.Do(req); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	}
+	if stats := bt.stats(); len(stats) != 1 || stats[0].State != StateClosed || stats[0].Failures != 0 {
+		t.Errorf("cancellations should not trip the breaker, got %+v", stats)
+	}
+}
+
+// TestBulkhead 测试并发隔离与响应体关闭后释放 / TestBulkhead covers the concurrency limit and release on body close
+func TestBulkhead(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+
+	bt := newBreakerTransport(BreakerConfig{MaxConcurrency: 1})
+	bt.next = http.DefaultTransport
+	client := &http.Client{Transport: bt}
+
+	first, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(ts.URL); !errors.Is(err, ErrBulkheadFull) {
+		t.Fatalf("expected ErrBulkheadFull while the first body is open, got %v", err)
+	}
+	first.Body.Close()
+
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("slot should be released after close: %v", err)
+	}
+	resp.Body.Close()
+}
+
+// TestBreakerEvictsIdle 测试回收空闲的关闭状态熔断器 / TestBreakerEvictsIdle checks idle closed circuits are evicted
+func TestBreakerEvictsIdle(t *testing.T) {
+	bt := newBreakerTransport(BreakerConfig{PerEndpoint: true, IdleTimeout: 20 * time.Millisecond})
+	bt.get("a.com/one")
+	bt.get("a.com/two").transition(StateOpen)
+
+	time.Sleep(30 * time.Millisecond)
+	bt.get("a.com/three")
+	stats := bt.stats()
+	if len(stats) != 2 || stats[0].Key != "a.com/three" || stats[1].Key != "a.com/two" || stats[1].State != StateOpen {
+		t.Fatalf("expected the idle closed circuit to be evicted, got %+v", stats)
+	}
+}
@@ -0,0 +1,215 @@
+package ahttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ClientOptions 命名客户端的配置文件结构，对应 http_clients.<name>，时间使用 "5s" 形式的字符串
+// ClientOptions is the file schema of a named client under http_clients.<name>; durations are strings such as "5s"
+type ClientOptions struct {
+	BaseURL             string            `json:"base_url"`
+	Timeout             string            `json:"timeout"`
+	DialTimeout         string            `json:"dial_timeout"`
+	TLSHandshakeTimeout string            `json:"tls_handshake_timeout"`
+	IdleConnTimeout     string            `json:"idle_conn_timeout"`
+	MaxIdleConns        int               `json:"max_idle_conns"`
+	MaxConnsPerHost     int               `json:"max_conns_per_host"`
+	RetryAttempts       *int              `json:"retry_attempts"` // 0 为不重试，未设置时使用默认值 / 0 disables retries, unset keeps the default
+	RetryWait           string            `json:"retry_wait"`
+	RetryMaxWait        string            `json:"retry_max_wait"`
+	Headers             map[string]string `json:"headers"`
+	Auth                AuthConfig        `json:"auth"`
+	ProxyURL            string            `json:"proxy_url"`
+	ProxyUser           string            `json:"proxy_user"`
+	ProxyPass           string            `json:"proxy_pass"`
+	TLS                 TLSOptions        `json:"tls"`
+	BaseURLs            []string          `json:"base_urls"` // 多个地址时启用负载均衡 / Several base URLs enable load balancing
+	Balancer            *BalancerOptions  `json:"balancer"`
+}
+
+// BalancerOptions 命名客户端的负载均衡配置 / BalancerOptions configures load balancing for a named client
+type BalancerOptions struct {
+	Strategy            string       `json:"strategy"`  // round_robin、weighted 或 least_inflight
+	Endpoints           []Endpoint   `json:"endpoints"` // 带权重的节点，与 base_urls 合并 / Weighted endpoints, merged with base_urls
+	HealthCheckPath     string       `json:"health_check_path"`
+	HealthCheckInterval string       `json:"health_check_interval"`
+	HealthCheckTimeout  string       `json:"health_check_timeout"`
+	EjectAfter          int          `json:"eject_after"`
+	EjectDuration       string       `json:"eject_duration"`
+	Etcd                *EtcdOptions `json:"etcd"` // 从 etcd 解析节点 / Resolve endpoints from etcd
+}
+
+// EtcdOptions etcd 节点解析配置 / EtcdOptions configures etcd endpoint resolution
+type EtcdOptions struct {
+	Hosts    []string `json:"hosts"`
+	Prefix   string   `json:"prefix"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+}
+
+// TLSOptions 命名客户端的 TLS 配置 / TLSOptions configures TLS for a named client
+type TLSOptions struct {
+	InsecureSkipVerify *bool  `json:"insecure_skip_verify"` // 默认校验证书，显式设为 true 才跳过 / Certificates are verified unless explicitly set to true
+	CertFile           string `json:"cert_file"`            // 客户端证书 / Client certificate
+	KeyFile            string `json:"key_file"`             // 客户端私钥 / Client key
+	CAFile             string `json:"ca_file"`              // 信任的 CA 证书 / Trusted CA bundle
+	ServerName         string `json:"server_name"`          // SNI 主机名 / SNI server name
+}
+
+// Config 转换为客户端配置，未设置的项使用 DefaultConfig，但默认校验 TLS 证书
+// Config converts the options; unset values come from DefaultConfig, except that TLS certificates are verified by default
+func (o ClientOptions) Config() (*Config, error) {
+	conf := DefaultConfig()
+	conf.BaseURL = o.BaseURL
+	conf.Headers = o.Headers
+	conf.Auth = o.Auth
+	conf.ProxyURL = o.ProxyURL
+	conf.ProxyUser = o.ProxyUser
+	conf.ProxyPass = o.ProxyPass
+	if o.MaxIdleConns > 0 {
+		conf.MaxIdleConnections = o.MaxIdleConns
+	}
+	if o.MaxConnsPerHost > 0 {
+		conf.MaxConnectionsPerHost = o.MaxConnsPerHost
+	}
+	if o.RetryAttempts != nil {
+		conf.RetryAttempts = *o.RetryAttempts
+	}
+	// 命名客户端默认校验证书，不沿用 DefaultConfig 的跳过校验 / Named clients verify certificates instead of inheriting DefaultConfig's skip
+	conf.InsecureSkipVerify = o.TLS.InsecureSkipVerify != nil && *o.TLS.InsecureSkipVerify
+
+	durations := []durationOption{
+		{"timeout", o.Timeout, &conf.Timeout},
+		{"dial_timeout", o.DialTimeout, &conf.DialerTimeout},
+		{"tls_handshake_timeout", o.TLSHandshakeTimeout, &conf.TLSHandshakeTimeout},
+		{"idle_conn_timeout", o.IdleConnTimeout, &conf.IdleConnectionTimeout},
+		{"retry_wait", o.RetryWait, &conf.RetryWaitTime},
+		{"retry_max_wait", o.RetryMaxWait, &conf.RetryMaxWaitTime},
+	}
+	if len(o.BaseURLs) > 1 || o.Balancer != nil {
+		conf.Balancer = &BalancerConfig{}
+		for _, u := range o.BaseURLs {
+			conf.Balancer.Endpoints = append(conf.Balancer.Endpoints, Endpoint{URL: u, Weight: 1})
+		}
+		if b := o.Balancer; b != nil {
+			conf.Balancer.Endpoints = append(conf.Balancer.Endpoints, b.Endpoints...)
+			conf.Balancer.Strategy = b.Strategy
+			conf.Balancer.HealthCheckPath = b.HealthCheckPath
+			conf.Balancer.EjectAfter = b.EjectAfter
+			durations = append(durations,
+				durationOption{"balancer.health_check_interval", b.HealthCheckInterval, &conf.Balancer.HealthCheckInterval},
+				durationOption{"balancer.health_check_timeout", b.HealthCheckTimeout, &conf.Balancer.HealthCheckTimeout},
+				durationOption{"balancer.eject_duration", b.EjectDuration, &conf.Balancer.EjectDuration},
+			)
+		}
+	} else if len(o.BaseURLs) == 1 && conf.BaseURL == "" {
+		conf.BaseURL = o.BaseURLs[0]
+	}
+
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", d.name, d.value, err)
+		}
+		*d.dst = v
+	}
+	return conf, nil
+}
+
+// durationOption 待解析的时间配置 / durationOption is a duration string to parse into dst
+type durationOption struct {
+	name  string
+	value string
+	dst   *time.Duration
+}
+
+// Build 根据配置创建独立的客户端 / Build creates an independent client from the options
+func (o ClientOptions) Build() (*HttpClient, error) {
+	conf, err := o.Config()
+	if err != nil {
+		return nil, err
+	}
+	if o.Balancer != nil && o.Balancer.Etcd != nil {
+		resolver, err := NewEtcdResolver(o.Balancer.Etcd.Hosts, o.Balancer.Etcd.Prefix, o.Balancer.Etcd.Username, o.Balancer.Etcd.Password)
+		if err != nil {
+			return nil, fmt.Errorf("connect etcd: %w", err)
+		}
+		conf.Balancer.Resolver = resolver
+	}
+	client := NewClient(conf)
+
+	if o.TLS.CertFile != "" || o.TLS.KeyFile != "" {
+		if err := client.SetTLSClientCert(o.TLS.CertFile, o.TLS.KeyFile); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+	}
+	if o.TLS.CAFile != "" || o.TLS.ServerName != "" {
+		if err := client.setRootCA(o.TLS.CAFile, o.TLS.ServerName); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// setRootCA 设置信任的 CA 与 SNI 主机名 / setRootCA sets the trusted CA bundle and SNI server name
+func (h *HttpClient) setRootCA(caFile, serverName string) error {
+	var pool *x509.CertPool
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("read CA file: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("no certificates found in CA file " + caFile)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	tlsConfig := h.httpTransport.TLSClientConfig.Clone()
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if pool != nil {
+		tlsConfig.RootCAs = pool
+	}
+	if serverName != "" {
+		tlsConfig.ServerName = serverName
+	}
+	h.httpTransport.TLSClientConfig = tlsConfig
+	h.applyTransport()
+	return nil
+}
+
+// clients 命名客户端注册表 / clients is the registry of named clients
+var (
+	clients   = make(map[string]*HttpClient)
+	clientsMu sync.RWMutex
+)
+
+// Register 注册命名客户端，同名时覆盖 / Register stores a named client, replacing any existing one
+func Register(name string, client *HttpClient) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	clients[name] = client
+}
+
+// Get 获取命名客户端 / Get returns a named client
+func Get(name string) (*HttpClient, bool) {
+	clientsMu.RLock()
+	defer clientsMu.RUnlock()
+	client, ok := clients[name]
+	return client, ok
+}
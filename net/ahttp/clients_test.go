@@ -0,0 +1,105 @@
+package ahttp
+
+import (
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestNamedClients 测试命名客户端独立配置基础地址、请求头与认证 / TestNamedClients covers independent base URLs, headers and auth
+func TestNamedClients(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Path", r.URL.Path)
+		w.Header().Set("X-Auth", r.Header.Get("Authorization"))
+		w.Header().Set("X-Key", r.Header.Get("X-API-Key")+r.URL.Query().Get("api_key"))
+		w.Header().Set("X-Tenant", r.Header.Get("X-Tenant"))
+	}))
+	defer ts.Close()
+
+	zero := 0
+	payments, err := ClientOptions{
+		BaseURL:       ts.URL + "/payments",
+		Timeout:       "2s",
+		RetryAttempts: &zero,
+		Headers:       map[string]string{"X-Tenant": "antgo"},
+		Auth:          AuthConfig{Type: "bearer", Token: "secret"},
+	}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	search, err := ClientOptions{
+		BaseURL: ts.URL + "/search",
+		Auth:    AuthConfig{Type: "api_key", Key: "k1", Query: "api_key"},
+	}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Register("payments", payments)
+	Register("search", search)
+
+	got, ok := Get("payments")
+	if !ok || got != payments {
+		t.Fatal("payments client not registered")
+	}
+	resp, err := got.Request().Get("/charges")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header().Get("X-Path") != "/payments/charges" || resp.Header().Get("X-Auth") != "Bearer secret" || resp.Header().Get("X-Tenant") != "antgo" {
+		t.Fatalf("unexpected payments request: %v", resp.Header())
+	}
+
+	resp, err = search.Request().Get("/q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header().Get("X-Path") != "/search/q" || resp.Header().Get("X-Auth") != "" || resp.Header().Get("X-Key") != "k1" {
+		t.Fatalf("unexpected search request: %v", resp.Header())
+	}
+
+	if _, err := (ClientOptions{Timeout: "soon"}).Build(); err == nil {
+		t.Fatal("expected an invalid duration error")
+	}
+	if _, ok := Get("missing"); ok {
+		t.Fatal("unexpected client for an unknown name")
+	}
+}
+
+// TestClientOptionsTLS 测试命名客户端默认校验证书 / TestClientOptionsTLS checks named clients verify certificates by default
+func TestClientOptionsTLS(t *testing.T) {
+	insecure := true
+	cases := []struct {
+		name string
+		tls  TLSOptions
+		skip bool
+	}{
+		{"default", TLSOptions{}, false},
+		{"server name", TLSOptions{ServerName: "api.internal"}, false},
+		{"ca file", TLSOptions{CAFile: "ca.pem"}, false},
+		{"explicit", TLSOptions{ServerName: "api.internal", InsecureSkipVerify: &insecure}, true},
+	}
+	for _, tc := range cases {
+		conf, err := ClientOptions{TLS: tc.tls}.Config()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conf.InsecureSkipVerify != tc.skip {
+			t.Errorf("%s: expected InsecureSkipVerify %v, got %v", tc.name, tc.skip, conf.InsecureSkipVerify)
+		}
+	}
+
+	// 未配置 TLS 的条目拒绝自签名证书 / An entry without a TLS section rejects a self-signed certificate
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	zero := 0
+	client, err := ClientOptions{BaseURL: ts.URL, RetryAttempts: &zero}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unknown x509.UnknownAuthorityError
+	if _, err := client.Request().Get("/"); !errors.As(err, &unknown) {
+		t.Fatalf("expected a certificate error, got %v", err)
+	}
+}
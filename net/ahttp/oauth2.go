@@ -0,0 +1,390 @@
+package ahttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/small-ek/antgo/db/aredis"
+	"golang.org/x/sync/singleflight"
+)
+
+// OAuth2 授权类型 / OAuth2 grant types
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantPassword          = "password"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// Token OAuth2 访问令牌 / Token is an OAuth2 access token
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"` // 零值表示不过期 / Zero means it never expires
+}
+
+// valid 判断令牌在 delta 之后是否仍有效 / valid reports whether the token is still usable after delta
+func (t *Token) valid(delta time.Duration) bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || time.Now().Add(delta).Before(t.Expiry))
+}
+
+// TokenCache 跨实例共享的令牌缓存 / TokenCache shares tokens across instances
+type TokenCache interface {
+	Get(key string) (*Token, bool)
+	Set(key string, token *Token, ttl time.Duration) error
+	Delete(key string)
+}
+
+// RedisTokenCache 基于 aredis 的令牌缓存 / RedisTokenCache stores tokens in Redis through aredis
+type RedisTokenCache struct {
+	Client *aredis.ClientRedis
+}
+
+// Get 读取令牌 / Get loads a token
+func (c RedisTokenCache) Get(key string) (*Token, bool) {
+	value := c.Client.Get(key)
+	if value == "" {
+		return nil, false
+	}
+	var token Token
+	if err := json.Unmarshal([]byte(value), &token); err != nil {
+		return nil, false
+	}
+	return &token, true
+}
+
+// Set 写入令牌，ttl 为 0 时不过期 / Set stores a token, a zero ttl never expires
+func (c RedisTokenCache) Set(key string, token *Token, ttl time.Duration) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return c.Client.Set(key, string(data), ttl.Milliseconds())
+}
+
+// Delete 删除令牌 / Delete removes a token
+func (c RedisTokenCache) Delete(key string) {
+	_, _ = c.Client.Remove(key)
+}
+
+// OAuth2Config OAuth2 令牌获取配置 / OAuth2Config configures how tokens are obtained
+type OAuth2Config struct {
+	TokenURL     string            `json:"token_url"`
+	ClientID     string            `json:"client_id"`
+	ClientSecret string            `json:"client_secret"`
+	GrantType    string            `json:"grant_type"` // 默认 client_credentials / Defaults to client_credentials
+	Username     string            `json:"username"`   // password 授权使用 / Used by the password grant
+	Password     string            `json:"password"`
+	RefreshToken string            `json:"refresh_token"` // refresh_token 授权的初始刷新令牌 / Initial token for the refresh_token grant
+	Scopes       []string          `json:"scopes"`
+	Params       map[string]string `json:"params"`         // 额外的表单参数，如 audience / Extra form parameters such as audience
+	AuthInParams bool              `json:"auth_in_params"` // 客户端凭证放在表单中而不是 Basic 认证头 / Send client credentials in the form instead of Basic auth
+	Redis        string            `json:"redis"`          // 共享缓存使用的 aredis 连接名 / aredis connection used as the shared cache
+	CacheKey     string            `json:"cache_key"`      // 共享缓存键，默认按令牌地址、客户端与范围生成 / Shared cache key, derived from the token URL, client and scopes by default
+	ExpiryDelta  time.Duration     `json:"-"`              // 提前刷新的时间，默认 30s / Refresh this long before expiry, defaults to 30s
+	Cache        TokenCache        `json:"-"`              // 共享缓存，优先于 Redis / Shared cache, takes precedence over Redis
+	HTTPClient   *http.Client      `json:"-"`              // 请求令牌使用的客户端 / Client used for token requests
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *OAuth2Config) setDefaults() {
+	if cfg.GrantType == "" {
+		cfg.GrantType = GrantClientCredentials
+	}
+	if cfg.ExpiryDelta <= 0 {
+		cfg.ExpiryDelta = 30 * time.Second
+	}
+	if cfg.Cache == nil && cfg.Redis != "" {
+		if client := aredis.Client[cfg.Redis]; client != nil {
+			cfg.Cache = RedisTokenCache{Client: client}
+		}
+	}
+	if cfg.CacheKey == "" {
+		sum := sha256.Sum256([]byte(strings.Join([]string{cfg.TokenURL, cfg.ClientID, cfg.GrantType, cfg.Username, strings.Join(cfg.Scopes, " ")}, "\n")))
+		cfg.CacheKey = "ahttp:oauth2:" + hex.EncodeToString(sum[:16])
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+}
+
+// TokenError 令牌接口返回的错误 / TokenError is an error response from the token endpoint
+type TokenError struct {
+	Status      int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// Error 实现 error 接口 / Error implements error
+func (e *TokenError) Error() string {
+	msg := fmt.Sprintf("ahttp: oauth2 token request failed with status %d", e.Status)
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Description != "" {
+		msg += " (" + e.Description + ")"
+	}
+	return msg
+}
+
+// TokenSource 获取并缓存令牌，临近过期前刷新，并发刷新合并为一次请求
+// TokenSource fetches and caches tokens, refreshing shortly before expiry with a single in-flight request
+type TokenSource struct {
+	conf  OAuth2Config
+	group singleflight.Group
+	mu    sync.RWMutex
+	token *Token
+}
+
+// NewTokenSource 创建令牌源 / NewTokenSource creates a token source
+func NewTokenSource(cfg OAuth2Config) *TokenSource {
+	cfg.setDefaults()
+	ts := &TokenSource{conf: cfg}
+	if cfg.RefreshToken != "" {
+		ts.token = &Token{RefreshToken: cfg.RefreshToken}
+	}
+	return ts
+}
+
+// Token 返回有效令牌 / Token returns a valid token
+func (ts *TokenSource) Token(ctx context.Context) (*Token, error) {
+	ts.mu.RLock()
+	token := ts.token
+	ts.mu.RUnlock()
+	if token.valid(ts.conf.ExpiryDelta) {
+		return token, nil
+	}
+
+	// 令牌请求不随单个调用方取消，避免一个调用方取消导致其他等待者失败
+	// The fetch is detached from any single caller so one cancellation does not fail the other waiters
+	ch := ts.group.DoChan("token", func() (any, error) {
+		return ts.refresh(context.WithoutCancel(ctx))
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*Token), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Invalidate 丢弃被服务端拒绝的令牌 / Invalidate drops a token rejected by the server
+func (ts *TokenSource) Invalidate(accessToken string) {
+	ts.mu.Lock()
+	if ts.token != nil && ts.token.AccessToken == accessToken {
+		// 保留刷新令牌 / Keep the refresh token
+		ts.token = &Token{RefreshToken: ts.token.RefreshToken}
+	}
+	ts.mu.Unlock()
+
+	if ts.conf.Cache != nil {
+		if shared, ok := ts.conf.Cache.Get(ts.conf.CacheKey); ok && shared.AccessToken == accessToken {
+			ts.conf.Cache.Delete(ts.conf.CacheKey)
+		}
+	}
+}
+
+// refresh 依次尝试共享缓存、刷新令牌与配置的授权方式 / refresh tries the shared cache, the refresh token, then the configured grant
+func (ts *TokenSource) refresh(ctx context.Context) (*Token, error) {
+	ts.mu.RLock()
+	current := ts.token
+	ts.mu.RUnlock()
+	if current.valid(ts.conf.ExpiryDelta) {
+		return current, nil
+	}
+
+	if ts.conf.Cache != nil {
+		if shared, ok := ts.conf.Cache.Get(ts.conf.CacheKey); ok && shared.valid(ts.conf.ExpiryDelta) {
+			ts.store(shared, false)
+			return shared, nil
+		}
+	}
+
+	var (
+		token *Token
+		err   error
+	)
+	if current != nil && current.RefreshToken != "" {
+		token, err = ts.fetch(ctx, url.Values{"grant_type": {GrantRefreshToken}, "refresh_token": {current.RefreshToken}})
+		if token != nil && token.RefreshToken == "" {
+			token.RefreshToken = current.RefreshToken
+		}
+	}
+	if token == nil && ts.conf.GrantType != GrantRefreshToken {
+		form := url.Values{"grant_type": {ts.conf.GrantType}}
+		if ts.conf.GrantType == GrantPassword {
+			form.Set("username", ts.conf.Username)
+			form.Set("password", ts.conf.Password)
+		}
+		token, err = ts.fetch(ctx, form)
+	}
+	if token == nil {
+		if err == nil {
+			err = errors.New("ahttp: oauth2 refresh token is missing")
+		}
+		return nil, err
+	}
+
+	ts.store(token, true)
+	return token, nil
+}
+
+// store 保存令牌，share 为 true 时写入共享缓存 / store keeps the token and optionally writes the shared cache
+func (ts *TokenSource) store(token *Token, share bool) {
+	ts.mu.Lock()
+	ts.token = token
+	ts.mu.Unlock()
+
+	if share && ts.conf.Cache != nil {
+		var ttl time.Duration
+		if !token.Expiry.IsZero() {
+			ttl = time.Until(token.Expiry)
+		}
+		_ = ts.conf.Cache.Set(ts.conf.CacheKey, token, ttl)
+	}
+}
+
+// fetch 请求令牌接口 / fetch calls the token endpoint
+func (ts *TokenSource) fetch(ctx context.Context, form url.Values) (*Token, error) {
+	if len(ts.conf.Scopes) > 0 {
+		form.Set("scope", strings.Join(ts.conf.Scopes, " "))
+	}
+	for k, v := range ts.conf.Params {
+		form.Set(k, v)
+	}
+	if ts.conf.AuthInParams {
+		form.Set("client_id", ts.conf.ClientID)
+		form.Set("client_secret", ts.conf.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.conf.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !ts.conf.AuthInParams {
+		req.SetBasicAuth(url.QueryEscape(ts.conf.ClientID), url.QueryEscape(ts.conf.ClientSecret))
+	}
+
+	resp, err := ts.conf.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		tokenErr := &TokenError{Status: resp.StatusCode}
+		_ = json.Unmarshal(body, tokenErr)
+		return nil, tokenErr
+	}
+
+	var payload struct {
+		AccessToken  string      `json:"access_token"`
+		TokenType    string      `json:"token_type"`
+		RefreshToken string      `json:"refresh_token"`
+		ExpiresIn    json.Number `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("ahttp: decode oauth2 token: %w", err)
+	}
+	if payload.AccessToken == "" {
+		return nil, errors.New("ahttp: oauth2 token response has no access_token")
+	}
+
+	token := &Token{AccessToken: payload.AccessToken, TokenType: payload.TokenType, RefreshToken: payload.RefreshToken}
+	if seconds, err := payload.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		token.Expiry = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	return token, nil
+}
+
+// oauth2Transport 为请求附加令牌，收到 401 时刷新令牌并重试一次
+// oauth2Transport attaches the token and retries once with a fresh token on 401
+type oauth2Transport struct {
+	source *TokenSource
+	next   http.RoundTripper
+}
+
+// RoundTrip 执行请求 / RoundTrip executes the request
+func (t *oauth2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(withToken(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// 请求体无法重放时直接返回 401 / Return the 401 when the body cannot be replayed
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+	t.source.Invalidate(token.AccessToken)
+	fresh, err := t.source.Token(req.Context())
+	if err != nil || fresh.AccessToken == token.AccessToken {
+		return resp, nil
+	}
+
+	retry := withToken(req, fresh)
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return t.next.RoundTrip(retry)
+}
+
+// withToken 复制请求并设置 Authorization / withToken clones the request with the Authorization header
+func withToken(req *http.Request, token *Token) *http.Request {
+	r := req.Clone(req.Context())
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	r.Header.Set("Authorization", tokenType+" "+token.AccessToken)
+	return r
+}
+
+// roundTripperFunc 函数形式的 RoundTripper / roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip 执行请求 / RoundTrip executes the request
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// SetOAuth2 为所有请求附加 OAuth2 令牌，令牌请求复用本客户端的传输层（代理、TLS、熔断）
+// SetOAuth2 attaches OAuth2 tokens to every request; token requests reuse this client's transport (proxy, TLS, breaker)
+func (h *HttpClient) SetOAuth2(cfg OAuth2Config) *HttpClient {
+	t := &oauth2Transport{}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) { return t.next.RoundTrip(req) }),
+			Timeout:   h.config.Timeout,
+		}
+	}
+	t.source = NewTokenSource(cfg)
+	h.oauth2 = t
+	h.applyTransport()
+	return h
+}
@@ -0,0 +1,113 @@
+package ahttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryTokenCache 内存共享缓存 / memoryTokenCache is an in-memory shared cache
+type memoryTokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]*Token
+}
+
+func (c *memoryTokenCache) Get(key string) (*Token, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tokens[key]
+	return t, ok
+}
+
+func (c *memoryTokenCache) Set(key string, token *Token, _ time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[key] = token
+	return nil
+}
+
+func (c *memoryTokenCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tokens, key)
+}
+
+// TestOAuth2 测试令牌缓存、并发合并、401 重试与共享缓存 / TestOAuth2 covers caching, singleflight, 401 retry and the shared cache
+func TestOAuth2(t *testing.T) {
+	var issued atomic.Int32
+	var revoked atomic.Value
+	revoked.Store("")
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "app" || secret != "s3cret" || r.FormValue("grant_type") != GrantClientCredentials || r.FormValue("scope") != "read write" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+		n := issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"t%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	defer tokenServer.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer "+revoked.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(r.Header.Get("Authorization") + "|" + r.FormValue("v")))
+	}))
+	defer api.Close()
+
+	cache := &memoryTokenCache{tokens: make(map[string]*Token)}
+	conf := DefaultConfig()
+	conf.RetryAttempts = 0
+	client := NewClient(conf).SetOAuth2(OAuth2Config{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "app",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"read", "write"},
+		Cache:        cache,
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Request().Get(api.URL)
+			if err != nil || resp.String() != "Bearer t1|" {
+				t.Errorf("unexpected response: %v %v", resp, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if issued.Load() != 1 {
+		t.Fatalf("expected one token request, got %d", issued.Load())
+	}
+
+	// 令牌被拒绝后刷新并重放请求体 / A rejected token is refreshed and the body replayed
+	revoked.Store("t1")
+	resp, err := client.Request().SetFormData(map[string]string{"v": "1"}).Post(api.URL)
+	if err != nil || resp.String() != "Bearer t2|1" {
+		t.Fatalf("unexpected response after 401: %v %v", resp, err)
+	}
+
+	// 另一个实例从共享缓存获取令牌 / Another instance picks the token from the shared cache
+	other := NewTokenSource(OAuth2Config{TokenURL: tokenServer.URL, ClientID: "app", ClientSecret: "s3cret", Scopes: []string{"read", "write"}, Cache: cache})
+	token, err := other.Token(context.Background())
+	if err != nil || token.AccessToken != "t2" || issued.Load() != 2 {
+		t.Fatalf("expected the shared token, got %v %v (issued %d)", token, err, issued.Load())
+	}
+
+	bad := NewTokenSource(OAuth2Config{TokenURL: tokenServer.URL, ClientID: "app", ClientSecret: "wrong"})
+	if _, err := bad.Token(context.Background()); err == nil || err.(*TokenError).Code != "invalid_client" {
+		t.Fatalf("expected invalid_client, got %v", err)
+	}
+}
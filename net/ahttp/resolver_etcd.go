@@ -0,0 +1,104 @@
+package ahttp
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/small-ek/antgo/os/alog"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// EtcdResolver 从 etcd 前缀下读取节点列表并监听变化。
+// 每个键对应一个节点，值为地址字符串或 JSON {"url": "...", "weight": 2}。
+//
+// EtcdResolver reads endpoints under an etcd prefix and watches it for changes.
+// Each key is one endpoint whose value is either a URL or JSON {"url": "...", "weight": 2}.
+type EtcdResolver struct {
+	Client *clientv3.Client
+	Prefix string
+	owned  bool // Client 由 NewEtcdResolver 创建，Close 时关闭 / Client was created by NewEtcdResolver and is closed by Close
+}
+
+// NewEtcdResolver 连接 etcd 并创建解析器 / NewEtcdResolver connects to etcd and creates a resolver
+func NewEtcdResolver(hosts []string, prefix, username, password string) (*EtcdResolver, error) {
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   hosts,
+		Username:    username,
+		Password:    password,
+		DialTimeout: 5 * time.Second,
+		Logger:      alog.Write,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &EtcdResolver{Client: cli, Prefix: prefix, owned: true}, nil
+}
+
+// Close 关闭 NewEtcdResolver 创建的 etcd 连接，外部传入的 Client 由调用方关闭
+// Close closes the etcd client created by NewEtcdResolver; a Client supplied by the caller is left open
+func (r *EtcdResolver) Close() error {
+	if !r.owned {
+		return nil
+	}
+	return r.Client.Close()
+}
+
+// Resolve 读取前缀下的全部节点 / Resolve reads every endpoint under the prefix
+func (r *EtcdResolver) Resolve(ctx context.Context) ([]Endpoint, error) {
+	resp, err := r.Client.Get(ctx, r.Prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]Endpoint, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		if e, ok := parseEndpoint(kv.Value); ok {
+			endpoints = append(endpoints, e)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].URL < endpoints[j].URL })
+	return endpoints, nil
+}
+
+// Watch 前缀下有变化时重新读取节点列表 / Watch re-reads the list whenever the prefix changes
+func (r *EtcdResolver) Watch(ctx context.Context, update func([]Endpoint)) {
+	for ctx.Err() == nil {
+		for resp := range r.Client.Watch(ctx, r.Prefix, clientv3.WithPrefix()) {
+			if err := resp.Err(); err != nil {
+				logBalancer("Watch upstream endpoints failed", zap.String("prefix", r.Prefix), zap.Error(err))
+				break
+			}
+			endpoints, err := r.Resolve(ctx)
+			if err != nil {
+				logBalancer("Resolve upstream endpoints failed", zap.String("prefix", r.Prefix), zap.Error(err))
+				continue
+			}
+			update(endpoints)
+		}
+
+		// 监听中断（如压缩或连接断开）后稍后重建 / Re-create the watch after it breaks, e.g. on compaction or disconnect
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// parseEndpoint 解析节点值 / parseEndpoint parses an endpoint value
+func parseEndpoint(value []byte) (Endpoint, bool) {
+	text := strings.TrimSpace(string(value))
+	if text == "" {
+		return Endpoint{}, false
+	}
+	if strings.HasPrefix(text, "{") {
+		var e Endpoint
+		if err := json.Unmarshal([]byte(text), &e); err != nil || e.URL == "" {
+			return Endpoint{}, false
+		}
+		return e, true
+	}
+	return Endpoint{URL: text, Weight: 1}, true
+}
@@ -0,0 +1,223 @@
+package ahttp
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// RetryPolicy 重试策略：指数退避（全抖动）、遵循 Retry-After、仅重试幂等方法
+// RetryPolicy retries with exponential backoff and full jitter, honors Retry-After and only retries idempotent methods
+type RetryPolicy struct {
+	MaxAttempts        int           // 最大重试次数（不含首次请求） / Max retries, excluding the first attempt
+	BaseDelay          time.Duration // 退避基准时间，默认 100ms / Backoff base, defaults to 100ms
+	MaxDelay           time.Duration // 单次等待上限，默认 10s / Cap of a single wait, defaults to 10s
+	StatusCodes        []int         // 需要重试的状态码，默认 408/429/500/502/503/504 / Retryable status codes
+	Methods            []string      // 可重试的方法，默认幂等方法 / Retryable methods, defaults to the idempotent ones
+	DisableNetworkErrs bool          // 不重试网络错误 / Do not retry network errors
+	Budget             time.Duration // 单个请求重试总耗时上限，0 为不限（仍受上下文截止时间约束） / Total retry time per request, 0 is unlimited
+}
+
+// RetryOptions 单个请求的重试选项，通过 WithRetry 放入请求上下文
+// RetryOptions are per-request retry options carried in the request context by WithRetry
+type RetryOptions struct {
+	Force       bool          // 非幂等方法也允许重试 / Allow retries for non-idempotent methods
+	Disable     bool          // 关闭重试 / Disable retries
+	MaxAttempts int           // 覆盖最大重试次数，不能超过策略值 / Override max retries, capped by the policy
+	Budget      time.Duration // 覆盖重试总耗时上限 / Override the retry time budget
+}
+
+type retryOptionsKey struct{}
+
+// retryStartKey 首次请求开始时间，用于计算重试预算 / retryStartKey holds the first attempt's start time for the budget
+type retryStartKey struct{}
+
+// WithRetry 为请求设置重试选项，配合 RequestWithContext 使用
+// WithRetry attaches per-request retry options, use together with RequestWithContext
+func WithRetry(ctx context.Context, opts RetryOptions) context.Context {
+	return context.WithValue(ctx, retryOptionsKey{}, opts)
+}
+
+var defaultRetryStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+var defaultRetryMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete,
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (p *RetryPolicy) setDefaults() {
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 100 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 10 * time.Second
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	if len(p.StatusCodes) == 0 {
+		p.StatusCodes = defaultRetryStatusCodes
+	}
+	if len(p.Methods) == 0 {
+		p.Methods = defaultRetryMethods
+	}
+}
+
+// retrier 将重试策略接入 Resty / retrier wires the policy into Resty
+type retrier struct {
+	policy   RetryPolicy
+	statuses map[int]bool
+	methods  map[string]bool
+	// waits 重试判断时计算的等待时间，由 RetryAfter 取出 / Waits computed by the condition, consumed by RetryAfter
+	waits sync.Map
+}
+
+// applyRetryPolicy 为 Resty 客户端设置重试策略 / applyRetryPolicy installs the policy on a Resty client
+func applyRetryPolicy(client *resty.Client, policy RetryPolicy) {
+	policy.setDefaults()
+	r := &retrier{
+		policy:   policy,
+		statuses: make(map[int]bool, len(policy.StatusCodes)),
+		methods:  make(map[string]bool, len(policy.Methods)),
+	}
+	for _, code := range policy.StatusCodes {
+		r.statuses[code] = true
+	}
+	for _, m := range policy.Methods {
+		r.methods[strings.ToUpper(m)] = true
+	}
+
+	// 等待时间完全由 RetryAfter 计算，最小值设为 0 以保留全抖动
+	// Waits are computed by RetryAfter; a zero minimum keeps the full jitter
+	client.SetRetryCount(policy.MaxAttempts).
+		SetRetryWaitTime(0).
+		SetRetryMaxWaitTime(policy.MaxDelay).
+		SetRetryAfter(r.retryAfter)
+	client.RetryConditions = []resty.RetryConditionFunc{r.shouldRetry}
+}
+
+// shouldRetry 判断是否重试并计算下次等待时间 / shouldRetry decides whether to retry and computes the next wait
+func (r *retrier) shouldRetry(resp *resty.Response, err error) bool {
+	if resp == nil || resp.Request == nil {
+		return false
+	}
+	req := resp.Request
+	// Resty 每次尝试都会重置 req.Time，首次尝试时把开始时间记入上下文
+	// Resty resets req.Time on every attempt, so record the first attempt's start in the context
+	if req.Attempt <= 1 {
+		req.SetContext(context.WithValue(req.Context(), retryStartKey{}, req.Time))
+	}
+	ctx := req.Context()
+	opts, _ := ctx.Value(retryOptionsKey{}).(RetryOptions)
+	if opts.Disable || ctx.Err() != nil {
+		return false
+	}
+
+	maxAttempts := r.policy.MaxAttempts
+	if opts.MaxAttempts > 0 && opts.MaxAttempts < maxAttempts {
+		maxAttempts = opts.MaxAttempts
+	}
+	if req.Attempt > maxAttempts {
+		return false
+	}
+	if !opts.Force && !r.methods[req.Method] {
+		return false
+	}
+
+	var rejected *RejectedError
+	switch {
+	case errors.As(err, &rejected):
+		// 熔断或隔离拒绝的请求不重试 / Calls rejected by the breaker or bulkhead are not retried
+		return false
+	case err != nil:
+		if r.policy.DisableNetworkErrs || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+	case !r.statuses[resp.StatusCode()]:
+		return false
+	}
+
+	wait := r.backoff(req.Attempt)
+	if after, ok := parseRetryAfter(resp.Header().Get("Retry-After")); ok {
+		wait = min(after, r.policy.MaxDelay)
+	}
+
+	// 剩余预算或上下文截止时间不足以等待时放弃重试 / Give up when the budget or the deadline cannot cover the wait
+	budget := r.policy.Budget
+	if opts.Budget > 0 {
+		budget = opts.Budget
+	}
+	if budget > 0 {
+		start, ok := ctx.Value(retryStartKey{}).(time.Time)
+		if !ok {
+			start = req.Time
+		}
+		if time.Since(start)+wait > budget {
+			return false
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+		return false
+	}
+
+	r.waits.Store(req, wait)
+	return true
+}
+
+// retryAfter 返回 shouldRetry 计算的等待时间 / retryAfter returns the wait computed by shouldRetry
+func (r *retrier) retryAfter(_ *resty.Client, resp *resty.Response) (time.Duration, error) {
+	if resp == nil || resp.Request == nil {
+		return 0, nil
+	}
+	if wait, ok := r.waits.LoadAndDelete(resp.Request); ok {
+		// Resty 将 0 视为使用内置退避，至少返回 1ns / Resty treats 0 as "use the default backoff"
+		return max(wait.(time.Duration), time.Nanosecond), nil
+	}
+	return r.backoff(resp.Request.Attempt), nil
+}
+
+// backoff 全抖动指数退避：在 [0, min(MaxDelay, BaseDelay*2^(attempt-1))] 内随机
+// backoff is exponential backoff with full jitter: random in [0, min(MaxDelay, BaseDelay*2^(attempt-1))]
+func (r *retrier) backoff(attempt int) time.Duration {
+	ceiling := r.policy.MaxDelay
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt < 32 {
+		if d := r.policy.BaseDelay << (attempt - 1); d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	return rand.N(ceiling + 1)
+}
+
+// parseRetryAfter 解析 Retry-After（秒数或 HTTP 日期） / parseRetryAfter parses Retry-After as seconds or an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
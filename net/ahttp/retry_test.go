@@ -0,0 +1,118 @@
+package ahttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// newRetryClient 创建应用重试策略的独立客户端 / newRetryClient builds a standalone client with the policy applied
+func newRetryClient(policy RetryPolicy) *resty.Client {
+	client := resty.New()
+	applyRetryPolicy(client, policy)
+	return client
+}
+
+// TestRetryPolicy 测试状态码、幂等方法与显式开启重试 / TestRetryPolicy covers status codes, idempotency and opt-in
+func TestRetryPolicy(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := newRetryClient(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+
+	resp, err := client.R().Get(ts.URL)
+	if err != nil || resp.StatusCode() != http.StatusOK || hits.Load() != 3 {
+		t.Fatalf("GET: expected success after 3 attempts, got %v %d attempts=%d", err, resp.StatusCode(), hits.Load())
+	}
+
+	hits.Store(0)
+	resp, _ = client.R().Post(ts.URL)
+	if resp.StatusCode() != http.StatusServiceUnavailable || hits.Load() != 1 {
+		t.Errorf("POST should not be retried, got %d attempts=%d", resp.StatusCode(), hits.Load())
+	}
+
+	hits.Store(0)
+	resp, _ = client.R().SetContext(WithRetry(context.Background(), RetryOptions{Force: true})).Post(ts.URL)
+	if resp.StatusCode() != http.StatusOK || hits.Load() != 3 {
+		t.Errorf("opted-in POST should be retried, got %d attempts=%d", resp.StatusCode(), hits.Load())
+	}
+
+	hits.Store(0)
+	resp, _ = client.R().SetContext(WithRetry(context.Background(), RetryOptions{MaxAttempts: 1})).Get(ts.URL)
+	if resp.StatusCode() != http.StatusServiceUnavailable || hits.Load() != 2 {
+		t.Errorf("per-request max attempts: got %d attempts=%d", resp.StatusCode(), hits.Load())
+	}
+}
+
+// TestRetryAfterAndDeadline 测试 Retry-After 与上下文截止时间 / TestRetryAfterAndDeadline covers Retry-After and the deadline
+func TestRetryAfterAndDeadline(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	client := newRetryClient(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	resp, _ := client.R().SetContext(ctx).Get(ts.URL)
+	if hits.Load() != 1 || resp.StatusCode() != http.StatusTooManyRequests || time.Since(start) > 400*time.Millisecond {
+		t.Errorf("a Retry-After beyond the deadline should stop retrying, attempts=%d elapsed=%v", hits.Load(), time.Since(start))
+	}
+
+	hits.Store(0)
+	start = time.Now()
+	client.R().Get(ts.URL)
+	if hits.Load() != 4 || time.Since(start) < 3*time.Second {
+		t.Errorf("expected Retry-After to be honored, attempts=%d elapsed=%v", hits.Load(), time.Since(start))
+	}
+}
+
+// TestRetryBudget 测试重试预算按首次请求累计 / TestRetryBudget checks the budget accumulates from the first attempt
+func TestRetryBudget(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client := newRetryClient(RetryPolicy{MaxAttempts: 10, BaseDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond, Budget: 100 * time.Millisecond})
+
+	start := time.Now()
+	resp, _ := client.R().Get(ts.URL)
+	if resp.StatusCode() != http.StatusServiceUnavailable || hits.Load() < 3 || hits.Load() > 6 || time.Since(start) > 300*time.Millisecond {
+		t.Errorf("the budget should stop retrying across attempts, attempts=%d elapsed=%v", hits.Load(), time.Since(start))
+	}
+}
+
+// TestParseRetryAfter 测试 Retry-After 解析 / TestParseRetryAfter covers Retry-After parsing
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := parseRetryAfter("3"); !ok || d != 3*time.Second {
+		t.Errorf("seconds: got %v %v", d, ok)
+	}
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d, ok := parseRetryAfter(future); !ok || d < 58*time.Second {
+		t.Errorf("http date: got %v %v", d, ok)
+	}
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Error("invalid value should be ignored")
+	}
+}
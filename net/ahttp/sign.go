@@ -0,0 +1,61 @@
+package ahttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/small-ek/antgo/crypto/arand"
+	"github.com/small-ek/antgo/net/httpx"
+)
+
+// Signer 请求签名器，与 agin.Signature 中间件配套 / Signer signs requests for the agin.Signature middleware
+type Signer struct {
+	AppID  string // 应用 ID / App ID
+	Secret string // 签名密钥 / Signing secret
+}
+
+// SignRequest 为请求生成时间戳、随机串并写入签名请求头 / SignRequest sets the timestamp, nonce and signature headers
+func (s Signer) SignRequest(req *http.Request) error {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		data, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return err
+		}
+		body = data
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	nonce, err := arand.RandomString(16)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set(httpx.HeaderAppID, s.AppID)
+	req.Header.Set(httpx.HeaderTimestamp, timestamp)
+	req.Header.Set(httpx.HeaderNonce, string(nonce))
+	req.Header.Set(httpx.HeaderSignature, httpx.Sign(s.Secret, req.Method, req.URL.Path, req.URL.Query(), body, timestamp, string(nonce)))
+	return nil
+}
+
+// SetSigner 为所有请求签名，每次发送（含重试）使用新的时间戳与随机串。
+// 签名通过 Resty 的 PreRequestHook 完成，会覆盖已有的 PreRequestHook。
+//
+// SetSigner signs every request, using a fresh timestamp and nonce per attempt including retries.
+// Signing runs in Resty's PreRequestHook and replaces any existing hook.
+func (h *HttpClient) SetSigner(appID, secret string) *HttpClient {
+	signer := Signer{AppID: appID, Secret: secret}
+	h.httpClient.SetPreRequestHook(func(_ *resty.Client, req *http.Request) error {
+		return signer.SignRequest(req)
+	})
+	return h
+}
@@ -0,0 +1,400 @@
+package ahttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/small-ek/antgo/crypto/ahash"
+)
+
+// ErrChecksumMismatch 下载内容校验失败 / The downloaded content does not match the checksum
+var ErrChecksumMismatch = errors.New("ahttp: checksum mismatch")
+
+// errRestart 服务端内容与本地分片不一致，需要从头下载 / The partial file does not line up with the server, start over
+var errRestart = errors.New("ahttp: partial download does not match the server, restarting")
+
+// StatusError 非 2xx 响应 / StatusError is returned for non-2xx responses
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+// Error 实现 error 接口 / Error implements error
+func (e *StatusError) Error() string {
+	return "ahttp: unexpected response status " + e.Status
+}
+
+// Progress 传输进度 / Progress reports transfer progress
+type Progress struct {
+	Transferred int64 // 已传输字节数（含续传前已有部分） / Bytes transferred, including a resumed prefix
+	Total       int64 // 总字节数，未知时为 -1 / Total bytes, -1 when unknown
+}
+
+// Percent 完成百分比，总数未知时返回 -1 / Percent returns the completion percentage, -1 when the total is unknown
+func (p Progress) Percent() float64 {
+	if p.Total <= 0 {
+		return -1
+	}
+	return float64(p.Transferred) * 100 / float64(p.Total)
+}
+
+// ProgressFunc 进度回调，在传输协程中同步调用 / ProgressFunc is called synchronously from the transfer goroutine
+type ProgressFunc func(p Progress)
+
+// DownloadOptions 下载选项 / DownloadOptions configures Download
+type DownloadOptions struct {
+	Checksum string            // 格式 "<算法>:<十六进制>"，如 "sha256:9f86d0..."，算法见 ahash.NewHash / "<algorithm>:<hex>", see ahash.NewHash
+	Progress ProgressFunc      // 进度回调 / Progress callback
+	Headers  map[string]string // 额外请求头 / Extra request headers
+	NoResume bool              // 丢弃已有的 .part 文件重新下载 / Discard an existing .part file instead of resuming
+}
+
+// Download 下载到 dst：先写入 dst.part，中断后再次调用（或自动重试时）通过 Range 续传，校验通过后重命名为 dst。
+// 网络错误、5xx 与 429 按 RetryAttempts 自动续传重试；不受 Config.Timeout 限制，超时请使用 ctx。
+//
+// Download streams url to dst through dst.part, resuming with Range requests on later calls and automatic retries,
+// verifying the checksum and renaming the file when complete. Network errors, 5xx and 429 are retried up to
+// RetryAttempts. Config.Timeout does not apply; bound the transfer with ctx instead.
+func (h *HttpClient) Download(ctx context.Context, url, dst string, opts ...DownloadOptions) error {
+	var o DownloadOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	var algorithm, sum string
+	if o.Checksum != "" {
+		var ok bool
+		if algorithm, sum, ok = strings.Cut(o.Checksum, ":"); !ok {
+			return fmt.Errorf("ahttp: checksum %q must look like <algorithm>:<hex>", o.Checksum)
+		}
+		if _, err := ahash.NewHash(algorithm); err != nil {
+			return err
+		}
+	}
+
+	part := dst + ".part"
+	if o.NoResume {
+		if err := os.Remove(part); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := h.downloadOnce(ctx, url, part, o)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, errRestart) {
+			// 分片已删除，立即从头下载且不计入重试次数 / The partial file is gone, start over without using a retry
+			attempt--
+			continue
+		}
+		if ctx.Err() != nil || attempt >= h.config.RetryAttempts || !retryableTransferError(err) {
+			return err
+		}
+		wait := h.config.RetryWaitTime << attempt
+		if h.config.RetryMaxWaitTime > 0 && wait > h.config.RetryMaxWaitTime {
+			wait = h.config.RetryMaxWaitTime
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+
+	if algorithm != "" {
+		got, err := ahash.File(algorithm, part)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(got, sum) {
+			_ = os.Remove(part)
+			return fmt.Errorf("%w: %s want %s, got %s", ErrChecksumMismatch, algorithm, sum, got)
+		}
+	}
+	return os.Rename(part, dst)
+}
+
+// downloadOnce 单次下载（可能为续传） / downloadOnce performs one, possibly resumed, download attempt
+func (h *HttpClient) downloadOnce(ctx context.Context, url, part string, o DownloadOptions) error {
+	var offset int64
+	if fi, err := os.Stat(part); err == nil {
+		offset = fi.Size()
+	}
+
+	req, err := h.newStreamRequest(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range o.Headers {
+		req.Header.Set(k, v)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	resp, err := h.streamClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	total := int64(-1)
+	flag := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			_ = os.Remove(part)
+			return errRestart
+		}
+		total = size
+		flag |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// 本地分片已完整时服务端返回 416 / The server answers 416 when the partial file is already complete
+		if _, size, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && size == offset {
+			return nil
+		}
+		_ = os.Remove(part)
+		return errRestart
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		// 服务端忽略 Range 时从头写入 / Start over when the server ignores Range
+		offset = 0
+		total = resp.ContentLength
+		flag |= os.O_TRUNC
+	default:
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	f, err := os.OpenFile(part, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, &progressReader{r: resp.Body, n: offset, total: total, fn: o.Progress})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if total >= 0 && offset+n != total {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// retryableTransferError 判断是否可以续传重试 / retryableTransferError reports whether a transfer error is worth retrying
+func retryableTransferError(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode >= http.StatusInternalServerError || status.StatusCode == http.StatusTooManyRequests
+	}
+	var rejected *RejectedError
+	return !errors.As(err, &rejected) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// parseContentRange 解析 "bytes 0-99/200" 或 "bytes */200"，总数未知时为 -1
+// parseContentRange parses "bytes 0-99/200" or "bytes */200"; the size is -1 when unknown
+func parseContentRange(value string) (start, size int64, ok bool) {
+	value, found := strings.CutPrefix(value, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, total, found := strings.Cut(value, "/")
+	if !found {
+		return 0, 0, false
+	}
+	size = -1
+	if total != "*" {
+		var err error
+		if size, err = strconv.ParseInt(total, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if rng == "*" {
+		return 0, size, true
+	}
+	first, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, size, true
+}
+
+// UploadOptions 上传选项 / UploadOptions configures Upload
+type UploadOptions struct {
+	Method      string            // 默认 POST / Defaults to POST
+	FieldName   string            // 文件字段名，默认 "file" / File field name, defaults to "file"
+	FileName    string            // 文件名，默认 "file" / File name, defaults to "file"
+	ContentType string            // 文件类型，默认 application/octet-stream / File content type
+	Fields      map[string]string // 其他表单字段，写在文件之前 / Other form fields, written before the file
+	Headers     map[string]string // 额外请求头 / Extra request headers
+	Size        int64             // 文件大小，用于进度总数，0 为未知 / File size for progress, 0 when unknown
+	Progress    ProgressFunc      // 进度回调 / Progress callback
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (o *UploadOptions) setDefaults() {
+	if o.Method == "" {
+		o.Method = http.MethodPost
+	}
+	if o.FieldName == "" {
+		o.FieldName = "file"
+	}
+	if o.FileName == "" {
+		o.FileName = "file"
+	}
+	if o.ContentType == "" {
+		o.ContentType = "application/octet-stream"
+	}
+}
+
+// Upload 以 multipart 流式上传 r 的内容，边读边发送，不在内存中缓存整个文件；取消 ctx 即中止上传。
+// 非 2xx 时同时返回响应与 *StatusError；调用方负责关闭响应体。请求体不可重放，因此不会自动重试。
+//
+// Upload streams r as a multipart upload without buffering it in memory; cancelling ctx aborts it.
+// Non-2xx responses are returned together with a *StatusError; the caller closes the body. The body
+// cannot be replayed, so uploads are never retried.
+func (h *HttpClient) Upload(ctx context.Context, url string, r io.Reader, opts UploadOptions) (*http.Response, error) {
+	opts.setDefaults()
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+
+	// 取消时关闭写端，传输层读取请求体得到 ctx 的错误 / Close the writer on cancellation so the transport reads ctx's error from the body
+	stop := context.AfterFunc(ctx, func() { _ = pw.CloseWithError(ctx.Err()) })
+	go func() {
+		defer stop()
+		_ = pw.CloseWithError(writeMultipart(mw, r, opts))
+	}()
+
+	req, err := h.newStreamRequest(ctx, opts.Method, url, pr)
+	if err != nil {
+		_ = pr.CloseWithError(err)
+		return nil, err
+	}
+	for k, v := range opts.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	resp, err := h.streamClient().Do(req)
+	if err != nil {
+		_ = pr.CloseWithError(err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return resp, nil
+}
+
+// writeMultipart 写出表单字段与文件内容 / writeMultipart writes the form fields and the file
+func writeMultipart(mw *multipart.Writer, r io.Reader, opts UploadOptions) error {
+	for k, v := range opts.Fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(opts.FieldName), escapeQuotes(opts.FileName)))
+	header.Set("Content-Type", opts.ContentType)
+	w, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	total := opts.Size
+	if total <= 0 {
+		total = -1
+	}
+	if _, err := io.Copy(w, &progressReader{r: r, total: total, fn: opts.Progress}); err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+// escapeQuotes 转义表单头中的引号 / escapeQuotes escapes quotes in form headers
+func escapeQuotes(s string) string {
+	return strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace(s)
+}
+
+// progressReader 统计读取字节数并回调进度 / progressReader counts bytes read and reports progress
+type progressReader struct {
+	r     io.Reader
+	n     int64
+	total int64
+	fn    ProgressFunc
+}
+
+// Read 读取数据 / Read reads data
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.n += int64(n)
+		if p.fn != nil {
+			p.fn(Progress{Transferred: p.n, Total: p.total})
+		}
+	}
+	return n, err
+}
+
+// newStreamRequest 按客户端的基础地址、默认请求头、查询参数与认证构造请求
+// newStreamRequest builds a request with the client's base URL, default headers, query parameters and auth
+func (h *HttpClient) newStreamRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	c := h.httpClient
+	if c.BaseURL != "" && !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = strings.TrimRight(c.BaseURL, "/") + "/" + strings.TrimLeft(url, "/")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.Header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	if len(c.QueryParam) > 0 {
+		query := req.URL.Query()
+		for k, v := range c.QueryParam {
+			if !query.Has(k) {
+				query[k] = v
+			}
+		}
+		req.URL.RawQuery = query.Encode()
+	}
+	if c.UserInfo != nil {
+		req.SetBasicAuth(c.UserInfo.Username, c.UserInfo.Password)
+	}
+	if c.Token != "" {
+		scheme := c.AuthScheme
+		if scheme == "" {
+			scheme = "Bearer"
+		}
+		req.Header.Set("Authorization", scheme+" "+c.Token)
+	}
+	for _, cookie := range c.Cookies {
+		req.AddCookie(cookie)
+	}
+	return req, nil
+}
+
+// streamClient 复用传输链（代理、TLS、熔断、OAuth2）但不设总超时的客户端
+// streamClient shares the transport chain (proxy, TLS, breaker, OAuth2) without the overall timeout
+func (h *HttpClient) streamClient() *http.Client {
+	return &http.Client{Transport: h.Transport()}
+}
@@ -0,0 +1,156 @@
+package ahttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/small-ek/antgo/crypto/ahash"
+)
+
+// TestDownload 测试续传、断点自动重试、进度与校验 / TestDownload covers resume, automatic retry, progress and checksums
+func TestDownload(t *testing.T) {
+	data := make([]byte, 256*1024)
+	_, _ = rand.Read(data)
+	sum, _ := ahash.Reader("sha256", bytes.NewReader(data))
+
+	var ranges []string
+	var dropOnce atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.URL.Path == "/flaky" && dropOnce.CompareAndSwap(false, true) {
+			// 只发送一半后断开连接 / Send half the body, then drop the connection
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			_, _ = w.Write(data[:len(data)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "blob", time.Time{}, bytes.NewReader(data))
+	}))
+	defer ts.Close()
+
+	conf := DefaultConfig()
+	conf.RetryAttempts = 1
+	conf.RetryWaitTime = time.Millisecond
+	client := NewClient(conf)
+	dir := t.TempDir()
+
+	// 已有前 1000 字节时续传 / Resume from an existing 1000 byte prefix
+	dst := filepath.Join(dir, "resume.bin")
+	if err := os.WriteFile(dst+".part", data[:1000], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var first, last Progress
+	err := client.Download(context.Background(), ts.URL+"/blob", dst, DownloadOptions{
+		Checksum: "sha256:" + sum,
+		Progress: func(p Progress) {
+			if first.Transferred == 0 {
+				first = p
+			}
+			last = p
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dst); !bytes.Equal(got, data) {
+		t.Fatal("resumed file differs from the source")
+	}
+	if ranges[0] != "bytes=1000-" || first.Transferred <= 1000 || last.Transferred != int64(len(data)) || last.Total != int64(len(data)) {
+		t.Fatalf("unexpected resume: range %q, progress %+v -> %+v", ranges[0], first, last)
+	}
+	if _, err := os.Stat(dst + ".part"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("part file left behind")
+	}
+
+	// 连接中断后自动续传 / Automatically resume after a dropped connection
+	ranges = nil
+	dst = filepath.Join(dir, "flaky.bin")
+	if err := client.Download(context.Background(), ts.URL+"/flaky", dst, DownloadOptions{Checksum: "SHA256:" + sum}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dst); !bytes.Equal(got, data) || len(ranges) != 2 || ranges[1] == "" {
+		t.Fatalf("unexpected retry: ranges %q", ranges)
+	}
+
+	// 校验失败时删除分片 / A checksum mismatch removes the part file
+	dst = filepath.Join(dir, "bad.bin")
+	err = client.Download(context.Background(), ts.URL+"/blob", dst, DownloadOptions{Checksum: "md5:00"})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err := os.Stat(dst + ".part"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("part file kept after a checksum mismatch")
+	}
+}
+
+// TestUpload 测试流式上传、表单字段、进度与取消 / TestUpload covers streaming uploads, fields, progress and cancellation
+func TestUpload(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		result := ""
+		for {
+			p, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			if p.FileName() == "" {
+				value, _ := io.ReadAll(p)
+				result += p.FormName() + "=" + string(value) + ";"
+				continue
+			}
+			sum, _ := ahash.Reader("sha256", p)
+			result += p.FormName() + ":" + p.FileName() + ":" + sum
+		}
+		_, _ = w.Write([]byte(result))
+	}))
+	defer ts.Close()
+
+	data := bytes.Repeat([]byte("antgo"), 100000)
+	sum, _ := ahash.Reader("sha256", bytes.NewReader(data))
+	client := NewClient(nil)
+
+	var last Progress
+	resp, err := client.Upload(context.Background(), ts.URL, bytes.NewReader(data), UploadOptions{
+		FieldName: "doc",
+		FileName:  "a.txt",
+		Fields:    map[string]string{"kind": "report"},
+		Size:      int64(len(data)),
+		Progress:  func(p Progress) { last = p },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "kind=report;doc:a.txt:"+sum {
+		t.Fatalf("unexpected upload result %q", body)
+	}
+	if last.Transferred != int64(len(data)) || last.Percent() != 100 {
+		t.Fatalf("unexpected progress %+v", last)
+	}
+
+	// 取消上下文中止上传 / Cancelling the context aborts the upload
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() { _, _ = pw.Write([]byte("partial")) }()
+	if _, err := client.Upload(ctx, ts.URL, pr, UploadOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+}
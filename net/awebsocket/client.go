@@ -1,31 +1,67 @@
 package awebsocket
 
 import (
+	"context"
+	"errors"
 	"github.com/small-ek/antgo/crypto/ahash"
 	"sync"
+	"time"
 )
 
 // Client 客户端连接管理
 type Client struct {
-	Clients     map[*Connection]bool   // 全部的连接
-	ClientsLock sync.RWMutex           // 读写锁
-	Users       map[string]*Connection // 登录的用户
-	UserLock    sync.RWMutex           // 读写锁
-	Register    chan *Connection       // 连接通道处理
-	Login       chan *Login            // 用户登录通道处理
-	Close       chan *Connection       // 断开连接处理程序
-	Broadcast   chan []byte            // 广播消息通道处理
+	Clients     map[*Connection]bool            // 全部的连接
+	ClientsLock sync.RWMutex                    // 读写锁
+	Users       map[string]map[*Connection]bool // 登录的用户，一个用户可在多个设备上同时连接 / Logged-in users, each may be connected from several devices
+	UserLock    sync.RWMutex                    // 读写锁
+	Rooms       map[string]map[*Connection]bool // 房间成员 / Room members
+	RoomLock    sync.RWMutex                    // 读写锁，同时保护 joined / Read-write lock, also guarding joined
+	Register    chan *Connection                // 连接通道处理
+	Login       chan *Login                     // 用户登录通道处理
+	Close       chan *Connection                // 断开连接处理程序
+	Broadcast   chan []byte                     // 广播消息通道处理
+	joined      map[*Connection]map[string]bool // 连接加入的房间 / Rooms joined by each connection
+	userKeys    map[*Connection]string          // 连接登录的用户，受 UserLock 保护 / User key of each logged-in connection, guarded by UserLock
+	changed     chan struct{}                   // 在线用户变化通知，供集群同步在线状态 / Signals user changes so a Cluster can sync presence
+	loginSeq    uint64                          // 登录序号，受 UserLock 保护 / Login sequence, guarded by UserLock
 }
 
 // NewClient 默认初始化客户端
 func NewClient() (clientManager *Client) {
 	return &Client{
 		Clients:   make(map[*Connection]bool),
-		Users:     make(map[string]*Connection),
+		Users:     make(map[string]map[*Connection]bool),
+		Rooms:     make(map[string]map[*Connection]bool),
 		Register:  make(chan *Connection, 1000),
 		Login:     make(chan *Login, 1000),
 		Close:     make(chan *Connection, 1000),
 		Broadcast: make(chan []byte, 1000),
+		joined:    make(map[*Connection]map[string]bool),
+		userKeys:  make(map[*Connection]string),
+		changed:   make(chan struct{}, 1),
+	}
+}
+
+// Run 处理 Register、Login、Close 与 Broadcast 通道直到 ctx 结束，通常以 go hub.Run(ctx) 启动。
+// 事件处理方法本身是并发安全的，也可以直接调用。
+//
+// Run consumes the Register, Login, Close and Broadcast channels until ctx is done; start it with go hub.Run(ctx).
+// The event handlers are safe for concurrent use and may also be called directly.
+func (get *Client) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case connection := <-get.Register:
+			get.OnRegister(connection)
+		case login := <-get.Login:
+			get.OnLogin(login)
+		case connection := <-get.Close:
+			get.OnUserLogout(connection)
+			connection.Close()
+		case message := <-get.Broadcast:
+			get.SendClients(message, nil)
+		}
 	}
 }
 
@@ -49,18 +85,19 @@ func (get *Client) GetClient() (clients map[*Connection]bool) {
 // GetClientsLoop Loop all connections<循环所有的客户端连接>
 func (get *Client) GetClientsLoop(f func(client *Connection, value bool) (result bool)) {
 	get.ClientsLock.RLock()
+	defer get.ClientsLock.RUnlock()
 	for key, value := range get.Clients {
 		result := f(key, value)
 		if result == false {
 			return
 		}
 	}
-	defer get.ClientsLock.RUnlock()
-	return
 }
 
 // GetClientsCount Gets the length of the connection<获取客户端的总长度>
 func (get *Client) GetClientsCount() int {
+	get.ClientsLock.RLock()
+	defer get.ClientsLock.RUnlock()
 	return len(get.Clients)
 }
 
@@ -85,43 +122,85 @@ func GetUserKey(appId string, userId string) string {
 	return ahash.SHA1(appId + userId)
 }
 
-// AddUsers 添加用户
+// AddUsers 添加用户的连接 / AddUsers adds a connection of the user
 func (get *Client) AddUsers(key string, connection *Connection) {
 	get.UserLock.Lock()
-	get.Users[key] = connection
 	defer get.UserLock.Unlock()
+	get.addUserLocked(key, connection)
 }
 
-// GetUserClient 获取用户的连接
+// addUserLocked 添加用户的连接并移除该连接之前的登录，调用方需持有 UserLock
+// addUserLocked adds the connection to the user and drops its previous login; the caller holds UserLock
+func (get *Client) addUserLocked(key string, connection *Connection) {
+	get.deleteUserLocked(connection)
+	if get.Users[key] == nil {
+		get.Users[key] = make(map[*Connection]bool)
+	}
+	get.Users[key][connection] = true
+	get.userKeys[connection] = key
+	get.loginSeq++
+	connection.loginSeq = get.loginSeq
+	get.notifyChanged()
+}
+
+// GetUserClient 获取用户最近登录的连接 / GetUserClient returns the user's most recently logged-in connection
 func (get *Client) GetUserClient(appId string, userId string) (connection *Connection) {
 	get.UserLock.RLock()
-	userKey := GetUserKey(appId, userId)
-	if value, ok := get.Users[userKey]; ok {
-		connection = value
+	defer get.UserLock.RUnlock()
+	// 按登录序号比较，同一秒内的重复登录也能区分 / Compare login sequences so re-logins within one second still order
+	for value := range get.Users[GetUserKey(appId, userId)] {
+		if connection == nil || value.loginSeq > connection.loginSeq {
+			connection = value
+		}
 	}
+	return
+}
+
+// GetUserConnections 获取用户在所有设备上的连接 / GetUserConnections returns the user's connections on every device
+func (get *Client) GetUserConnections(appId string, userId string) (connections []*Connection) {
+	get.UserLock.RLock()
 	defer get.UserLock.RUnlock()
+	for connection := range get.Users[GetUserKey(appId, userId)] {
+		connections = append(connections, connection)
+	}
 	return
 }
 
 // GetUsersCount Get the total number of users<获取用户总数>
 func (get *Client) GetUsersCount() int {
+	get.UserLock.RLock()
+	defer get.UserLock.RUnlock()
 	return len(get.Users)
 }
 
-// DeleteUsers Delete user<删除用户>
+// DeleteUsers Delete user<删除用户的连接，用户没有其他连接时下线>
 func (get *Client) DeleteUsers(connection *Connection) (result bool) {
 	get.UserLock.Lock()
-	key := GetUserKey(connection.AppId, connection.UserId)
-	if value, ok := get.Users[key]; ok {
-		// 判断是否为相同的用户
-		if value.Address != connection.Address {
-			return
-		}
+	defer get.UserLock.Unlock()
+	return get.deleteUserLocked(connection)
+}
+
+// deleteUserLocked 移除连接的登录，调用方需持有 UserLock / deleteUserLocked removes the connection's login; the caller holds UserLock
+func (get *Client) deleteUserLocked(connection *Connection) bool {
+	key, ok := get.userKeys[connection]
+	if !ok {
+		return false
+	}
+	delete(get.userKeys, connection)
+	delete(get.Users[key], connection)
+	if len(get.Users[key]) == 0 {
 		delete(get.Users, key)
-		result = true
+		get.notifyChanged()
+	}
+	return true
+}
+
+// notifyChanged 非阻塞地发出在线用户变化通知 / notifyChanged signals a user change without blocking
+func (get *Client) notifyChanged() {
+	select {
+	case get.changed <- struct{}{}:
+	default:
 	}
-	defer get.UserLock.Unlock()
-	return
 }
 
 // GetUserKeys Get the keys for all users<获取所有的key>
@@ -135,62 +214,107 @@ func (get *Client) GetUserKeys() (userKeys []string) {
 	return
 }
 
-// GetUserList 获取用户的key
+// GetUserList 获取平台下在线用户的标识
 func (get *Client) GetUserList(appId string) (userList []string) {
 	userList = make([]string, 0)
 	get.UserLock.RLock()
 	defer get.UserLock.RUnlock()
-	for _, v := range get.Users {
-		if v.AppId == appId {
-			userList = append(userList, v.UserId)
+	for _, connections := range get.Users {
+		for v := range connections {
+			if v.AppId == appId {
+				userList = append(userList, v.UserId)
+			}
+			break
 		}
 	}
 	return
 }
 
-// GetUserClients 获取用户的连接
+// GetUserClients 获取全部已登录的连接
 func (get *Client) GetUserClients() (connection []*Connection) {
-
 	connection = make([]*Connection, 0)
 	get.UserLock.RLock()
-
-	for _, v := range get.Users {
-		connection = append(connection, v)
-	}
 	defer get.UserLock.RUnlock()
+	for _, connections := range get.Users {
+		for v := range connections {
+			connection = append(connection, v)
+		}
+	}
 	return
 }
 
-// SendAll 向全部成员(除了自己)发送数据
+// SendAll 向全部已登录成员(除了自己)发送数据，写入队列已满时阻塞等待
+// SendAll sends to every logged-in member except one, blocking while a write queue is full
 func (get *Client) SendAll(message []byte, connection *Connection) {
-	clients := get.GetUserClients()
-	for _, conn := range clients {
+	for _, conn := range get.GetUserClients() {
 		if conn != connection {
 			conn.WriteMessage(message)
 		}
 	}
 }
 
-// OnRegister 用户建立连接事件
+// SendClients 向全部连接(除了 except)发送数据，返回送达的连接数 / SendClients sends to every connection except one and returns the number reached
+func (get *Client) SendClients(message []byte, except *Connection) int {
+	connections := make([]*Connection, 0, get.GetClientsCount())
+	get.GetClientsLoop(func(client *Connection, value bool) bool {
+		connections = append(connections, client)
+		return true
+	})
+	return deliver(connections, message, except)
+}
+
+// SendUser 向用户的所有设备发送数据，返回送达的连接数 / SendUser sends to all devices of a user and returns the number reached
+func (get *Client) SendUser(appId string, userId string, message []byte) int {
+	return deliver(get.GetUserConnections(appId, userId), message, nil)
+}
+
+// deliver 非阻塞发送，写入队列已满的慢连接会被关闭，避免拖慢其他成员
+// deliver sends without blocking; slow connections whose write queue is full are closed so they cannot stall others
+func deliver(connections []*Connection, message []byte, except *Connection) (sent int) {
+	for _, conn := range connections {
+		if conn == except {
+			continue
+		}
+		err := conn.TryWriteMessage(message)
+		if errors.Is(err, ErrWriteQueueFull) {
+			conn.Close()
+		}
+		if err == nil {
+			sent++
+		}
+	}
+	return
+}
+
+// OnRegister 用户建立连接事件，连接关闭后自动注销 / OnRegister registers a connection, which is removed automatically once closed
 func (get *Client) OnRegister(connection *Connection) {
 	get.AddClients(connection)
+	go func() {
+		<-connection.CloseChan
+		get.OnUserLogout(connection)
+	}()
 }
 
-// OnLogin 用户登录
+// OnLogin 用户登录，同一用户可以有多个连接
 func (get *Client) OnLogin(login *Login) {
 	var client = login.Client
+	// 持有 UserLock 检查连接，避免与断开同时发生时留下失效的登录
+	// Check the connection under UserLock so a concurrent disconnect cannot leave a stale login behind
+	get.UserLock.Lock()
+	defer get.UserLock.Unlock()
 	// 连接存在，在添加
-	if get.IsClient(client) {
-		userKey := login.GetUserKey()
-		get.AddUsers(userKey, login.Client)
+	if !get.IsClient(client) {
+		return
 	}
+	// 重复登录同样刷新登录时间 / A repeated login refreshes the login time as well
+	client.SetLogin(login.AppId, login.UserId, uint64(time.Now().Unix()))
+	// 连接切换账号时会移除旧的登录 / A connection switching accounts drops its old login
+	get.addUserLocked(login.GetUserKey(), client)
 }
 
-// OnUserLogout 用户断开连接
+// OnUserLogout 用户断开连接，可重复调用
 func (get *Client) OnUserLogout(client *Connection) {
 	get.DeleteClients(client)
-	deleteResult := get.DeleteUsers(client)
-	if deleteResult == false {
-		return
-	}
+	get.LeaveAll(client)
+	get.DeleteUsers(client)
 }
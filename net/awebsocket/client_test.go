@@ -0,0 +1,142 @@
+package awebsocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// peer 服务端连接与对应的客户端 / peer is a server-side connection and its remote client
+type peer struct {
+	conn   *Connection
+	remote *websocket.Conn
+}
+
+// newPeers 建立 n 个 WebSocket 连接 / newPeers opens n WebSocket connections
+func newPeers(t *testing.T, n int) []peer {
+	t.Helper()
+	accepted := make(chan *Connection, n)
+	var upgrader websocket.Upgrader
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		socket, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		accepted <- New(socket, r.RemoteAddr, uint64(time.Now().Unix()))
+	}))
+	t.Cleanup(server.Close)
+
+	peers := make([]peer, n)
+	for i := range peers {
+		remote, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = remote.Close() })
+		peers[i] = peer{conn: <-accepted, remote: remote}
+	}
+	return peers
+}
+
+// expect 读取客户端收到的消息 / expect reads the next message received by the client
+func (p peer) expect(t *testing.T, want string) {
+	t.Helper()
+	_ = p.remote.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, msg, err := p.remote.ReadMessage(); err != nil || string(msg) != want {
+		t.Fatalf("expected %q, got %q: %v", want, msg, err)
+	}
+}
+
+// waitFor 等待条件成立 / waitFor polls until the condition holds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for " + what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestClientRooms 测试集线器循环、房间、多设备用户与在线列表 / TestClientRooms covers the hub loop, rooms, multi-device users and presence
+func TestClientRooms(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub := NewClient()
+	go hub.Run(ctx)
+
+	peers := newPeers(t, 4)
+	phone, laptop, bob, guest := peers[0], peers[1], peers[2], peers[3]
+	for _, p := range peers {
+		hub.Register <- p.conn
+	}
+	waitFor(t, "registration", func() bool { return hub.GetClientsCount() == 4 })
+	hub.Login <- &Login{AppId: "app", UserId: "alice", Client: phone.conn}
+	hub.Login <- &Login{AppId: "app", UserId: "alice", Client: laptop.conn}
+	hub.Login <- &Login{AppId: "app", UserId: "bob", Client: bob.conn}
+	waitFor(t, "login", func() bool { return len(hub.GetUserConnections("app", "alice")) == 2 && hub.GetUsersCount() == 2 })
+	if hub.GetUserClient("app", "alice") != laptop.conn {
+		t.Fatal("expected the most recent login")
+	}
+	// 重复登录后成为最近登录的连接 / A repeated login becomes the most recent one
+	hub.OnLogin(&Login{AppId: "app", UserId: "alice", Client: phone.conn})
+	if hub.GetUserClient("app", "alice") != phone.conn {
+		t.Fatal("expected the re-login to be the most recent")
+	}
+
+	for _, p := range peers {
+		if !hub.Join("lobby", p.conn) {
+			t.Fatal("join failed")
+		}
+	}
+	hub.Join("vip", laptop.conn)
+
+	if got := hub.GetRoomPresence("lobby"); len(got) != 2 || got[0] != (Presence{AppId: "app", UserId: "alice", Connections: 2}) || got[1].UserId != "bob" {
+		t.Fatalf("unexpected presence %+v", got)
+	}
+	if got := hub.GetJoinedRooms(laptop.conn); len(got) != 2 || got[0] != "lobby" || got[1] != "vip" {
+		t.Fatalf("unexpected joined rooms %v", got)
+	}
+
+	if n := hub.SendRoom("lobby", []byte("hello"), bob.conn); n != 3 {
+		t.Fatalf("expected 3 room deliveries, got %d", n)
+	}
+	phone.expect(t, "hello")
+	laptop.expect(t, "hello")
+	guest.expect(t, "hello")
+
+	if n := hub.SendUser("app", "alice", []byte("dm")); n != 2 {
+		t.Fatalf("expected both devices, got %d", n)
+	}
+	phone.expect(t, "dm")
+	laptop.expect(t, "dm")
+
+	// 消息按顺序到达，收到 "all" 说明之前没有多余的消息 / Messages arrive in order, so "all" proves nothing extra came first
+	hub.Broadcast <- []byte("all")
+	for _, p := range peers {
+		p.expect(t, "all")
+	}
+
+	// 客户端断开后自动离开房间并下线该设备 / A disconnected client leaves its rooms and that device goes offline
+	_ = laptop.remote.Close()
+	waitFor(t, "disconnect", func() bool { return hub.GetClientsCount() == 3 })
+	if hub.GetRoomCount("vip") != 0 || len(hub.GetRooms()) != 1 || len(hub.GetUserConnections("app", "alice")) != 1 {
+		t.Fatalf("connection not cleaned up: rooms %v", hub.GetRooms())
+	}
+	if hub.Join("lobby", laptop.conn) {
+		t.Fatal("expected join to fail for a closed connection")
+	}
+
+	hub.Leave("lobby", guest.conn)
+	hub.Close <- bob.conn
+	waitFor(t, "close", func() bool { return hub.GetUsersCount() == 1 })
+	if got := hub.GetRoomPresence("lobby"); len(got) != 1 || got[0].Connections != 1 {
+		t.Fatalf("unexpected presence after leaving %+v", got)
+	}
+}
@@ -0,0 +1,554 @@
+package awebsocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/small-ek/antgo/db/aredis"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"go.uber.org/zap"
+)
+
+// 集群消息类型 / Cluster message kinds
+const (
+	kindUser      = "user"      // 发送给用户的所有设备 / To every device of a user
+	kindRoom      = "room"      // 发送给房间成员 / To the members of a room
+	kindAll       = "all"       // 发送给全部已登录连接 / To every logged-in connection
+	kindBroadcast = "broadcast" // 发送给全部连接 / To every connection
+)
+
+// ErrNoBroker 未配置消息代理或 Redis / ErrNoBroker is returned when neither a broker nor Redis is configured
+var ErrNoBroker = errors.New("awebsocket: cluster needs a broker or a redis client")
+
+// Broker 集群节点之间的消息通道 / Broker carries messages between cluster nodes
+type Broker interface {
+	// Publish 发布消息 / Publish sends a payload to every subscriber of the channel
+	Publish(channel string, payload []byte) error
+	// Subscribe 订阅频道并阻塞直到 ctx 结束或订阅失败 / Subscribe delivers payloads to handler until ctx is done or the subscription fails
+	Subscribe(ctx context.Context, channel string, handler func(payload []byte)) error
+}
+
+// PresenceStore 集群共享的在线状态，记录用户所在的节点，条目在 ttl 内未续期即过期
+// PresenceStore is the shared presence registry mapping users to nodes; entries not refreshed within ttl expire
+type PresenceStore interface {
+	// Online 标记用户在节点上在线或续期 / Online marks users online on a node or refreshes them
+	Online(node string, userKeys []string, ttl time.Duration) error
+	// Offline 标记用户在节点上下线 / Offline marks users offline on a node
+	Offline(node string, userKeys []string) error
+	// Nodes 获取用户所在的节点 / Nodes returns the nodes a user is connected to
+	Nodes(userKey string) ([]string, error)
+	// Count 获取全局在线用户数 / Count returns the number of users online across the cluster
+	Count() (int64, error)
+}
+
+// ClusterConfig 集群配置，零值字段读取 websocket_cluster.* 配置
+// ClusterConfig configures a Cluster; zero fields fall back to websocket_cluster.*
+type ClusterConfig struct {
+	Node        string              // 节点名称，默认主机名加进程号 / Node name, defaults to hostname and pid
+	Channel     string              // 发布订阅频道，默认 "awebsocket" / Pub/sub channel, defaults to "awebsocket"
+	PresenceTTL time.Duration       // 在线状态过期时间，默认 30s / Presence expiry, defaults to 30s
+	Prefix      string              // Redis 在线状态键前缀，默认 "awebsocket:presence:" / Redis presence key prefix
+	Redis       *aredis.ClientRedis // 默认 websocket_cluster.redis 指定的连接 / Defaults to the websocket_cluster.redis connection
+	Broker      Broker              // 消息代理，默认基于 Redis / Message broker, Redis backed by default
+	Presence    PresenceStore       // 在线状态存储，默认基于 Redis / Presence store, Redis backed by default
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *ClusterConfig) setDefaults() {
+	if cfg.Node == "" {
+		cfg.Node = config.GetString("websocket_cluster.node")
+	}
+	if cfg.Node == "" {
+		host, _ := os.Hostname()
+		cfg.Node = host + "-" + strconv.Itoa(os.Getpid())
+	}
+	if cfg.Channel == "" {
+		cfg.Channel = config.GetString("websocket_cluster.channel")
+	}
+	if cfg.Channel == "" {
+		cfg.Channel = "awebsocket"
+	}
+	if cfg.PresenceTTL <= 0 {
+		cfg.PresenceTTL = config.GetDuration("websocket_cluster.presence_ttl")
+	}
+	if cfg.PresenceTTL <= 0 {
+		cfg.PresenceTTL = 30 * time.Second
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "awebsocket:presence:"
+	}
+	if cfg.Redis == nil && (cfg.Broker == nil || cfg.Presence == nil) {
+		cfg.Redis = aredis.Client[config.GetString("websocket_cluster.redis")]
+	}
+	if cfg.Redis != nil {
+		if cfg.Broker == nil {
+			cfg.Broker = &RedisBroker{Client: cfg.Redis}
+		}
+		if cfg.Presence == nil {
+			cfg.Presence = &RedisPresenceStore{Client: cfg.Redis, Prefix: cfg.Prefix}
+		}
+	}
+}
+
+// clusterMessage 节点之间传递的消息 / clusterMessage is the envelope exchanged between nodes
+type clusterMessage struct {
+	Node   string `json:"node"`
+	Kind   string `json:"kind"`
+	AppId  string `json:"app_id,omitempty"`
+	UserId string `json:"user_id,omitempty"`
+	Room   string `json:"room,omitempty"`
+	Data   []byte `json:"data"`
+}
+
+// Cluster 多实例适配器：消息先投递到本节点的连接，再经消息代理发布给其他节点，
+// 并把本节点的在线用户同步到共享的在线状态存储。
+//
+// Cluster spreads a hub across instances: messages reach local connections first and are then published
+// through the broker to the other nodes, while the node's online users are synced to the shared presence store.
+type Cluster struct {
+	hub    *Client
+	config ClusterConfig
+	online map[string]bool // 已发布到在线状态存储的用户，仅由 Run 访问 / Users published to the presence store, only touched by Run
+}
+
+// NewCluster 创建集群适配器，需调用 Run 后才会接收其他节点的消息
+// NewCluster creates a cluster adapter; call Run to start receiving from other nodes
+func NewCluster(hub *Client, cfg ...ClusterConfig) (*Cluster, error) {
+	var conf ClusterConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	}
+	conf.setDefaults()
+	if conf.Broker == nil || conf.Presence == nil {
+		return nil, ErrNoBroker
+	}
+	return &Cluster{hub: hub, config: conf, online: make(map[string]bool)}, nil
+}
+
+// Node 获取本节点名称 / Node returns the name of this node
+func (c *Cluster) Node() string {
+	return c.config.Node
+}
+
+// Run 订阅其他节点的消息并同步在线状态直到 ctx 结束，结束时本节点的用户下线，通常以 go cluster.Run(ctx) 启动
+// Run subscribes to other nodes and syncs presence until ctx is done, then takes this node's users offline; start it with go cluster.Run(ctx)
+func (c *Cluster) Run(ctx context.Context) {
+	go c.subscribe(ctx)
+
+	ticker := time.NewTicker(c.config.PresenceTTL / 3)
+	defer ticker.Stop()
+	c.sync(true)
+	for {
+		select {
+		case <-ctx.Done():
+			c.offline()
+			return
+		case <-c.hub.changed:
+			c.sync(false)
+		case <-ticker.C:
+			c.sync(true)
+		}
+	}
+}
+
+// subscribe 订阅频道，失败后等待一秒重新订阅 / subscribe listens on the channel and resubscribes a second after failures
+func (c *Cluster) subscribe(ctx context.Context) {
+	for {
+		err := c.config.Broker.Subscribe(ctx, c.config.Channel, c.receive)
+		if ctx.Err() != nil {
+			return
+		}
+		c.log("Websocket cluster subscription failed", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// receive 投递其他节点发布的消息 / receive delivers a message published by another node
+func (c *Cluster) receive(payload []byte) {
+	var msg clusterMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		c.log("Websocket cluster message is invalid", err)
+		return
+	}
+	if msg.Node == c.config.Node {
+		return
+	}
+	switch msg.Kind {
+	case kindUser:
+		c.hub.SendUser(msg.AppId, msg.UserId, msg.Data)
+	case kindRoom:
+		c.hub.SendRoom(msg.Room, msg.Data, nil)
+	case kindAll:
+		// 订阅协程不能被慢连接阻塞，使用非阻塞投递 / The subscription goroutine must not block on a slow connection
+		deliver(c.hub.GetUserClients(), msg.Data, nil)
+	case kindBroadcast:
+		c.hub.SendClients(msg.Data, nil)
+	}
+}
+
+// sync 把本节点的在线用户同步到存储，refresh 为 true 时续期全部用户，失败的用户在下次同步时重试
+// sync publishes the node's users to the store, refreshing all of them when refresh is set; failed users are retried next time
+func (c *Cluster) sync(refresh bool) {
+	current := make(map[string]bool)
+	for _, key := range c.hub.GetUserKeys() {
+		current[key] = true
+	}
+	var added, removed []string
+	for key := range current {
+		if refresh || !c.online[key] {
+			added = append(added, key)
+		}
+	}
+	for key := range c.online {
+		if !current[key] {
+			removed = append(removed, key)
+		}
+	}
+	if len(added) > 0 {
+		if err := c.config.Presence.Online(c.config.Node, added, c.config.PresenceTTL); err != nil {
+			c.log("Websocket cluster presence update failed", err)
+		} else {
+			for _, key := range added {
+				c.online[key] = true
+			}
+		}
+	}
+	if len(removed) > 0 {
+		if err := c.config.Presence.Offline(c.config.Node, removed); err != nil {
+			c.log("Websocket cluster presence update failed", err)
+		} else {
+			for _, key := range removed {
+				delete(c.online, key)
+			}
+		}
+	}
+}
+
+// offline 本节点的用户全部下线 / offline takes every user of this node offline
+func (c *Cluster) offline() {
+	keys := make([]string, 0, len(c.online))
+	for key := range c.online {
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if err := c.config.Presence.Offline(c.config.Node, keys); err != nil {
+		c.log("Websocket cluster presence update failed", err)
+	}
+	c.online = make(map[string]bool)
+}
+
+// publish 发布消息给其他节点 / publish sends a message to the other nodes
+func (c *Cluster) publish(msg clusterMessage) error {
+	msg.Node = c.config.Node
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.config.Broker.Publish(c.config.Channel, payload)
+}
+
+// SendUser 向用户在所有节点上的设备发送数据 / SendUser sends to the user's devices on every node
+func (c *Cluster) SendUser(appId string, userId string, message []byte) error {
+	c.hub.SendUser(appId, userId, message)
+	return c.publish(clusterMessage{Kind: kindUser, AppId: appId, UserId: userId, Data: message})
+}
+
+// SendRoom 向所有节点上的房间成员(除了本节点的 except)发送数据
+// SendRoom sends to the room members on every node, skipping except on this node
+func (c *Cluster) SendRoom(room string, message []byte, except *Connection) error {
+	c.hub.SendRoom(room, message, except)
+	return c.publish(clusterMessage{Kind: kindRoom, Room: room, Data: message})
+}
+
+// SendAll 向所有节点上已登录的连接(除了本节点的 except)发送数据
+// SendAll sends to the logged-in connections on every node, skipping except on this node
+func (c *Cluster) SendAll(message []byte, except *Connection) error {
+	c.hub.SendAll(message, except)
+	return c.publish(clusterMessage{Kind: kindAll, Data: message})
+}
+
+// Broadcast 向所有节点上的全部连接发送数据 / Broadcast sends to every connection on every node
+func (c *Cluster) Broadcast(message []byte) error {
+	c.hub.SendClients(message, nil)
+	return c.publish(clusterMessage{Kind: kindBroadcast, Data: message})
+}
+
+// UserNodes 获取用户所在的节点 / UserNodes returns the nodes the user is connected to
+func (c *Cluster) UserNodes(appId string, userId string) ([]string, error) {
+	return c.config.Presence.Nodes(GetUserKey(appId, userId))
+}
+
+// OnlineCount 获取全局在线用户数 / OnlineCount returns the number of users online across the cluster
+func (c *Cluster) OnlineCount() (int64, error) {
+	return c.config.Presence.Count()
+}
+
+// log 记录集群错误 / log records a cluster error
+func (c *Cluster) log(msg string, err error) {
+	if alog.Write != nil {
+		alog.Write.Warn(msg, zap.String("node", c.config.Node), zap.Error(err))
+	}
+}
+
+// ----------------- 内存实现 / In-memory implementations -----------------
+
+// MemoryBroker 进程内消息代理，用于测试或单进程内的多个集线器
+// MemoryBroker is an in-process broker for tests or several hubs in one process
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers map[string]map[*func([]byte)]bool
+}
+
+// NewMemoryBroker 创建进程内消息代理 / NewMemoryBroker creates an in-process broker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{handlers: make(map[string]map[*func([]byte)]bool)}
+}
+
+// Publish 同步调用频道的订阅者 / Publish calls the channel's subscribers synchronously
+func (b *MemoryBroker) Publish(channel string, payload []byte) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for handler := range b.handlers[channel] {
+		(*handler)(payload)
+	}
+	return nil
+}
+
+// Subscribe 订阅频道直到 ctx 结束 / Subscribe listens on the channel until ctx is done
+func (b *MemoryBroker) Subscribe(ctx context.Context, channel string, handler func(payload []byte)) error {
+	b.mu.Lock()
+	if b.handlers[channel] == nil {
+		b.handlers[channel] = make(map[*func([]byte)]bool)
+	}
+	b.handlers[channel][&handler] = true
+	b.mu.Unlock()
+
+	<-ctx.Done()
+
+	b.mu.Lock()
+	delete(b.handlers[channel], &handler)
+	b.mu.Unlock()
+	return ctx.Err()
+}
+
+// subscribers 频道的订阅者数量 / subscribers returns the number of subscribers on a channel
+func (b *MemoryBroker) subscribers(channel string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.handlers[channel])
+}
+
+// MemoryPresenceStore 进程内在线状态存储 / MemoryPresenceStore is an in-process presence store
+type MemoryPresenceStore struct {
+	mu    sync.Mutex
+	users map[string]map[string]time.Time // 用户 -> 节点 -> 过期时间 / user -> node -> expiry
+}
+
+// NewMemoryPresenceStore 创建进程内在线状态存储 / NewMemoryPresenceStore creates an in-process presence store
+func NewMemoryPresenceStore() *MemoryPresenceStore {
+	return &MemoryPresenceStore{users: make(map[string]map[string]time.Time)}
+}
+
+// Online 标记用户在线 / Online marks users online on a node
+func (s *MemoryPresenceStore) Online(node string, userKeys []string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiry := time.Now().Add(ttl)
+	for _, key := range userKeys {
+		if s.users[key] == nil {
+			s.users[key] = make(map[string]time.Time)
+		}
+		s.users[key][node] = expiry
+	}
+	return nil
+}
+
+// Offline 标记用户下线 / Offline marks users offline on a node
+func (s *MemoryPresenceStore) Offline(node string, userKeys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range userKeys {
+		delete(s.users[key], node)
+		if len(s.users[key]) == 0 {
+			delete(s.users, key)
+		}
+	}
+	return nil
+}
+
+// Nodes 获取用户所在的节点 / Nodes returns the nodes a user is connected to
+func (s *MemoryPresenceStore) Nodes(userKey string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	nodes := make([]string, 0, len(s.users[userKey]))
+	for node, expiry := range s.users[userKey] {
+		if expiry.After(now) {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// Count 获取在线用户数 / Count returns the number of online users
+func (s *MemoryPresenceStore) Count() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var count int64
+	for _, nodes := range s.users {
+		for _, expiry := range nodes {
+			if expiry.After(now) {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+// ----------------- Redis 实现 / Redis implementations -----------------
+
+// RedisBroker 基于 aredis 发布订阅的消息代理 / RedisBroker is a broker on top of aredis pub/sub
+type RedisBroker struct {
+	Client *aredis.ClientRedis
+}
+
+// Publish 发布消息 / Publish sends a payload to the channel
+func (b *RedisBroker) Publish(channel string, payload []byte) error {
+	return b.Client.Publish(channel, payload)
+}
+
+// Subscribe 订阅频道直到 ctx 结束或连接断开 / Subscribe listens on the channel until ctx is done or the connection drops
+func (b *RedisBroker) Subscribe(ctx context.Context, channel string, handler func(payload []byte)) error {
+	pubsub := b.Client.Subscribe(ctx, channel)
+	defer pubsub.Close()
+	// 等待订阅确认，连接失败时立即返回 / Wait for the confirmation so connection errors surface immediately
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-messages:
+			if !ok {
+				return errors.New("awebsocket: redis subscription closed")
+			}
+			handler([]byte(msg.Payload))
+		}
+	}
+}
+
+// RedisPresenceStore 基于 aredis 的在线状态存储：每个用户一个哈希表记录节点与过期时间，
+// 另有一个有序集合按过期时间记录在线用户，用于统计。
+//
+// RedisPresenceStore keeps presence in aredis: a hash per user maps nodes to expiry times,
+// and a sorted set scored by expiry tracks online users for counting.
+type RedisPresenceStore struct {
+	Client *aredis.ClientRedis
+	Prefix string
+}
+
+// userKey 用户哈希表的键 / userKey returns the key of a user's hash
+func (s *RedisPresenceStore) userKey(key string) string {
+	return s.Prefix + "user:" + key
+}
+
+// usersKey 在线用户有序集合的键 / usersKey returns the key of the online users set
+func (s *RedisPresenceStore) usersKey() string {
+	return s.Prefix + "users"
+}
+
+// Online 标记用户在线，全部命令在一个管道中发送 / Online marks users online on a node in a single pipeline
+func (s *RedisPresenceStore) Online(node string, userKeys []string, ttl time.Duration) error {
+	if len(userKeys) == 0 {
+		return nil
+	}
+	expiry := time.Now().Add(ttl).UnixMilli()
+	members := make([]redis.Z, 0, len(userKeys))
+	_, err := s.Client.Pipelined(func(pipe redis.Pipeliner) error {
+		for _, key := range userKeys {
+			pipe.HSet(s.Client.Ctx, s.userKey(key), node, expiry)
+			// 哈希表本身也会过期，清理崩溃节点留下的条目 / The hash expires too, clearing entries left by crashed nodes
+			pipe.PExpire(s.Client.Ctx, s.userKey(key), 2*ttl)
+			members = append(members, redis.Z{Score: float64(expiry), Member: key})
+		}
+		pipe.ZAdd(s.Client.Ctx, s.usersKey(), members...)
+		return nil
+	})
+	return err
+}
+
+// Offline 标记用户下线，用户不在任何节点时移出在线集合，按管道批量发送
+// Offline marks users offline on a node in pipelines, removing them from the online set once no node has them
+func (s *RedisPresenceStore) Offline(node string, userKeys []string) error {
+	if len(userKeys) == 0 {
+		return nil
+	}
+	remaining := make([]*redis.IntCmd, len(userKeys))
+	_, err := s.Client.Pipelined(func(pipe redis.Pipeliner) error {
+		for i, key := range userKeys {
+			pipe.HDel(s.Client.Ctx, s.userKey(key), node)
+			remaining[i] = pipe.HLen(s.Client.Ctx, s.userKey(key))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 与其他节点的续期并发时可能误删，下次续期会恢复 / A race with another node's refresh heals on its next heartbeat
+	offline := make([]interface{}, 0, len(userKeys))
+	for i, key := range userKeys {
+		if remaining[i].Val() == 0 {
+			offline = append(offline, key)
+		}
+	}
+	if len(offline) == 0 {
+		return nil
+	}
+	_, err = s.Client.ZRem(s.usersKey(), offline...)
+	return err
+}
+
+// Nodes 获取用户所在的节点 / Nodes returns the nodes a user is connected to
+func (s *RedisPresenceStore) Nodes(userKey string) ([]string, error) {
+	entries, err := s.Client.HGetAll(s.userKey(userKey))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	nodes := make([]string, 0, len(entries))
+	for node, value := range entries {
+		if expiry, _ := strconv.ParseInt(value, 10, 64); expiry > now {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// Count 清理过期用户后获取在线用户数 / Count removes expired users and returns the number online
+func (s *RedisPresenceStore) Count() (int64, error) {
+	if _, err := s.Client.ZRemRangeByScore(s.usersKey(), "-inf", "("+strconv.FormatInt(time.Now().UnixMilli(), 10)); err != nil {
+		return 0, err
+	}
+	return s.Client.ZCard(s.usersKey())
+}
@@ -0,0 +1,136 @@
+package awebsocket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/small-ek/antgo/db/aredis"
+)
+
+// TestCluster 测试跨节点投递与共享在线状态 / TestCluster covers cross-node delivery and shared presence
+func TestCluster(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broker, presence := NewMemoryBroker(), NewMemoryPresenceStore()
+
+	hubs := []*Client{NewClient(), NewClient()}
+	clusters := make([]*Cluster, len(hubs))
+	nodeCtx := make([]context.CancelFunc, len(hubs))
+	for i, hub := range hubs {
+		cluster, err := NewCluster(hub, ClusterConfig{Node: "node" + string(rune('a'+i)), Broker: broker, Presence: presence, PresenceTTL: time.Minute})
+		if err != nil {
+			t.Fatal(err)
+		}
+		clusters[i] = cluster
+		runCtx, stop := context.WithCancel(ctx)
+		nodeCtx[i] = stop
+		go cluster.Run(runCtx)
+	}
+	waitFor(t, "subscriptions", func() bool { return broker.subscribers("awebsocket") == 2 })
+
+	peers := newPeers(t, 3)
+	phone, laptop, bob := peers[0], peers[1], peers[2]
+	hubs[0].OnRegister(phone.conn)
+	hubs[1].OnRegister(laptop.conn)
+	hubs[1].OnRegister(bob.conn)
+	hubs[0].OnLogin(&Login{AppId: "app", UserId: "alice", Client: phone.conn})
+	hubs[1].OnLogin(&Login{AppId: "app", UserId: "alice", Client: laptop.conn})
+	hubs[1].OnLogin(&Login{AppId: "app", UserId: "bob", Client: bob.conn})
+	hubs[0].Join("lobby", phone.conn)
+	hubs[1].Join("lobby", bob.conn)
+
+	waitFor(t, "presence", func() bool {
+		nodes, _ := clusters[0].UserNodes("app", "alice")
+		count, _ := clusters[1].OnlineCount()
+		return len(nodes) == 2 && count == 2
+	})
+	if nodes, _ := clusters[0].UserNodes("app", "bob"); len(nodes) != 1 || nodes[0] != "nodeb" {
+		t.Fatalf("unexpected nodes for bob %v", nodes)
+	}
+
+	if err := clusters[1].SendUser("app", "alice", []byte("dm")); err != nil {
+		t.Fatal(err)
+	}
+	phone.expect(t, "dm")
+	laptop.expect(t, "dm")
+
+	// 发送方节点跳过 except，其他节点不受影响 / except is skipped on the sending node only
+	if err := clusters[1].SendRoom("lobby", []byte("room"), bob.conn); err != nil {
+		t.Fatal(err)
+	}
+	phone.expect(t, "room")
+
+	if err := clusters[0].Broadcast([]byte("all")); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range peers {
+		p.expect(t, "all")
+	}
+
+	// 断开连接与节点下线都会更新共享在线状态 / Disconnects and stopped nodes both update the shared presence
+	_ = bob.remote.Close()
+	waitFor(t, "bob offline", func() bool {
+		count, _ := clusters[0].OnlineCount()
+		return count == 1
+	})
+	nodeCtx[0]()
+	waitFor(t, "node a offline", func() bool {
+		nodes, _ := clusters[1].UserNodes("app", "alice")
+		return len(nodes) == 1 && nodes[0] == "nodeb"
+	})
+
+	if _, err := NewCluster(NewClient()); err != ErrNoBroker {
+		t.Fatalf("expected ErrNoBroker, got %v", err)
+	}
+}
+
+// TestClusterReceiveSlowClient 测试慢连接不阻塞集群消息投递 / TestClusterReceiveSlowClient checks a stuck connection cannot stall cluster delivery
+func TestClusterReceiveSlowClient(t *testing.T) {
+	hub := NewClient()
+	cluster, err := NewCluster(hub, ClusterConfig{Node: "nodea", Broker: NewMemoryBroker(), Presence: NewMemoryPresenceStore()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	peers := newPeers(t, 2)
+	alice, bob := peers[0], peers[1]
+	// 写入队列无缓冲且无写协程，任何阻塞写入都会卡住 / An unbuffered queue without a writer blocks any blocking write
+	stuck := &Connection{Socket: bob.conn.Socket, WriteChan: make(chan []byte), CloseChan: make(chan byte, 1)}
+	for _, conn := range []*Connection{alice.conn, stuck} {
+		hub.OnRegister(conn)
+	}
+	hub.OnLogin(&Login{AppId: "app", UserId: "alice", Client: alice.conn})
+	hub.OnLogin(&Login{AppId: "app", UserId: "bob", Client: stuck})
+
+	payload, _ := json.Marshal(clusterMessage{Node: "nodeb", Kind: kindAll, Data: []byte("all")})
+	done := make(chan struct{})
+	go func() {
+		cluster.receive(payload)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("receive blocked on a stuck connection")
+	}
+	alice.expect(t, "all")
+}
+
+// TestRedisPresenceStoreErrors 测试 Redis 不可用时返回错误 / TestRedisPresenceStoreErrors checks errors surface when Redis is unreachable
+func TestRedisPresenceStoreErrors(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	store := &RedisPresenceStore{Client: &aredis.ClientRedis{Clients: client, Ctx: context.Background(), Mode: true}, Prefix: "ws:"}
+
+	if err := store.Online("node", nil, time.Minute); err != nil {
+		t.Fatalf("empty online should not reach Redis: %v", err)
+	}
+	if err := store.Online("node", []string{"a", "b"}, time.Minute); err == nil {
+		t.Fatal("expected an error from Online")
+	}
+	if err := store.Offline("node", []string{"a", "b"}); err == nil {
+		t.Fatal("expected an error from Offline")
+	}
+}
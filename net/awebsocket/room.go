@@ -0,0 +1,142 @@
+package awebsocket
+
+import "sort"
+
+// Presence 房间内的在线用户 / Presence is an online user in a room
+type Presence struct {
+	AppId       string `json:"app_id"`
+	UserId      string `json:"user_id"`
+	Connections int    `json:"connections"` // 该用户在房间内的连接数 / Number of the user's connections in the room
+}
+
+// Join 加入房间，连接未注册或已关闭时返回 false / Join adds the connection to a room, false when it is not registered
+func (get *Client) Join(room string, connection *Connection) bool {
+	get.RoomLock.Lock()
+	defer get.RoomLock.Unlock()
+	// 持有 RoomLock 检查连接，断开时的 LeaveAll 会在之后执行 / Checked under RoomLock so a concurrent LeaveAll runs afterwards
+	if !get.IsClient(connection) {
+		return false
+	}
+	if get.Rooms[room] == nil {
+		get.Rooms[room] = make(map[*Connection]bool)
+	}
+	get.Rooms[room][connection] = true
+	if get.joined[connection] == nil {
+		get.joined[connection] = make(map[string]bool)
+	}
+	get.joined[connection][room] = true
+	return true
+}
+
+// Leave 离开房间，房间为空时删除 / Leave removes the connection from a room, deleting the room once empty
+func (get *Client) Leave(room string, connection *Connection) {
+	get.RoomLock.Lock()
+	defer get.RoomLock.Unlock()
+	get.leaveLocked(room, connection)
+}
+
+// LeaveAll 离开所有房间 / LeaveAll removes the connection from every room
+func (get *Client) LeaveAll(connection *Connection) {
+	get.RoomLock.Lock()
+	defer get.RoomLock.Unlock()
+	for room := range get.joined[connection] {
+		get.leaveLocked(room, connection)
+	}
+}
+
+// leaveLocked 离开房间，调用方需持有 RoomLock / leaveLocked leaves a room; the caller holds RoomLock
+func (get *Client) leaveLocked(room string, connection *Connection) {
+	if members, ok := get.Rooms[room]; ok {
+		delete(members, connection)
+		if len(members) == 0 {
+			delete(get.Rooms, room)
+		}
+	}
+	if rooms, ok := get.joined[connection]; ok {
+		delete(rooms, room)
+		if len(rooms) == 0 {
+			delete(get.joined, connection)
+		}
+	}
+}
+
+// GetRooms 获取全部房间名称 / GetRooms returns the names of all rooms
+func (get *Client) GetRooms() (rooms []string) {
+	get.RoomLock.RLock()
+	defer get.RoomLock.RUnlock()
+	rooms = make([]string, 0, len(get.Rooms))
+	for room := range get.Rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return
+}
+
+// GetJoinedRooms 获取连接加入的房间 / GetJoinedRooms returns the rooms joined by a connection
+func (get *Client) GetJoinedRooms(connection *Connection) (rooms []string) {
+	get.RoomLock.RLock()
+	defer get.RoomLock.RUnlock()
+	rooms = make([]string, 0, len(get.joined[connection]))
+	for room := range get.joined[connection] {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return
+}
+
+// GetRoomClients 获取房间内的连接 / GetRoomClients returns the connections in a room
+func (get *Client) GetRoomClients(room string) (connections []*Connection) {
+	get.RoomLock.RLock()
+	defer get.RoomLock.RUnlock()
+	connections = make([]*Connection, 0, len(get.Rooms[room]))
+	for connection := range get.Rooms[room] {
+		connections = append(connections, connection)
+	}
+	return
+}
+
+// GetRoomCount 获取房间内的连接数 / GetRoomCount returns the number of connections in a room
+func (get *Client) GetRoomCount(room string) int {
+	get.RoomLock.RLock()
+	defer get.RoomLock.RUnlock()
+	return len(get.Rooms[room])
+}
+
+// GetRoomPresence 获取房间内已登录的用户，按平台与用户标识排序，未登录的连接不计入
+// GetRoomPresence lists the logged-in users in a room sorted by app and user id; anonymous connections are left out
+func (get *Client) GetRoomPresence(room string) []Presence {
+	connections := get.GetRoomClients(room)
+
+	get.UserLock.RLock()
+	counts := make(map[string]*Presence)
+	for _, connection := range connections {
+		key, ok := get.userKeys[connection]
+		if !ok {
+			continue
+		}
+		if p, ok := counts[key]; ok {
+			p.Connections++
+			continue
+		}
+		counts[key] = &Presence{AppId: connection.AppId, UserId: connection.UserId, Connections: 1}
+	}
+	get.UserLock.RUnlock()
+
+	presence := make([]Presence, 0, len(counts))
+	for _, p := range counts {
+		presence = append(presence, *p)
+	}
+	sort.Slice(presence, func(i, j int) bool {
+		if presence[i].AppId != presence[j].AppId {
+			return presence[i].AppId < presence[j].AppId
+		}
+		return presence[i].UserId < presence[j].UserId
+	})
+	return presence
+}
+
+// SendRoom 向房间内的连接(除了 except)发送数据，返回送达的连接数
+// SendRoom sends to the connections in a room except one and returns the number reached
+func (get *Client) SendRoom(room string, message []byte, except *Connection) int {
+	return deliver(get.GetRoomClients(room), message, except)
+}
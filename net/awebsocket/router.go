@@ -0,0 +1,442 @@
+package awebsocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/small-ek/antgo/os/alog"
+	"go.uber.org/zap"
+)
+
+// AckEvent 应答消息的事件名，应答的 id 与请求相同 / AckEvent is the event of replies, which carry the id of the request
+const AckEvent = "ack"
+
+// abortIndex 停止后的处理程序下标 / abortIndex is the handler index once the chain is stopped
+const abortIndex = math.MaxInt32
+
+var (
+	// ErrUnknownEvent 事件没有注册处理程序 / ErrUnknownEvent is replied when no handler is registered for the event
+	ErrUnknownEvent = errors.New("unknown event")
+	// ErrUnauthorized 连接未登录 / ErrUnauthorized is replied when the connection is not logged in
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrInternal 处理程序发生 panic / ErrInternal is replied when a handler panics
+	ErrInternal = errors.New("internal error")
+	// ErrBusy 连接待处理的消息已满，消息被丢弃 / ErrBusy is replied when the connection's queue is full and the message is dropped
+	ErrBusy = errors.New("server busy")
+)
+
+// Message 消息信封，带 id 的消息需要应答 / Message is the envelope; messages with an id expect an ack
+type Message struct {
+	Event string          `json:"event"`
+	Id    string          `json:"id,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"` // 仅应答使用 / Only used by acks
+}
+
+// Encode 编码消息 / Encode builds the envelope for an event
+func Encode(event string, data interface{}) ([]byte, error) {
+	return encode(Message{Event: event}, data)
+}
+
+// encode 编码消息体并序列化信封 / encode marshals data into the envelope
+func encode(msg Message, data interface{}) ([]byte, error) {
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		msg.Data = raw
+	}
+	return json.Marshal(msg)
+}
+
+// HandlerFunc 事件处理程序与中间件 / HandlerFunc handles an event or acts as middleware
+type HandlerFunc func(c *Context)
+
+// Handle 将带类型的处理程序转换为 HandlerFunc：data 解码为 T，返回值作为应答
+// Handle adapts a typed handler: data is decoded into T and the result is sent as the ack
+func Handle[T any](fn func(c *Context, payload T) (interface{}, error)) HandlerFunc {
+	return func(c *Context) {
+		var payload T
+		if err := c.Bind(&payload); err != nil {
+			_ = c.Fail(err)
+			return
+		}
+		result, err := fn(c, payload)
+		if err != nil {
+			_ = c.Fail(err)
+			return
+		}
+		_ = c.Reply(result)
+	}
+}
+
+// Context 单条消息的处理上下文 / Context carries one message through the handler chain
+type Context struct {
+	Connection *Connection
+	Hub        *Client
+	Message    *Message
+	router     *Router
+	handlers   []HandlerFunc
+	index      int
+	replied    bool
+	keys       map[string]interface{}
+}
+
+// Event 获取事件名 / Event returns the event name
+func (c *Context) Event() string {
+	return c.Message.Event
+}
+
+// Bind 将 data 解码到 v / Bind decodes data into v
+func (c *Context) Bind(v interface{}) error {
+	if len(c.Message.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(c.Message.Data, v); err != nil {
+		return fmt.Errorf("invalid data: %w", err)
+	}
+	return nil
+}
+
+// Next 执行后续处理程序 / Next runs the remaining handlers
+func (c *Context) Next() {
+	c.index++
+	for c.index < len(c.handlers) {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+// Abort 停止执行后续处理程序 / Abort stops the remaining handlers
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted 是否已停止 / IsAborted reports whether the chain was stopped
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// Set 保存上下文数据 / Set stores a value for later handlers
+func (c *Context) Set(key string, value interface{}) {
+	if c.keys == nil {
+		c.keys = make(map[string]interface{})
+	}
+	c.keys[key] = value
+}
+
+// Get 读取上下文数据 / Get returns a value stored by an earlier handler
+func (c *Context) Get(key string) (value interface{}, ok bool) {
+	value, ok = c.keys[key]
+	return
+}
+
+// Reply 应答请求，消息没有 id 或已应答时忽略 / Reply acks the request; ignored without an id or once replied
+func (c *Context) Reply(data interface{}) error {
+	return c.ack(Message{Event: AckEvent, Id: c.Message.Id}, data)
+}
+
+// Fail 以错误应答请求 / Fail acks the request with an error
+func (c *Context) Fail(err error) error {
+	return c.ack(Message{Event: AckEvent, Id: c.Message.Id, Error: err.Error()}, nil)
+}
+
+// ack 发送应答 / ack sends the reply once
+func (c *Context) ack(msg Message, data interface{}) error {
+	if msg.Id == "" || c.replied {
+		return nil
+	}
+	c.replied = true
+	payload, err := encode(msg, data)
+	if err != nil {
+		return err
+	}
+	return c.Connection.WriteMessage(payload)
+}
+
+// Emit 向当前连接推送事件 / Emit pushes an event to the current connection
+func (c *Context) Emit(event string, data interface{}) error {
+	return Emit(c.Connection, event, data)
+}
+
+// Request 向当前连接发送请求并等待应答 / Request sends a request to the current connection and waits for its ack
+func (c *Context) Request(ctx context.Context, event string, data interface{}, reply interface{}) error {
+	return c.router.Request(ctx, c.Connection, event, data, reply)
+}
+
+// pendingKey 等待应答的请求 / pendingKey identifies a request waiting for its ack
+type pendingKey struct {
+	connection *Connection
+	id         string
+}
+
+// Router 事件路由：按信封中的 event 分发消息，支持中间件、请求应答与服务端推送
+// Router dispatches messages by the envelope's event, with middleware, acks and server push
+type Router struct {
+	Hub        *Client       // 可选，处理程序通过 Context.Hub 访问 / Optional, exposed to handlers as Context.Hub
+	Timeout    time.Duration // Request 默认等待时间，默认 10s / Default wait for Request, defaults to 10s
+	QueueSize  int           // 每个连接待处理的消息数，默认 100 / Messages queued per connection, defaults to 100
+	mu         sync.RWMutex
+	handlers   map[string][]HandlerFunc
+	middleware []HandlerFunc
+	notFound   HandlerFunc
+	pending    map[pendingKey]chan *Message
+	pendingMu  sync.Mutex
+	seq        atomic.Uint64
+}
+
+// NewRouter 创建事件路由 / NewRouter creates a router
+func NewRouter(hub *Client) *Router {
+	return &Router{
+		Hub:       hub,
+		Timeout:   10 * time.Second,
+		QueueSize: 100,
+		handlers:  make(map[string][]HandlerFunc),
+		pending:   make(map[pendingKey]chan *Message),
+	}
+}
+
+// Use 添加中间件，对之后注册的事件生效 / Use adds middleware applied to events registered afterwards
+func (r *Router) Use(middleware ...HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// On 注册事件处理程序，可附带只作用于该事件的中间件 / On registers the handlers of an event, optionally preceded by event-specific middleware
+func (r *Router) On(event string, handlers ...HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	chain := make([]HandlerFunc, 0, len(r.middleware)+len(handlers))
+	chain = append(append(chain, r.middleware...), handlers...)
+	r.handlers[event] = chain
+}
+
+// NotFound 设置未注册事件的处理程序，默认以 ErrUnknownEvent 应答 / NotFound handles unregistered events, replying ErrUnknownEvent by default
+func (r *Router) NotFound(handler HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notFound = handler
+}
+
+// Serve 读取连接的消息并分发直到连接关闭，同一连接的消息按顺序处理。
+// 应答在读取时直接交给等待的 Request，因此处理程序内可以向同一连接发起请求；
+// 待处理队列已满时丢弃新消息并以 ErrBusy 应答，读取不会停止。
+//
+// Serve reads and dispatches the connection's messages until it closes; messages of one connection are handled in order.
+// Acks are handed to the waiting Request as they are read, so handlers may issue requests to the same connection.
+// When the queue is full new messages are dropped with an ErrBusy ack and reading carries on.
+func (r *Router) Serve(connection *Connection) {
+	queue := make(chan *Message, r.QueueSize)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range queue {
+			r.Dispatch(connection, msg)
+		}
+	}()
+	for {
+		data, err := connection.ReadMessage()
+		if err != nil {
+			break
+		}
+		var msg Message
+		if err = json.Unmarshal(data, &msg); err != nil || msg.Event == "" {
+			logWarn("Websocket message is invalid", zap.String("address", connection.Address), zap.Error(err))
+			continue
+		}
+		if msg.Event == AckEvent {
+			r.resolve(connection, &msg)
+			continue
+		}
+		select {
+		case queue <- &msg:
+		default:
+			logWarn("Websocket queue is full, message dropped", zap.String("address", connection.Address), zap.String("event", msg.Event))
+			if msg.Id != "" {
+				if payload, err := encode(Message{Event: AckEvent, Id: msg.Id, Error: ErrBusy.Error()}, nil); err == nil {
+					_ = connection.TryWriteMessage(payload)
+				}
+			}
+		}
+	}
+	close(queue)
+	<-done
+}
+
+// Dispatch 执行事件的处理程序 / Dispatch runs the handlers of the message's event
+func (r *Router) Dispatch(connection *Connection, msg *Message) {
+	r.mu.RLock()
+	handlers, ok := r.handlers[msg.Event]
+	if !ok {
+		notFound := r.notFound
+		if notFound == nil {
+			notFound = func(c *Context) { _ = c.Fail(ErrUnknownEvent) }
+		}
+		handlers = append(append([]HandlerFunc{}, r.middleware...), notFound)
+	}
+	r.mu.RUnlock()
+
+	c := &Context{Connection: connection, Hub: r.Hub, Message: msg, router: r, handlers: handlers, index: -1}
+	c.Next()
+}
+
+// Request 向连接发送请求并等待应答，reply 不为空时解码应答数据；ctx 没有截止时间时使用 Timeout
+// Request sends a request to the connection and waits for its ack, decoding the data into reply when set; Timeout applies when ctx has no deadline
+func (r *Router) Request(ctx context.Context, connection *Connection, event string, data interface{}, reply interface{}) error {
+	if _, ok := ctx.Deadline(); !ok && r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	key := pendingKey{connection: connection, id: "s" + strconv.FormatUint(r.seq.Add(1), 10)}
+	payload, err := encode(Message{Event: event, Id: key.id}, data)
+	if err != nil {
+		return err
+	}
+	wait := make(chan *Message, 1)
+	r.pendingMu.Lock()
+	r.pending[key] = wait
+	r.pendingMu.Unlock()
+	defer func() {
+		r.pendingMu.Lock()
+		delete(r.pending, key)
+		r.pendingMu.Unlock()
+	}()
+
+	if err = connection.WriteMessage(payload); err != nil {
+		return err
+	}
+	select {
+	case msg := <-wait:
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+		if reply != nil && len(msg.Data) > 0 {
+			return json.Unmarshal(msg.Data, reply)
+		}
+		return nil
+	case <-connection.CloseChan:
+		return ErrConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resolve 将应答交给等待的请求，未知的应答被丢弃 / resolve hands an ack to its waiting request; unknown acks are dropped
+func (r *Router) resolve(connection *Connection, msg *Message) {
+	r.pendingMu.Lock()
+	wait, ok := r.pending[pendingKey{connection: connection, id: msg.Id}]
+	r.pendingMu.Unlock()
+	if ok {
+		select {
+		case wait <- msg:
+		default:
+		}
+	}
+}
+
+// ----------------- 推送 / Server push -----------------
+
+// Emit 向连接推送事件 / Emit pushes an event to a connection
+func Emit(connection *Connection, event string, data interface{}) error {
+	payload, err := Encode(event, data)
+	if err != nil {
+		return err
+	}
+	return connection.WriteMessage(payload)
+}
+
+// EmitUser 向用户的所有设备推送事件，返回送达的连接数 / EmitUser pushes an event to every device of a user and returns the number reached
+func (get *Client) EmitUser(appId string, userId string, event string, data interface{}) (int, error) {
+	payload, err := Encode(event, data)
+	if err != nil {
+		return 0, err
+	}
+	return get.SendUser(appId, userId, payload), nil
+}
+
+// EmitRoom 向房间内的连接(除了 except)推送事件，返回送达的连接数 / EmitRoom pushes an event to a room except one connection and returns the number reached
+func (get *Client) EmitRoom(room string, event string, data interface{}, except *Connection) (int, error) {
+	payload, err := Encode(event, data)
+	if err != nil {
+		return 0, err
+	}
+	return get.SendRoom(room, payload, except), nil
+}
+
+// EmitAll 向全部连接推送事件，返回送达的连接数 / EmitAll pushes an event to every connection and returns the number reached
+func (get *Client) EmitAll(event string, data interface{}) (int, error) {
+	payload, err := Encode(event, data)
+	if err != nil {
+		return 0, err
+	}
+	return get.SendClients(payload, nil), nil
+}
+
+// ----------------- 中间件 / Middleware -----------------
+
+// Recovery 捕获处理程序的 panic，记录日志并以 ErrInternal 应答 / Recovery catches handler panics, logs them and replies ErrInternal
+func Recovery() HandlerFunc {
+	return func(c *Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				logWarn("Websocket handler panic", zap.String("event", c.Event()), zap.Any("error", err), zap.Stack("stack"))
+				_ = c.Fail(ErrInternal)
+				c.Abort()
+			}
+		}()
+		c.Next()
+	}
+}
+
+// Logger 记录事件处理耗时 / Logger logs each event and how long it took
+func Logger() HandlerFunc {
+	return func(c *Context) {
+		start := time.Now()
+		c.Next()
+		if alog.Write != nil {
+			alog.Write.Info("Websocket event",
+				zap.String("event", c.Event()),
+				zap.String("id", c.Message.Id),
+				zap.String("address", c.Connection.Address),
+				zap.String("user_id", c.Connection.GetUserId()),
+				zap.Duration("latency", time.Since(start)),
+			)
+		}
+	}
+}
+
+// Auth 认证中间件，未登录的连接以 ErrUnauthorized 应答；check 不为空时由其决定，返回错误即拒绝
+// Auth rejects connections that are not logged in with ErrUnauthorized; a non-nil check decides instead and rejects by returning an error
+func Auth(check ...func(c *Context) error) HandlerFunc {
+	return func(c *Context) {
+		var err error
+		if len(check) > 0 && check[0] != nil {
+			err = check[0](c)
+		} else if !c.Connection.GetLogin() {
+			err = ErrUnauthorized
+		}
+		if err != nil {
+			_ = c.Fail(err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// logWarn 记录警告日志 / logWarn writes a warning
+func logWarn(msg string, fields ...zap.Field) {
+	if alog.Write != nil {
+		alog.Write.Warn(msg, fields...)
+	}
+}
@@ -0,0 +1,153 @@
+package awebsocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// call 客户端发送消息并读取下一条消息 / call sends a message from the client and reads the next one
+func (p peer) call(t *testing.T, msg string) Message {
+	t.Helper()
+	if err := p.remote.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+	return p.read(t)
+}
+
+// read 读取客户端收到的消息信封 / read decodes the next envelope received by the client
+func (p peer) read(t *testing.T) Message {
+	t.Helper()
+	_ = p.remote.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg Message
+	if err := p.remote.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// TestRouter 测试事件分发、中间件与请求应答 / TestRouter covers dispatch, middleware and acks
+func TestRouter(t *testing.T) {
+	hub := NewClient()
+	router := NewRouter(hub)
+	router.Use(Recovery())
+	router.On("chat.send", Handle(func(c *Context, payload struct {
+		Text string `json:"text"`
+	}) (interface{}, error) {
+		if payload.Text == "" {
+			return nil, errors.New("empty text")
+		}
+		return map[string]string{"echo": payload.Text}, nil
+	}))
+	router.On("boom", func(c *Context) { panic("boom") })
+	router.On("ask", func(c *Context) {
+		var answer int
+		err := c.Request(context.Background(), "question", map[string]int{"n": 1}, &answer)
+		_ = c.Reply(map[string]interface{}{"answer": answer, "err": err != nil})
+	})
+	router.Use(Auth())
+	router.On("profile", func(c *Context) { _ = c.Reply(c.Connection.GetUserId()) })
+
+	client := newPeers(t, 1)[0]
+	hub.OnRegister(client.conn)
+	go router.Serve(client.conn)
+
+	if msg := client.call(t, `{"event":"chat.send","id":"1","data":{"text":"hi"}}`); msg.Event != AckEvent || msg.Id != "1" || string(msg.Data) != `{"echo":"hi"}` {
+		t.Fatalf("unexpected ack %+v", msg)
+	}
+	if msg := client.call(t, `{"event":"chat.send","id":"2","data":{}}`); msg.Id != "2" || msg.Error != "empty text" {
+		t.Fatalf("unexpected error ack %+v", msg)
+	}
+	if msg := client.call(t, `{"event":"chat.send","id":"3","data":"text"}`); msg.Id != "3" || msg.Error == "" {
+		t.Fatalf("expected a decoding error, got %+v", msg)
+	}
+	if msg := client.call(t, `{"event":"boom","id":"4"}`); msg.Id != "4" || msg.Error != ErrInternal.Error() {
+		t.Fatalf("expected recovery, got %+v", msg)
+	}
+
+	// Auth 只作用于之后注册的事件 / Auth only applies to events registered after it
+	if msg := client.call(t, `{"event":"profile","id":"6"}`); msg.Error != ErrUnauthorized.Error() {
+		t.Fatalf("expected unauthorized, got %+v", msg)
+	}
+	hub.OnLogin(&Login{AppId: "app", UserId: "alice", Client: client.conn})
+	if msg := client.call(t, `{"event":"profile","id":"7"}`); msg.Error != "" || string(msg.Data) != `"alice"` {
+		t.Fatalf("unexpected profile ack %+v", msg)
+	}
+	if msg := client.call(t, `{"event":"missing","id":"5"}`); msg.Id != "5" || msg.Error != ErrUnknownEvent.Error() {
+		t.Fatalf("expected unknown event, got %+v", msg)
+	}
+
+	// 处理程序向同一连接发起请求 / A handler issues a request to the same connection
+	question := client.call(t, `{"event":"ask","id":"8"}`)
+	if question.Event != "question" || question.Id == "" || string(question.Data) != `{"n":1}` {
+		t.Fatalf("unexpected server request %+v", question)
+	}
+	ack, _ := json.Marshal(Message{Event: AckEvent, Id: question.Id, Data: json.RawMessage("42")})
+	if msg := client.call(t, string(ack)); msg.Id != "8" || string(msg.Data) != `{"answer":42,"err":false}` {
+		t.Fatalf("unexpected ask ack %+v", msg)
+	}
+
+	// 推送与请求超时 / Server push and request timeouts
+	if n, err := hub.EmitUser("app", "alice", "notice", "hello"); err != nil || n != 1 {
+		t.Fatalf("emit failed: %d %v", n, err)
+	}
+	if msg := client.read(t); msg.Event != "notice" || msg.Id != "" || string(msg.Data) != `"hello"` {
+		t.Fatalf("unexpected push %+v", msg)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := router.Request(ctx, client.conn, "question", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if msg := client.read(t); msg.Event != "question" {
+		t.Fatalf("unexpected request %+v", msg)
+	}
+}
+
+// TestRouterBusy 测试队列已满时丢弃消息且继续处理应答 / TestRouterBusy drops messages once the queue is full and keeps resolving acks
+func TestRouterBusy(t *testing.T) {
+	router := NewRouter(nil)
+	router.QueueSize = 1
+	entered, release := make(chan struct{}, 2), make(chan struct{})
+	router.On("slow", func(c *Context) {
+		entered <- struct{}{}
+		<-release
+		_ = c.Reply("done")
+	})
+
+	client := newPeers(t, 1)[0]
+	go router.Serve(client.conn)
+	send := func(msg string) {
+		if err := client.remote.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(`{"event":"slow","id":"1"}`)
+	<-entered
+	send(`{"event":"slow","id":"2"}`)
+	if msg := client.call(t, `{"event":"slow","id":"3"}`); msg.Id != "3" || msg.Error != ErrBusy.Error() {
+		t.Fatalf("expected a busy ack, got %+v", msg)
+	}
+
+	// 处理程序阻塞时应答仍被读取 / Acks are still read while the handler blocks
+	result := make(chan error, 1)
+	go func() { result <- router.Request(context.Background(), client.conn, "ping", nil, nil) }()
+	question := client.read(t)
+	ack, _ := json.Marshal(Message{Event: AckEvent, Id: question.Id})
+	send(string(ack))
+	if err := <-result; err != nil {
+		t.Fatalf("request should resolve while the queue is full: %v", err)
+	}
+
+	close(release)
+	for _, id := range []string{"1", "2"} {
+		if msg := client.read(t); msg.Id != id || string(msg.Data) != `"done"` {
+			t.Fatalf("unexpected ack %+v", msg)
+		}
+	}
+}
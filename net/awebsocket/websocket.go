@@ -7,11 +7,18 @@ import (
 	"sync"
 )
 
+var (
+	// ErrConnectionClosed 连接已关闭 / ErrConnectionClosed is returned once the connection is closed
+	ErrConnectionClosed = errors.New("connection is closed")
+	// ErrWriteQueueFull 写入队列已满 / ErrWriteQueueFull is returned when the write queue is full
+	ErrWriteQueueFull = errors.New("connection write queue is full")
+)
+
 // Connection ...
 type Connection struct {
 	Address       string          // 客户端地址
 	AppId         string          // 登录的平台Id
-	UserId        string          // 用户标识，用户登录以后才有
+	UserId        string          // 用户标识，用户登录以后才有，并发读取请使用 GetUserId
 	LoginTime     uint64          // 登录时间 登录以后才有
 	FirstTime     uint64          // 首次连接事件
 	HeartbeatTime uint64          // 用户上次心跳时间
@@ -22,6 +29,8 @@ type Connection struct {
 	CloseChan     chan byte       // 关闭通道
 	mutex         sync.Mutex      // 对关闭上锁
 	isClosed      bool            // 防止closeChan被关闭多次
+	loginMu       sync.RWMutex    // 保护登录信息 / Guards the login fields
+	loginSeq      uint64          // 最近一次登录的序号，受 Client.UserLock 保护 / Sequence of the latest login, guarded by Client.UserLock
 }
 
 // New ...
@@ -48,7 +57,7 @@ func (get *Connection) ReadMessage() (data []byte, err error) {
 	select {
 	case data = <-get.ReadChan:
 	case <-get.CloseChan:
-		err = errors.New("connection is closeed")
+		err = ErrConnectionClosed
 	}
 	return
 }
@@ -58,11 +67,29 @@ func (get *Connection) WriteMessage(data []byte) (err error) {
 	select {
 	case get.WriteChan <- data:
 	case <-get.CloseChan:
-		err = errors.New("connection is closeed")
+		err = ErrConnectionClosed
 	}
 	return
 }
 
+// TryWriteMessage 非阻塞写入，队列已满时返回 ErrWriteQueueFull
+// TryWriteMessage queues the message without blocking and returns ErrWriteQueueFull when the queue is full
+func (get *Connection) TryWriteMessage(data []byte) error {
+	select {
+	case <-get.CloseChan:
+		return ErrConnectionClosed
+	default:
+	}
+	select {
+	case get.WriteChan <- data:
+		return nil
+	case <-get.CloseChan:
+		return ErrConnectionClosed
+	default:
+		return ErrWriteQueueFull
+	}
+}
+
 // Close Close the connection<关闭连接>
 func (get *Connection) Close() {
 	// 线程安全，可多次调用
@@ -141,20 +168,29 @@ func (get *Connection) GetHeartbeat(currentTime uint64) bool {
 
 // SetLogin 设置用户登录
 func (get *Connection) SetLogin(appId string, userId string, loginTime uint64) {
+	get.loginMu.Lock()
 	get.AppId = appId
 	get.UserId = userId
 	get.LoginTime = loginTime
+	get.loginMu.Unlock()
 	get.SetHeartbeat(loginTime)
 }
 
 // GetLogin 获取是否登录
 func (get *Connection) GetLogin() bool {
-	if get.UserId != "" {
+	if get.GetUserId() != "" {
 		return true
 	}
 	return false
 }
 
+// GetUserId 获取登录的用户标识，可与登录并发调用 / GetUserId returns the logged-in user id, safe alongside a concurrent login
+func (get *Connection) GetUserId() string {
+	get.loginMu.RLock()
+	defer get.loginMu.RUnlock()
+	return get.UserId
+}
+
 // Login 用户登录
 type Login struct {
 	AppId  string
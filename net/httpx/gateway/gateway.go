@@ -0,0 +1,383 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/net/ahttp"
+	"github.com/small-ek/antgo/net/httpx/middleware/agin"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"github.com/small-ek/antgo/utils/conv"
+	"github.com/small-ek/antgo/utils/response"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader 请求标识头，缺失时由网关生成并转发给上游 / RequestIDHeader carries the request id, generated by the gateway when missing
+const RequestIDHeader = "X-Request-Id"
+
+// Route 代理路由，对应配置 gateway.routes，时间使用 "5s" 形式的字符串
+// Route is a proxy route under gateway.routes; durations are strings such as "5s"
+type Route struct {
+	Prefix          string                 `json:"prefix"`           // 路径前缀，如 "/api/orders" / Path prefix such as "/api/orders"
+	Methods         []string               `json:"methods"`          // 允许的方法，为空则全部 / Allowed methods, empty allows all
+	Upstreams       []string               `json:"upstreams"`        // 上游地址，多个时负载均衡 / Upstream base URLs, several enable load balancing
+	Balancer        *ahttp.BalancerOptions `json:"balancer"`         // 负载均衡配置 / Load balancing options
+	Client          string                 `json:"client"`           // 使用 http_clients 中的命名客户端代替 upstreams，只复用其传输层（TLS、代理、熔断、负载均衡、OAuth2），headers、basic/bearer/api_key 认证与请求钩子不生效 / Use a named client from http_clients instead of upstreams; only its transport (TLS, proxy, breaker, balancer, OAuth2) is reused, headers, basic/bearer/api_key auth and request hooks are not applied
+	StripPrefix     bool                   `json:"strip_prefix"`     // 转发前去掉前缀 / Remove the prefix before forwarding
+	PreserveHost    bool                   `json:"preserve_host"`    // 保留客户端的 Host 头 / Keep the client's Host header
+	Timeout         string                 `json:"timeout"`          // 上游请求超时，不限制 WebSocket / Upstream deadline, not applied to WebSocket
+	Retries         int                    `json:"retries"`          // 无请求体的幂等请求在连接失败或 502/503/504 时重试 / Retries for idempotent requests without a body
+	RetryWait       string                 `json:"retry_wait"`       // 首次重试等待，之后翻倍，默认 100ms / First retry wait, doubled afterwards, defaults to 100ms
+	Middlewares     []string               `json:"middlewares"`      // 路由前执行的中间件名称 / Names of the middlewares run before the proxy
+	RequestHeaders  HeaderRules            `json:"request_headers"`  // 转发请求头改写 / Rewrites of the forwarded request headers
+	ResponseHeaders HeaderRules            `json:"response_headers"` // 响应头改写 / Rewrites of the response headers
+}
+
+// HeaderRules 请求头改写规则，按删除、设置、追加的顺序执行 / HeaderRules rewrites headers in the order remove, set, add
+type HeaderRules struct {
+	Set    map[string]string `json:"set"`
+	Add    map[string]string `json:"add"`
+	Remove []string          `json:"remove"`
+}
+
+// apply 改写请求头 / apply rewrites the header
+func (r HeaderRules) apply(header http.Header) {
+	for _, key := range r.Remove {
+		header.Del(key)
+	}
+	for key, value := range r.Set {
+		header.Set(key, value)
+	}
+	for key, value := range r.Add {
+		header.Add(key, value)
+	}
+}
+
+// Proxy 单个路由的反向代理 / Proxy is the reverse proxy of a single route
+type Proxy struct {
+	route   Route
+	prefix  string
+	target  *url.URL
+	client  *ahttp.HttpClient
+	owned   bool
+	timeout time.Duration
+	proxy   *httputil.ReverseProxy
+}
+
+// LoadRoutes 读取 gateway.routes，并在每个路由前加入 gateway.middlewares
+// LoadRoutes reads gateway.routes and prepends gateway.middlewares to every route
+func LoadRoutes() ([]Route, error) {
+	var routes []Route
+	if raw := config.GetMaps("gateway.routes"); len(raw) > 0 {
+		if err := conv.ToStruct(raw, &routes); err != nil {
+			return nil, fmt.Errorf("gateway: parse gateway.routes: %w", err)
+		}
+	}
+	global := config.GetStringSlice("gateway.middlewares")
+	for i := range routes {
+		routes[i].Middlewares = append(slices.Clone(global), routes[i].Middlewares...)
+	}
+	return routes, nil
+}
+
+// Mount 将代理路由挂载到 gin 路由上，未传入路由时读取配置，返回的代理需在服务停止时通过 CloseAll 关闭。
+// 每个路由依次执行请求标识、配置的中间件与代理，前缀本身及其下的全部路径都会转发。
+// 全部路由创建成功后才注册，任一路由失败时关闭已创建的代理且不注册任何路由。
+//
+// Mount registers proxy routes on a gin router, reading the config when no routes are given; close the returned
+// proxies with CloseAll when the server stops. Each route runs the request id handler, its middlewares and then
+// the proxy, for the prefix and every path below it. Routes are only registered once all of them are built, so a
+// failing route closes the proxies created so far and leaves the router untouched.
+func Mount(r gin.IRouter, routes ...Route) ([]*Proxy, error) {
+	if len(routes) == 0 {
+		var err error
+		if routes, err = LoadRoutes(); err != nil {
+			return nil, err
+		}
+	}
+	proxies := make([]*Proxy, 0, len(routes))
+	chains := make([][]gin.HandlerFunc, 0, len(routes))
+	for _, route := range routes {
+		handlers, err := resolveMiddlewares(route.Middlewares)
+		if err != nil {
+			CloseAll(proxies...)
+			return nil, err
+		}
+		p, err := New(route)
+		if err != nil {
+			CloseAll(proxies...)
+			return nil, err
+		}
+		proxies = append(proxies, p)
+		chains = append(chains, handlers)
+	}
+	for i, p := range proxies {
+		p.Register(r, chains[i]...)
+	}
+	return proxies, nil
+}
+
+// CloseAll 关闭多个代理，如 Mount 返回的代理 / CloseAll closes several proxies, such as the ones returned by Mount
+func CloseAll(proxies ...*Proxy) {
+	for _, p := range proxies {
+		p.Close()
+	}
+}
+
+// New 创建路由的反向代理 / New creates the reverse proxy of a route
+func New(route Route) (*Proxy, error) {
+	p := &Proxy{route: route, prefix: strings.TrimRight(route.Prefix, "/")}
+	var err error
+	if p.timeout, err = parseDuration("timeout", route.Timeout); err != nil {
+		return nil, err
+	}
+	wait, err := parseDuration("retry_wait", route.RetryWait)
+	if err != nil {
+		return nil, err
+	}
+	if wait <= 0 {
+		wait = 100 * time.Millisecond
+	}
+
+	switch {
+	case route.Client != "":
+		// 代理经由 Transport 转发，Resty 层的请求头、认证与钩子不会执行 / Proxying goes through Transport, so Resty-level headers, auth and hooks never run
+		client, ok := ahttp.Get(route.Client)
+		if !ok {
+			return nil, fmt.Errorf("gateway: unknown http client %q", route.Client)
+		}
+		p.client = client
+	case len(route.Upstreams) > 0 || route.Balancer != nil:
+		// 重试由网关处理，客户端只提供传输层 / The gateway retries itself, the client only provides the transport
+		noRetry := 0
+		client, err := ahttp.ClientOptions{BaseURLs: route.Upstreams, Balancer: route.Balancer, RetryAttempts: &noRetry}.Build()
+		if err != nil {
+			return nil, fmt.Errorf("gateway: route %q: %w", route.Prefix, err)
+		}
+		p.client, p.owned = client, true
+	default:
+		return nil, fmt.Errorf("gateway: route %q has no upstream", route.Prefix)
+	}
+
+	p.target, err = url.Parse(p.client.Client().BaseURL)
+	if err != nil || p.target.Scheme == "" || p.target.Host == "" {
+		p.Close()
+		return nil, fmt.Errorf("gateway: route %q has an invalid upstream %q", route.Prefix, p.client.Client().BaseURL)
+	}
+
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		Transport:      &retryTransport{client: p.client, retries: route.Retries, wait: wait},
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler:   p.errorHandler,
+	}
+	if alog.Write != nil {
+		p.proxy.ErrorLog = zap.NewStdLog(alog.Write)
+	}
+	return p, nil
+}
+
+// Register 在路由上注册代理，handlers 在代理前执行 / Register adds the proxy to a router, running handlers first
+func (p *Proxy) Register(r gin.IRouter, handlers ...gin.HandlerFunc) {
+	chain := append([]gin.HandlerFunc{requestID}, handlers...)
+	chain = append(chain, p.Handler())
+
+	paths := []string{p.prefix + "/*path"}
+	if p.prefix != "" {
+		paths = append(paths, p.prefix)
+	}
+	for _, path := range paths {
+		if len(p.route.Methods) == 0 {
+			r.Any(path, chain...)
+			continue
+		}
+		for _, method := range p.route.Methods {
+			r.Handle(strings.ToUpper(method), path, chain...)
+		}
+	}
+}
+
+// Handler 返回转发请求的处理函数 / Handler returns the handler that forwards the request
+func (p *Proxy) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := c.Request
+		// WebSocket 为长连接，不设置截止时间 / WebSocket connections are long-lived and get no deadline
+		if p.timeout > 0 && !isUpgrade(req) {
+			ctx, cancel := context.WithTimeout(req.Context(), p.timeout)
+			defer cancel()
+			req = req.WithContext(ctx)
+		}
+		p.proxy.ServeHTTP(responseWriter{c.Writer}, req)
+	}
+}
+
+// responseWriter 仅暴露 Unwrap，刷新与劫持经 http.ResponseController 传递给 gin，
+// 不再使用已废弃的 CloseNotify（客户端断开由请求上下文感知）。
+//
+// responseWriter exposes only Unwrap so flushing and hijacking reach gin through http.ResponseController,
+// leaving out the deprecated CloseNotify; client disconnects are seen through the request context.
+type responseWriter struct {
+	http.ResponseWriter
+}
+
+// Unwrap 返回底层写入器 / Unwrap returns the underlying writer
+func (w responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close 关闭路由自行创建的上游客户端 / Close stops the upstream client created for the route
+func (p *Proxy) Close() {
+	if p.owned {
+		p.client.Close()
+	}
+}
+
+// rewrite 改写转发请求 / rewrite rewrites the outgoing request
+func (p *Proxy) rewrite(pr *httputil.ProxyRequest) {
+	if p.route.StripPrefix && p.prefix != "" {
+		pr.Out.URL.Path = trimPrefix(pr.Out.URL.Path, p.prefix)
+		if pr.Out.URL.RawPath != "" {
+			pr.Out.URL.RawPath = trimPrefix(pr.Out.URL.RawPath, p.prefix)
+		}
+	}
+	pr.SetURL(p.target)
+	// 在已有的转发链后追加客户端地址 / Append the client address to the existing forwarding chain
+	pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+	pr.SetXForwarded()
+	if p.route.PreserveHost {
+		pr.Out.Host = pr.In.Host
+	}
+	p.route.RequestHeaders.apply(pr.Out.Header)
+}
+
+// modifyResponse 改写上游响应头 / modifyResponse rewrites the upstream response headers
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	if id := resp.Request.Header.Get(RequestIDHeader); id != "" {
+		resp.Header.Set(RequestIDHeader, id)
+	}
+	p.route.ResponseHeaders.apply(resp.Header)
+	return nil
+}
+
+// errorHandler 上游失败时返回统一的失败结构 / errorHandler answers upstream failures with the unified failure body
+func (p *Proxy) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	status, msg := http.StatusBadGateway, "bad gateway"
+	var (
+		rejected *ahttp.RejectedError
+		netErr   net.Error
+	)
+	switch {
+	case errors.Is(err, context.Canceled) && r.Context().Err() != nil:
+		// 客户端已断开 / The client went away
+		w.WriteHeader(499)
+		return
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		status, msg = http.StatusGatewayTimeout, "upstream timeout"
+	case errors.Is(err, ahttp.ErrNoEndpoint), errors.As(err, &rejected):
+		status, msg = http.StatusServiceUnavailable, "upstream unavailable"
+	}
+
+	alog.Write.Warn("Gateway upstream failed",
+		zap.String("prefix", p.route.Prefix),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("request_id", r.Header.Get(RequestIDHeader)),
+		zap.Error(err),
+	)
+
+	body, _ := json.Marshal(response.Fail(strconv.Itoa(status), msg))
+	w.Header().Set(RequestIDHeader, r.Header.Get(RequestIDHeader))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// requestID 确保请求带有请求标识并写入上下文 / requestID makes sure the request carries an id and stores it in the context
+var requestID = agin.WithContextRequestID()
+
+// retryTransport 重试无请求体的幂等请求 / retryTransport retries idempotent requests without a body
+type retryTransport struct {
+	client  *ahttp.HttpClient
+	retries int
+	wait    time.Duration
+}
+
+// RoundTrip 执行请求 / RoundTrip executes the request
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retryable := t.retries > 0 && (req.Body == nil || req.Body == http.NoBody) && isIdempotent(req.Method)
+	for attempt := 0; ; attempt++ {
+		resp, err := t.client.Transport().RoundTrip(req)
+		if !retryable || attempt >= t.retries || req.Context().Err() != nil || !shouldRetry(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			_ = resp.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(t.wait << attempt):
+		}
+	}
+}
+
+// shouldRetry 连接失败或上游不可用时重试 / shouldRetry retries on transport errors and unavailable upstreams
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isIdempotent 判断是否为幂等方法 / isIdempotent reports whether the method is idempotent
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// isUpgrade 判断是否为协议升级请求 / isUpgrade reports whether the request asks for a protocol upgrade
+func isUpgrade(r *http.Request) bool {
+	return r.Header.Get("Upgrade") != "" && strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+// trimPrefix 去掉路径前缀并保留开头的斜杠 / trimPrefix strips the prefix and keeps a leading slash
+func trimPrefix(path, prefix string) string {
+	rest := strings.TrimPrefix(path, prefix)
+	if !strings.HasPrefix(rest, "/") {
+		rest = "/" + rest
+	}
+	return rest
+}
+
+// parseDuration 解析时间配置 / parseDuration parses a duration option
+func parseDuration(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("gateway: invalid %s %q: %w", name, value, err)
+	}
+	return d, nil
+}
@@ -0,0 +1,196 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/small-ek/antgo/os/alog"
+	"go.uber.org/zap"
+)
+
+// TestMain 初始化测试所需的日志与 gin 模式 / TestMain sets up logging and gin mode for the tests
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	alog.Write = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// serve 挂载路由并发送请求 / serve mounts the routes and sends one request
+func serve(t *testing.T, req *http.Request, routes ...Route) *httptest.ResponseRecorder {
+	t.Helper()
+	app := gin.New()
+	proxies, err := Mount(app, routes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer CloseAll(proxies...)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	return w
+}
+
+// TestGatewayRewrite 测试路径、请求头改写、请求标识与中间件 / TestGatewayRewrite covers path and header rewriting, request ids and middlewares
+func TestGatewayRewrite(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Internal", "secret")
+		_, _ = io.WriteString(w, strings.Join([]string{
+			r.URL.RequestURI(), r.Header.Get(RequestIDHeader), r.Header.Get("X-From"), r.Header.Get("Cookie"), r.Header.Get("X-Forwarded-For"),
+		}, "|"))
+	}))
+	defer upstream.Close()
+
+	RegisterMiddleware("deny", func(c *gin.Context) {
+		if c.GetHeader("X-Block") != "" {
+			c.AbortWithStatus(http.StatusForbidden)
+		}
+	})
+	route := Route{
+		Prefix:          "/api/orders/",
+		Upstreams:       []string{upstream.URL + "/v1"},
+		StripPrefix:     true,
+		Middlewares:     []string{"deny"},
+		RequestHeaders:  HeaderRules{Set: map[string]string{"x-from": "gateway"}, Remove: []string{"Cookie"}},
+		ResponseHeaders: HeaderRules{Remove: []string{"X-Internal"}, Set: map[string]string{"X-Served-By": "gateway"}},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/items?id=1", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.Header.Set("Cookie", "session=1")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	w := serve(t, req, route)
+	id := w.Header().Get(RequestIDHeader)
+	if id == "" || w.Body.String() != "/v1/items?id=1|"+id+"|gateway||10.0.0.1, 10.0.0.2" {
+		t.Fatalf("unexpected upstream view %q (request id %q)", w.Body.String(), id)
+	}
+	if w.Header().Get("X-Internal") != "" || w.Header().Get("X-Served-By") != "gateway" {
+		t.Fatalf("response headers not rewritten: %v", w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader("{}"))
+	req.Header.Set(RequestIDHeader, "req-1")
+	if w = serve(t, req, route); !strings.HasPrefix(w.Body.String(), "/v1/|req-1|") || w.Header().Get(RequestIDHeader) != "req-1" {
+		t.Fatalf("unexpected upstream view %q", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/orders/items", nil)
+	req.Header.Set("X-Block", "1")
+	if w = serve(t, req, route); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the middleware to block, got %d", w.Code)
+	}
+
+	// 任一路由失败时不注册任何路由 / A failing route leaves the router untouched
+	app := gin.New()
+	if _, err := Mount(app, Route{Prefix: "/ok", Upstreams: []string{upstream.URL}}, Route{Prefix: "/x", Upstreams: []string{upstream.URL}, Middlewares: []string{"missing"}}); err == nil {
+		t.Fatal("expected an unknown middleware error")
+	}
+	if routes := app.Routes(); len(routes) != 0 {
+		t.Fatalf("expected no routes after a failed mount, got %d", len(routes))
+	}
+}
+
+// TestGatewayFailures 测试重试、超时、负载均衡与上游不可用 / TestGatewayFailures covers retries, timeouts, balancing and dead upstreams
+func TestGatewayFailures(t *testing.T) {
+	var calls atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		if calls.Add(1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer flaky.Close()
+	route := Route{Prefix: "/api", Upstreams: []string{flaky.URL}, Retries: 2, RetryWait: "1ms", Timeout: "100ms"}
+
+	if w := serve(t, httptest.NewRequest(http.MethodGet, "/api/items", nil), route); w.Code != http.StatusOK || calls.Load() != 3 {
+		t.Fatalf("expected success after retries, got %d after %d calls", w.Code, calls.Load())
+	}
+	calls.Store(0)
+	if w := serve(t, httptest.NewRequest(http.MethodPost, "/api/items", strings.NewReader("{}")), route); w.Code != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Fatalf("expected no retry for POST, got %d after %d calls", w.Code, calls.Load())
+	}
+	if w := serve(t, httptest.NewRequest(http.MethodGet, "/api/slow", nil), route); w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), "upstream timeout") {
+		t.Fatalf("expected a timeout, got %d %q", w.Code, w.Body.String())
+	}
+
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	if w := serve(t, httptest.NewRequest(http.MethodGet, "/api/items", nil), Route{Prefix: "/api", Upstreams: []string{dead.URL}}); w.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 for a dead upstream, got %d", w.Code)
+	}
+
+	a := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { _, _ = io.WriteString(w, "a") }))
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { _, _ = io.WriteString(w, "b") }))
+	defer a.Close()
+	defer b.Close()
+	app := gin.New()
+	proxies, err := Mount(app, Route{Prefix: "/lb", Upstreams: []string{a.URL, b.URL, dead.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer CloseAll(proxies...)
+	seen := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/lb/", nil))
+		seen[w.Body.String()]++
+	}
+	if seen["a"] == 0 || seen["b"] == 0 || seen["a"]+seen["b"] != 6 {
+		t.Fatalf("unexpected balancing %v", seen)
+	}
+}
+
+// TestGatewayWebSocket 测试 WebSocket 透传且不受超时限制 / TestGatewayWebSocket covers WebSocket passthrough without the deadline
+func TestGatewayWebSocket(t *testing.T) {
+	var upgrader websocket.Upgrader
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			kind, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = conn.WriteMessage(kind, append([]byte(r.URL.Path+":"), msg...))
+		}
+	}))
+	defer upstream.Close()
+
+	app := gin.New()
+	proxies, err := Mount(app, Route{Prefix: "/ws", Upstreams: []string{upstream.URL, strings.Replace(upstream.URL, "127.0.0.1", "localhost", 1)}, StripPrefix: true, Timeout: "50ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer CloseAll(proxies...)
+	gateway := httptest.NewServer(app)
+	defer gateway.Close()
+
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(gateway.URL, "http")+"/ws/chat", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if resp.Header.Get(RequestIDHeader) == "" {
+		t.Error("expected a request id on the upgrade response")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != "/chat:hi" {
+		t.Fatalf("unexpected echo %q: %v", msg, err)
+	}
+}
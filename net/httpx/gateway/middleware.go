@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/net/httpx/middleware/agin"
+)
+
+// middlewares 按名称引用的中间件，内置项在挂载时才创建以读取最新配置
+// middlewares maps names to middleware factories; built-ins are created at mount time so they read the current config
+var (
+	middlewares = map[string]func() gin.HandlerFunc{
+		"recovery":   func() gin.HandlerFunc { return agin.Recovery() },
+		"logger":     agin.Logger,
+		"access_log": func() gin.HandlerFunc { return agin.AccessLog() },
+		"request_id": agin.WithContextRequestID,
+		"cors":       func() gin.HandlerFunc { return agin.CORS() },
+		"secure":     func() gin.HandlerFunc { return agin.Secure() },
+		"ip_filter":  func() gin.HandlerFunc { return agin.IPFilter() },
+		"signature":  func() gin.HandlerFunc { return agin.Signature() },
+		"authorize":  func() gin.HandlerFunc { return agin.AuthorizeRoutes() },
+		"mirror":     func() gin.HandlerFunc { return agin.Mirror() },
+	}
+	middlewaresMu sync.RWMutex
+)
+
+// RegisterMiddleware 注册可在路由 middlewares 中引用的中间件，同名时覆盖内置项，
+// 需要参数的中间件（如 agin.JWTAuth、限流）在挂载前注册。
+//
+// RegisterMiddleware makes a handler available to route middlewares by name, replacing a built-in of the same name.
+// Register middlewares that need arguments, such as agin.JWTAuth or a rate limiter, before mounting.
+func RegisterMiddleware(name string, handler gin.HandlerFunc) {
+	middlewaresMu.Lock()
+	defer middlewaresMu.Unlock()
+	middlewares[name] = func() gin.HandlerFunc { return handler }
+}
+
+// resolveMiddlewares 按名称查找中间件 / resolveMiddlewares looks up middlewares by name
+func resolveMiddlewares(names []string) ([]gin.HandlerFunc, error) {
+	middlewaresMu.RLock()
+	defer middlewaresMu.RUnlock()
+	handlers := make([]gin.HandlerFunc, 0, len(names))
+	for _, name := range names {
+		factory, ok := middlewares[name]
+		if !ok {
+			return nil, fmt.Errorf("gateway: unknown middleware %q", name)
+		}
+		handlers = append(handlers, factory())
+	}
+	return handlers, nil
+}
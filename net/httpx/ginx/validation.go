@@ -0,0 +1,131 @@
+package ginx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/small-ek/antgo/i18n"
+	"github.com/small-ek/antgo/utils/response"
+)
+
+// defaultValidationMessages 缺少翻译时使用的默认提示 / Default messages used when no translation exists
+var defaultValidationMessages = map[string]string{
+	"required": "%s is required",
+	"email":    "%s must be a valid email address",
+	"url":      "%s must be a valid URL",
+	"min":      "%s must be at least %s",
+	"max":      "%s must be at most %s",
+	"len":      "%s must be exactly %s",
+	"gte":      "%s must be greater than or equal to %s",
+	"lte":      "%s must be less than or equal to %s",
+	"gt":       "%s must be greater than %s",
+	"lt":       "%s must be less than %s",
+	"oneof":    "%s must be one of [%s]",
+	"numeric":  "%s must be numeric",
+}
+
+// UseJSONFieldNames 让 gin 的校验器使用 json/form 标签作为字段名，使错误字段与请求参数一致，需在启动时显式调用。
+// 该设置作用于 binding.Validator，会影响进程内所有 gin 校验错误。
+//
+// UseJSONFieldNames makes gin's validator report the json/form tag as the field name so errors match the request
+// parameters. It changes binding.Validator for the whole process, so call it explicitly at startup.
+func UseJSONFieldNames() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(fieldTagName)
+	}
+}
+
+// fieldTagName 依次读取 json、form 标签 / fieldTagName reads the json tag, then the form tag
+func fieldTagName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// ValidationMessages 将校验错误转换为 字段 -> 本地化提示，翻译键为 validation.<tag>，字段名翻译键为 fields.<field>。
+// 翻译模板的第一个参数为字段名，带参数的规则（如 min）第二个参数为规则参数。
+//
+// ValidationMessages turns validator errors into a field -> localized message map using the validation.<tag> keys
+// and fields.<field> for field names. Templates receive the field name first and the rule parameter second.
+func ValidationMessages(c *gin.Context, err error) map[string]string {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+
+	messages := make(map[string]string, len(errs))
+	for _, fe := range errs {
+		field := fieldPath(fe)
+		name := i18n.TDefault(c, "fields."+field, fe.Field())
+
+		args := []interface{}{name}
+		if fe.Param() != "" {
+			args = append(args, fe.Param())
+		}
+
+		fallback, ok := defaultValidationMessages[fe.Tag()]
+		if !ok {
+			fallback = "%s is invalid"
+		}
+		template := i18n.TDefault(c, "validation."+fe.Tag(), fallback)
+		messages[field] = formatMessage(template, args)
+	}
+	return messages
+}
+
+// ValidationFail 构造包含字段错误的失败响应 / ValidationFail builds a failure body carrying the field errors
+func ValidationFail(c *gin.Context, err error) *response.Write {
+	messages := ValidationMessages(c, err)
+	result := response.Fail("400", i18n.TDefault(c, "validation.failed", "validation failed"))
+	if messages != nil {
+		result.Error = messages
+	} else if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Bind 绑定并校验请求参数，失败时以 400 返回本地化错误并终止请求
+// Bind binds and validates the request, aborting with a localized 400 response on failure
+func Bind(c *gin.Context, obj any) bool {
+	if err := c.ShouldBind(obj); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ValidationFail(c, err))
+		return false
+	}
+	return true
+}
+
+// fieldPath 去掉顶层结构体名的字段路径，如 profile.name
+// fieldPath returns the namespace without the top-level struct, e.g. profile.name
+func fieldPath(fe validator.FieldError) string {
+	if _, rest, found := strings.Cut(fe.Namespace(), "."); found {
+		return rest
+	}
+	return fe.Field()
+}
+
+// formatMessage 按模板中的占位符个数格式化，避免多余参数输出 %!(EXTRA ...)
+// formatMessage formats with as many args as the template has verbs, avoiding %!(EXTRA ...)
+func formatMessage(template string, args []interface{}) string {
+	verbs := strings.Count(template, "%") - 2*strings.Count(template, "%%")
+	if verbs <= 0 {
+		return template
+	}
+	if verbs < len(args) {
+		args = args[:verbs]
+	}
+	return fmt.Sprintf(template, args...)
+}
@@ -0,0 +1,116 @@
+package ginx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/i18n"
+)
+
+// TestBind 测试校验失败时返回字段 -> 提示的失败响应 / TestBind checks the field -> message failure body
+func TestBind(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	UseJSONFieldNames()
+	type profile struct {
+		Name string `json:"name" binding:"required"`
+	}
+	type request struct {
+		Email   string  `json:"email" binding:"required,email"`
+		Age     int     `json:"age" binding:"min=18"`
+		Profile profile `json:"profile"`
+	}
+
+	app := gin.New()
+	app.POST("/", func(c *gin.Context) {
+		var req request
+		if !Bind(c, &req) {
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email":"bad","age":3}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+
+	var body struct {
+		Code  string            `json:"code"`
+		Error map[string]string `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unexpected body %q: %v", w.Body.String(), err)
+	}
+	expected := map[string]string{
+		"email":        "email must be a valid email address",
+		"age":          "age must be at least 18",
+		"profile.name": "name is required",
+	}
+	for field, msg := range expected {
+		if body.Error[field] != msg {
+			t.Errorf("%s: expected %q, got %q", field, msg, body.Error[field])
+		}
+	}
+	if body.Code != "400" {
+		t.Errorf("expected code 400, got %q", body.Code)
+	}
+}
+
+// TestBindLocalized 测试语言包中存在翻译时使用本地化提示 / TestBindLocalized uses the bundle messages when they exist
+func TestBindLocalized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	UseJSONFieldNames()
+	dir := t.TempDir()
+	bundle := `{"validation":{"failed":"参数校验失败","required":"%s不能为空"},"fields":{"email":"邮箱"}}`
+	if err := os.WriteFile(filepath.Join(dir, "zh-CN.json"), []byte(bundle), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := i18n.New(i18n.Config{DefaultLang: "zh-CN", SupportedLangs: []string{"zh-CN"}, TranslationsDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	app := gin.New()
+	app.Use(i18n.Middleware())
+	app.POST("/", func(c *gin.Context) {
+		var req struct {
+			Email string `json:"email" binding:"required"`
+			Age   int    `json:"age" binding:"min=18"`
+		}
+		if !Bind(c, &req) {
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"age":3}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	var body struct {
+		Message string            `json:"message"`
+		Error   map[string]string `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unexpected body %q: %v", w.Body.String(), err)
+	}
+	if body.Message != "参数校验失败" {
+		t.Errorf("expected the localized summary, got %q", body.Message)
+	}
+	if body.Error["email"] != "邮箱不能为空" {
+		t.Errorf("expected the localized message, got %q", body.Error["email"])
+	}
+	// 缺少翻译的规则仍使用默认提示 / Rules without a translation keep the default message
+	if body.Error["age"] != "age must be at least 18" {
+		t.Errorf("expected the default message, got %q", body.Error["age"])
+	}
+}
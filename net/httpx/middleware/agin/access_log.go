@@ -0,0 +1,277 @@
+package agin
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"go.uber.org/zap"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// 访问日志格式 / Access log formats
+const (
+	AccessLogCombined = "combined" // Apache/Nginx combined 格式 / Apache/Nginx combined format
+	AccessLogCommon   = "common"   // Apache/Nginx common 格式 / Apache/Nginx common format
+	AccessLogJSON     = "json"     // 固定字段的 JSON 行 / JSON lines with a fixed schema
+)
+
+// defaultAccessLogFields JSON 格式默认字段，顺序即输出顺序 / Default JSON fields, in output order
+var defaultAccessLogFields = []string{
+	"time", "remote_addr", "method", "path", "query", "protocol", "status",
+	"bytes", "latency_ms", "referer", "user_agent", "request_id",
+}
+
+// accessLogFieldSet 支持的 JSON 字段 / Supported JSON fields
+var accessLogFieldSet = map[string]bool{
+	"time": true, "remote_addr": true, "host": true, "method": true, "path": true, "query": true,
+	"protocol": true, "status": true, "bytes": true, "latency_ms": true, "referer": true,
+	"user_agent": true, "request_id": true,
+}
+
+// AccessLogConfig 访问日志配置，对应配置文件 log.access.*，轮转参数缺省时沿用 log.*
+// AccessLogConfig configures the access log under log.access.*; rotation settings fall back to log.*
+type AccessLogConfig struct {
+	Path       string    // 日志文件路径，默认 "./log/access.log" / File path, defaults to "./log/access.log"
+	Format     string    // combined、common 或 json，默认 combined / combined, common or json, defaults to combined
+	Fields     []string  // JSON 格式输出的字段 / Fields written in JSON format
+	MaxSize    int       // 分割大小（MB） / Rotation size in MB
+	MaxBackups int       // 保留备份数 / Number of backups kept
+	MaxAge     int       // 保留天数 / Days to keep
+	Compress   bool      // 是否压缩 / Compress rotated files
+	Writer     io.Writer // 自定义输出，设置后忽略文件配置 / Custom output, overrides the file settings
+}
+
+// LoadAccessLogConfig 从配置文件读取访问日志配置 / LoadAccessLogConfig reads log.access.* from the config
+func LoadAccessLogConfig() AccessLogConfig {
+	conf := AccessLogConfig{
+		Path:       config.GetString("log.access.path"),
+		Format:     config.GetString("log.access.format"),
+		Fields:     config.GetStringSlice("log.access.fields"),
+		MaxSize:    config.GetInt("log.access.max_size"),
+		MaxBackups: config.GetInt("log.access.max_backups"),
+		MaxAge:     config.GetInt("log.access.max_age"),
+		Compress:   config.GetBool("log.access.compress"),
+	}
+	if conf.MaxSize <= 0 {
+		conf.MaxSize = config.GetInt("log.max_size")
+	}
+	if conf.MaxBackups <= 0 {
+		conf.MaxBackups = config.GetInt("log.max_backups")
+	}
+	if conf.MaxAge <= 0 {
+		conf.MaxAge = config.GetInt("log.max_age")
+	}
+	return conf
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *AccessLogConfig) setDefaults() {
+	if cfg.Path == "" {
+		cfg.Path = "./log/access.log"
+	}
+	cfg.Format = strings.ToLower(cfg.Format)
+	if cfg.Format != AccessLogCommon && cfg.Format != AccessLogJSON {
+		cfg.Format = AccessLogCombined
+	}
+	if len(cfg.Fields) == 0 {
+		cfg.Fields = defaultAccessLogFields
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 100
+	}
+}
+
+// accessLogWriters 按路径复用轮转文件，避免多个实例写同一文件 / accessLogWriters shares rotating files per path
+var accessLogWriters sync.Map
+
+// accessLogger 访问日志写入器 / accessLogger writes access log lines
+type accessLogger struct {
+	format string
+	fields []string
+	out    io.Writer
+}
+
+// newAccessLogger 创建访问日志写入器 / newAccessLogger creates the access log writer
+func newAccessLogger(conf AccessLogConfig) *accessLogger {
+	conf.setDefaults()
+
+	fields := make([]string, 0, len(conf.Fields))
+	for _, f := range conf.Fields {
+		if !accessLogFieldSet[f] {
+			alog.Write.Warn("Unknown access log field", zap.String("field", f))
+			continue
+		}
+		fields = append(fields, f)
+	}
+
+	out := conf.Writer
+	if out == nil {
+		w, _ := accessLogWriters.LoadOrStore(conf.Path, &lumberjack.Logger{
+			Filename:   conf.Path,
+			MaxSize:    conf.MaxSize,
+			MaxBackups: conf.MaxBackups,
+			MaxAge:     conf.MaxAge,
+			Compress:   conf.Compress,
+		})
+		out = w.(io.Writer)
+	}
+	return &accessLogger{format: conf.Format, fields: fields, out: out}
+}
+
+// AccessLog 访问日志中间件，按 combined/common/json 格式写入独立的轮转文件，未传入配置时读取 log.access.*
+// AccessLog writes combined/common/json access lines to a dedicated rotating file, reading log.access.* when no config is given
+func AccessLog(cfg ...AccessLogConfig) gin.HandlerFunc {
+	var conf AccessLogConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	} else {
+		conf = LoadAccessLogConfig()
+	}
+	logger := newAccessLogger(conf)
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		logger.write(c, start, time.Since(start))
+	}
+}
+
+// write 格式化并写出一行访问日志 / write formats and writes one access line
+func (l *accessLogger) write(c *gin.Context, start time.Time, latency time.Duration) {
+	buf := apiBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer putBackBuffer(buf)
+
+	if l.format == AccessLogJSON {
+		l.writeJSON(buf, c, start, latency)
+	} else {
+		l.writeCLF(buf, c, start)
+	}
+	buf.WriteByte('\n')
+	if _, err := l.out.Write(buf.Bytes()); err != nil {
+		alog.Write.Error("Write access log failed", zap.Error(err))
+	}
+}
+
+// writeCLF 输出 common/combined 格式 / writeCLF writes the common/combined format
+func (l *accessLogger) writeCLF(buf *bytes.Buffer, c *gin.Context, start time.Time) {
+	req := c.Request
+	user := "-"
+	if name, _, ok := req.BasicAuth(); ok && name != "" {
+		// 用户名字段不带引号，空格同样需要转义 / The user field is unquoted, so spaces are escaped as well
+		user = strings.ReplaceAll(clfEscape(name), " ", `\x20`)
+	}
+
+	buf.WriteString(c.ClientIP())
+	buf.WriteString(" - ")
+	buf.WriteString(user)
+	buf.WriteString(" [")
+	buf.WriteString(start.Format("02/Jan/2006:15:04:05 -0700"))
+	buf.WriteString(`] "`)
+	buf.WriteString(req.Method)
+	buf.WriteByte(' ')
+	buf.WriteString(clfEscape(req.URL.RequestURI()))
+	buf.WriteByte(' ')
+	buf.WriteString(req.Proto)
+	buf.WriteString(`" `)
+	buf.WriteString(strconv.Itoa(c.Writer.Status()))
+	buf.WriteByte(' ')
+	if size := c.Writer.Size(); size > 0 {
+		buf.WriteString(strconv.Itoa(size))
+	} else {
+		buf.WriteByte('-')
+	}
+	if l.format == AccessLogCombined {
+		buf.WriteString(` "`)
+		buf.WriteString(clfValue(req.Referer()))
+		buf.WriteString(`" "`)
+		buf.WriteString(clfValue(req.UserAgent()))
+		buf.WriteByte('"')
+	}
+}
+
+// writeJSON 输出 JSON 行 / writeJSON writes a JSON line
+func (l *accessLogger) writeJSON(buf *bytes.Buffer, c *gin.Context, start time.Time, latency time.Duration) {
+	req := c.Request
+	buf.WriteByte('{')
+	for i, field := range l.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('"')
+		buf.WriteString(field)
+		buf.WriteString(`":`)
+
+		var value interface{}
+		switch field {
+		case "time":
+			value = start.Format("2006-01-02T15:04:05.000Z07:00")
+		case "remote_addr":
+			value = c.ClientIP()
+		case "host":
+			value = req.Host
+		case "method":
+			value = req.Method
+		case "path":
+			value = req.URL.Path
+		case "query":
+			value = req.URL.RawQuery
+		case "protocol":
+			value = req.Proto
+		case "status":
+			value = c.Writer.Status()
+		case "bytes":
+			value = max(c.Writer.Size(), 0)
+		case "latency_ms":
+			value = float64(latency.Microseconds()) / 1000
+		case "referer":
+			value = req.Referer()
+		case "user_agent":
+			value = req.UserAgent()
+		case "request_id":
+			value = getRequestID(c)
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			encoded = []byte("null")
+		}
+		buf.Write(encoded)
+	}
+	buf.WriteByte('}')
+}
+
+// clfValue 空值输出 "-" 并转义引号 / clfValue writes "-" for empty values and escapes quotes
+func clfValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return clfEscape(s)
+}
+
+// clfEscape 转义引号、反斜杠与控制字符，防止日志注入 / clfEscape escapes quotes, backslashes and control characters
+func clfEscape(s string) string {
+	if !strings.ContainsAny(s, "\"\\") && strings.IndexFunc(s, func(r rune) bool { return r < 0x20 || r == 0x7f }) < 0 {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			b.WriteString(`\x`)
+			b.WriteString(strconv.FormatInt(int64(r)|0x100, 16)[1:])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
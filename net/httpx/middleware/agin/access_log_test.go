@@ -0,0 +1,54 @@
+package agin
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestAccessLog 测试 combined 与 JSON 访问日志格式 / TestAccessLog covers the combined and JSON formats
+func TestAccessLog(t *testing.T) {
+	serve := func(conf AccessLogConfig, user ...string) string {
+		var out bytes.Buffer
+		conf.Writer = &out
+		app := gin.New()
+		app.Use(AccessLog(conf))
+		app.GET("/items", func(c *gin.Context) { c.String(http.StatusOK, "hello") })
+
+		req := httptest.NewRequest(http.MethodGet, "/items?id=1", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Referer", "https://example.com/")
+		req.Header.Set("User-Agent", `curl "x"`)
+		req.Header.Set("X-Request-Id", "req-1")
+		if len(user) > 0 {
+			req.SetBasicAuth(user[0], "secret")
+		}
+		app.ServeHTTP(httptest.NewRecorder(), req)
+		return out.String()
+	}
+
+	combined := serve(AccessLogConfig{Format: AccessLogCombined})
+	pattern := regexp.MustCompile(`^10\.0\.0\.1 - - \[[^\]]+\] "GET /items\?id=1 HTTP/1\.1" 200 5 "https://example\.com/" "curl \\"x\\""\n$`)
+	if !pattern.MatchString(combined) {
+		t.Errorf("unexpected combined line %q", combined)
+	}
+
+	// 用户名不能伪造日志字段或新行 / A user name cannot forge fields or lines
+	forged := serve(AccessLogConfig{Format: AccessLogCommon}, "bob\" 200 1\n10.0.0.2 - admin")
+	if !strings.HasPrefix(forged, `10.0.0.1 - bob\"\x20200\x201\x0a10.0.0.2\x20-\x20admin [`) || strings.Count(forged, "\n") != 1 {
+		t.Errorf("user name was not escaped: %q", forged)
+	}
+
+	line := serve(AccessLogConfig{Format: AccessLogJSON, Fields: []string{"status", "path", "query", "request_id", "unknown"}})
+	if line != `{"status":200,"path":"/items","query":"id=1","request_id":"req-1"}`+"\n" {
+		t.Errorf("unexpected json line %q", line)
+	}
+	if strings.Count(line, "\n") != 1 {
+		t.Error("expected one line per request")
+	}
+}
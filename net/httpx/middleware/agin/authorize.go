@@ -0,0 +1,169 @@
+package agin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/i18n"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/rbac"
+	"github.com/small-ek/antgo/utils/conv"
+	"go.uber.org/zap"
+)
+
+const (
+	// ContextKeyRoles 角色列表在 gin.Context 中的键名 / gin.Context key of the role list
+	ContextKeyRoles = "roles"
+	// ContextKeyDataScope 数据范围提示在 gin.Context 中的键名，标准库 context 使用 DataScopeFromContext 读取
+	// ContextKeyDataScope is the gin.Context key of the data scope hint; use DataScopeFromContext for context.Context
+	ContextKeyDataScope = "data_scope"
+)
+
+// dataScopeContextKey 数据范围提示在 context.Context 中的键 / dataScopeContextKey is the context.Context key of the data scope hint
+type dataScopeContextKey struct{}
+
+// AuthorizeConfig 权限校验中间件配置 / AuthorizeConfig configures the RBAC middlewares
+type AuthorizeConfig struct {
+	Enforcer   *rbac.Enforcer                // 校验器，默认 rbac.Default() / Enforcer, defaults to rbac.Default()
+	Roles      func(c *gin.Context) []string // 角色解析函数 / Resolves the roles of the request
+	RolesClaim string                        // JWT 中的角色声明名，默认 "roles" / Claim holding the roles, defaults to "roles"
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *AuthorizeConfig) setDefaults() {
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+	if cfg.Roles == nil {
+		claim := cfg.RolesClaim
+		cfg.Roles = func(c *gin.Context) []string {
+			return resolveRoles(c, claim)
+		}
+	}
+}
+
+// enforcer 获取校验器 / enforcer returns the configured or default enforcer
+func (cfg *AuthorizeConfig) enforcer() *rbac.Enforcer {
+	if cfg.Enforcer != nil {
+		return cfg.Enforcer
+	}
+	return rbac.Default()
+}
+
+// Authorize 校验当前用户是否拥有全部指定权限 / Authorize requires all the given permissions
+func Authorize(perm string, cfg ...AuthorizeConfig) gin.HandlerFunc {
+	var conf AuthorizeConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	}
+	conf.setDefaults()
+	perms := strings.Split(perm, ",")
+
+	return func(c *gin.Context) {
+		conf.check(c, perms)
+	}
+}
+
+// AuthorizeRoutes 基于路由权限表的全局校验中间件，未配置的路由直接放行
+// AuthorizeRoutes is a global middleware driven by the route table; unlisted routes pass through
+func AuthorizeRoutes(cfg ...AuthorizeConfig) gin.HandlerFunc {
+	var conf AuthorizeConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	}
+	conf.setDefaults()
+
+	return func(c *gin.Context) {
+		enforcer := conf.enforcer()
+		if enforcer == nil {
+			c.Next()
+			return
+		}
+		perm, ok := enforcer.RoutePermission(c.Request.Method, c.Request.URL.Path)
+		if !ok {
+			c.Next()
+			return
+		}
+		conf.check(c, []string{perm})
+	}
+}
+
+// check 执行权限校验并注入数据范围提示 / check enforces the permissions and injects the data scope hint
+func (cfg *AuthorizeConfig) check(c *gin.Context, perms []string) {
+	enforcer := cfg.enforcer()
+	if enforcer == nil {
+		alog.Write.Error("RBAC enforcer not configured", zap.String("path", c.Request.URL.Path))
+		abortWithFail(c, http.StatusInternalServerError, "500", i18n.TDefault(c, "auth.forbidden", "forbidden"))
+		return
+	}
+
+	roles := cfg.Roles(c)
+	if len(roles) == 0 {
+		abortWithFail(c, http.StatusUnauthorized, "401", i18n.TDefault(c, "auth.unauthorized", "unauthorized"))
+		return
+	}
+	if !enforcer.Allow(roles, perms...) {
+		abortWithFail(c, http.StatusForbidden, "403", i18n.TDefault(c, "auth.forbidden", "forbidden"))
+		return
+	}
+
+	scope := enforcer.DataScope(roles)
+	c.Set(ContextKeyRoles, roles)
+	c.Set(ContextKeyDataScope, scope)
+	ctx := context.WithValue(c.Request.Context(), dataScopeContextKey{}, scope)
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
+
+// resolveRoles 优先读取已设置的角色，其次读取 JWT 声明
+// resolveRoles reads roles set on the context first, then the JWT claims
+func resolveRoles(c *gin.Context, claim string) []string {
+	if value, exists := c.Get(ContextKeyRoles); exists {
+		if roles := toRoles(value); len(roles) > 0 {
+			return roles
+		}
+	}
+	claims, ok := GetClaims(c)
+	if !ok {
+		return nil
+	}
+	return toRoles(claims[claim])
+}
+
+// toRoles 兼容切片与逗号分隔字符串 / toRoles accepts slices and comma separated strings
+func toRoles(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		if v == "" {
+			return nil
+		}
+		return strings.Split(v, ",")
+	case []interface{}:
+		roles := make([]string, 0, len(v))
+		for _, item := range v {
+			roles = append(roles, conv.String(item))
+		}
+		return roles
+	default:
+		return conv.Strings(v)
+	}
+}
+
+// GetDataScope 获取当前请求的数据范围提示 / GetDataScope returns the data scope hint of the request
+func GetDataScope(c *gin.Context) string {
+	return c.GetString(ContextKeyDataScope)
+}
+
+// DataScopeFromContext 从 context.Context 获取数据范围提示，可用于 gorm Scopes
+// DataScopeFromContext returns the data scope hint from context.Context, e.g. for gorm scopes
+func DataScopeFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	scope, _ := ctx.Value(dataScopeContextKey{}).(string)
+	return scope
+}
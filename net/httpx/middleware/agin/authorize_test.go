@@ -0,0 +1,68 @@
+package agin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/rbac"
+)
+
+// TestAuthorize 测试权限校验、路由权限表与数据范围注入 / TestAuthorize covers permission checks, the route table and the data scope hint
+func TestAuthorize(t *testing.T) {
+	enforcer, err := rbac.New(rbac.LoaderFunc(func() (*rbac.Policy, error) {
+		return &rbac.Policy{
+			SuperRoles: []string{"root"},
+			Roles:      map[string][]string{"viewer": {"article:read"}},
+			DataScopes: map[string]string{"viewer": rbac.ScopeSelf},
+			Routes:     []rbac.RouteRule{{Method: "DELETE", Path: "/articles/:id", Permission: "article:delete"}},
+		}, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := AuthorizeConfig{Enforcer: enforcer}
+
+	app := gin.New()
+	app.Use(func(c *gin.Context) {
+		if roles := c.GetHeader("X-Roles"); roles != "" {
+			c.Set(ContextKeyRoles, roles)
+		}
+	})
+	scope := func(c *gin.Context) {
+		if DataScopeFromContext(c.Request.Context()) != GetDataScope(c) {
+			t.Error("data scope differs between gin.Context and context.Context")
+		}
+		c.String(http.StatusOK, GetDataScope(c))
+	}
+	app.GET("/articles/:id", Authorize("article:read", conf), scope)
+	app.POST("/articles", Authorize("article:read,article:write", conf), scope)
+	routes := app.Group("/", AuthorizeRoutes(conf))
+	routes.DELETE("/articles/:id", scope)
+	routes.GET("/public", scope)
+
+	cases := []struct {
+		method, path, roles string
+		status              int
+		body                string
+	}{
+		{http.MethodGet, "/articles/1", "viewer", http.StatusOK, rbac.ScopeSelf},
+		{http.MethodGet, "/articles/1", "", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/articles/1", "guest", http.StatusForbidden, ""},
+		{http.MethodPost, "/articles", "viewer", http.StatusForbidden, ""},
+		{http.MethodPost, "/articles", "guest,root", http.StatusOK, rbac.ScopeAll},
+		{http.MethodDelete, "/articles/1", "viewer", http.StatusForbidden, ""},
+		{http.MethodDelete, "/articles/1", "root", http.StatusOK, rbac.ScopeAll},
+		{http.MethodGet, "/public", "", http.StatusOK, ""},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("X-Roles", tc.roles)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tc.status || (tc.status == http.StatusOK && w.Body.String() != tc.body) {
+			t.Errorf("%s %s as %q: got %d %q", tc.method, tc.path, tc.roles, w.Code, w.Body.String())
+		}
+	}
+}
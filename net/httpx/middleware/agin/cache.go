@@ -0,0 +1,517 @@
+package agin
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/crypto/ahash"
+	"github.com/small-ek/antgo/db/aredis"
+	"github.com/small-ek/antgo/os/alog"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CacheStore 响应缓存存储 / CacheStore stores cached responses
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration, tags ...string) error
+	InvalidateTags(tags ...string) error
+}
+
+// CacheConfig 缓存中间件配置 / CacheConfig configures the cache middleware
+type CacheConfig struct {
+	Store   CacheStore                    // 存储，默认内存 / Store, defaults to memory
+	TTL     time.Duration                 // 缓存时间，默认 1m / Cache lifetime, defaults to 1m
+	Prefix  string                        // 缓存键前缀，默认 "cache:" / Cache key prefix
+	Headers []string                      // 参与缓存键的请求头 / Request headers that are part of the key
+	Private bool                          // 缓存带 Authorization 或 Cookie 的请求并将二者计入缓存键，默认跳过 / Cache requests carrying Authorization or Cookie, keyed on both; skipped by default
+	Tags    []string                      // 缓存标签，用于失效 / Tags used for invalidation
+	TagFunc func(c *gin.Context) []string // 动态标签 / Dynamic tags
+}
+
+// cachedResponse 缓存的响应 / cachedResponse is a cached response
+type cachedResponse struct {
+	Status       int                 `json:"status"`
+	Header       map[string][]string `json:"header"`
+	Body         []byte              `json:"body"`
+	ETag         string              `json:"etag"`
+	LastModified int64               `json:"last_modified"`
+	Vary         []string            `json:"vary,omitempty"` // 非空时为 Vary 标记，指向按这些请求头区分的变体 / Set on a marker pointing at variants keyed by these headers
+}
+
+// cacheSkipHeaders 每个请求各不相同、不保存到缓存的响应头
+// cacheSkipHeaders are per-request response headers that are never stored
+var cacheSkipHeaders = []string{"X-Request-Id", "X-Trace-Id", "Date", "X-Cache"}
+
+var (
+	defaultCacheStore     CacheStore
+	defaultCacheStoreOnce sync.Once
+)
+
+// DefaultCacheStore 默认内存缓存存储 / DefaultCacheStore returns the shared in-memory store
+func DefaultCacheStore() CacheStore {
+	defaultCacheStoreOnce.Do(func() {
+		defaultCacheStore = NewMemoryCacheStore(10000)
+	})
+	return defaultCacheStore
+}
+
+// Cache 缓存 GET 响应，HEAD 请求读取 GET 的缓存但从不写入，生成 ETag/Last-Modified 并处理条件请求。
+// 带 Authorization 或 Cookie 的请求默认不缓存；响应的 Vary 请求头计入缓存键，Vary: * 不缓存。
+//
+// Cache caches GET responses and answers HEAD from them without ever storing HEAD; it emits ETag/Last-Modified and answers conditional requests.
+// Requests carrying Authorization or Cookie bypass the cache by default; the response's Vary headers are part of the key and Vary: * is never stored.
+func Cache(cfg ...CacheConfig) gin.HandlerFunc {
+	var conf CacheConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	}
+	if conf.Store == nil {
+		conf.Store = DefaultCacheStore()
+	}
+	if conf.TTL <= 0 {
+		conf.TTL = time.Minute
+	}
+	if conf.Prefix == "" {
+		conf.Prefix = "cache:"
+	}
+	if conf.Private {
+		conf.Headers = append(append([]string{}, conf.Headers...), "Authorization", "Cookie")
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+		// 身份相关的响应不能共享给其他用户 / Responses tied to a user must not be shared with others
+		if !conf.Private && (c.GetHeader("Authorization") != "" || c.GetHeader("Cookie") != "") {
+			c.Next()
+			return
+		}
+
+		key := conf.Prefix + cacheKey(c, conf.Headers)
+		if entry, ok := loadCachedResponse(c, conf.Store, key); ok {
+			writeCachedResponse(c, entry, "HIT")
+			c.Abort()
+			return
+		}
+		// HEAD 响应没有响应体，不能作为 GET 的缓存 / HEAD responses carry no body and must not fill the GET entry
+		if c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		buffer := apiBufferPool.Get().(*bytes.Buffer)
+		buffer.Reset()
+		defer putBackBuffer(buffer)
+		writer := &bufferedWriter{ResponseWriter: c.Writer, body: buffer}
+		c.Writer = writer
+
+		c.Next()
+
+		c.Writer = writer.ResponseWriter
+		status := writer.Status()
+		header := writer.Header().Clone()
+		for _, h := range cacheSkipHeaders {
+			header.Del(h)
+		}
+		entry := &cachedResponse{
+			Status:       status,
+			Header:       header,
+			Body:         buffer.Bytes(),
+			ETag:         `"` + ahash.SHA1(buffer.String()) + `"`,
+			LastModified: time.Now().Unix(),
+		}
+
+		if status == http.StatusOK && cacheable(writer.Header()) {
+			tags := conf.Tags
+			if conf.TagFunc != nil {
+				tags = append(append([]string{}, tags...), conf.TagFunc(c)...)
+			}
+			save := func(key string, entry *cachedResponse) {
+				if raw, err := json.Marshal(entry); err == nil {
+					if err = conf.Store.Set(key, raw, conf.TTL, tags...); err != nil {
+						alog.Write.Error("Cache store response failed", zap.String("key", key), zap.Error(err))
+					}
+				}
+			}
+			// 响应带 Vary 时在主键保存标记，变体按请求头的值另存 / With Vary, keep a marker under the key and store the variant by header values
+			if vary := varyHeaders(writer.Header()); len(vary) > 0 {
+				save(key, &cachedResponse{Vary: vary})
+				save(varyKey(c, key, vary), entry)
+			} else {
+				save(key, entry)
+			}
+			writeCachedResponse(c, entry, "MISS")
+			return
+		}
+
+		writer.flushBuffer()
+	}
+}
+
+// cacheKey 由主机、路径、排序后的查询参数与指定请求头生成缓存键，HEAD 与 GET 共用
+// cacheKey builds the key from the host, path, sorted query and selected headers; HEAD shares the GET key
+func cacheKey(c *gin.Context, headers []string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(c.Request.Host))
+	sb.WriteString(c.Request.URL.Path)
+	sb.WriteByte('?')
+	sb.WriteString(c.Request.URL.Query().Encode())
+	for _, h := range headers {
+		sb.WriteByte('|')
+		sb.WriteString(h)
+		sb.WriteByte('=')
+		sb.WriteString(c.GetHeader(h))
+	}
+	return ahash.SHA1(sb.String())
+}
+
+// varyKey 在缓存键后追加 Vary 请求头的值 / varyKey appends the request values of the Vary headers to the key
+func varyKey(c *gin.Context, key string, vary []string) string {
+	var sb strings.Builder
+	for _, h := range vary {
+		sb.WriteString(h)
+		sb.WriteByte('=')
+		sb.WriteString(strings.Join(c.Request.Header.Values(h), ","))
+		sb.WriteByte('|')
+	}
+	return key + ":" + ahash.SHA1(sb.String())
+}
+
+// varyHeaders 解析响应的 Vary 请求头名称 / varyHeaders parses the header names listed in the response's Vary
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// loadCachedResponse 读取缓存，遇到 Vary 标记时读取对应变体
+// loadCachedResponse reads the entry, following a Vary marker to the variant for this request
+func loadCachedResponse(c *gin.Context, store CacheStore, key string) (*cachedResponse, bool) {
+	raw, ok := store.Get(key)
+	if !ok {
+		return nil, false
+	}
+	var entry cachedResponse
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, false
+	}
+	if len(entry.Vary) == 0 {
+		return &entry, true
+	}
+	if raw, ok = store.Get(varyKey(c, key, entry.Vary)); !ok {
+		return nil, false
+	}
+	var variant cachedResponse
+	if err := json.Unmarshal(raw, &variant); err != nil || len(variant.Vary) > 0 {
+		return nil, false
+	}
+	return &variant, true
+}
+
+// cacheable 遵循处理函数设置的 Cache-Control，Vary: * 不缓存 / cacheable honours the handler's Cache-Control and refuses Vary: *
+func cacheable(header http.Header) bool {
+	cc := strings.ToLower(header.Get("Cache-Control"))
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "private") && header.Get("Set-Cookie") == "" &&
+		!slices.Contains(varyHeaders(header), "*")
+}
+
+// writeCachedResponse 输出缓存响应，命中条件请求时返回 304
+// writeCachedResponse writes the cached response, answering 304 for matching conditional requests
+func writeCachedResponse(c *gin.Context, entry *cachedResponse, state string) {
+	header := c.Writer.Header()
+	for k, values := range entry.Header {
+		header[k] = values
+	}
+	lastModified := time.Unix(entry.LastModified, 0).UTC()
+	header.Set("ETag", entry.ETag)
+	header.Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	header.Set("X-Cache", state)
+
+	if notModified(c.Request, entry.ETag, lastModified) {
+		header.Del("Content-Length")
+		header.Del("Content-Type")
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	c.Status(entry.Status)
+	if c.Request.Method == http.MethodHead {
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	_, _ = c.Writer.Write(entry.Body)
+}
+
+// notModified 判断条件请求是否命中 / notModified reports whether the conditional request matches
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !lastModified.After(t)
+		}
+	}
+	return false
+}
+
+// bufferedWriter 缓冲全部响应体，以便在发送前补充响应头
+// bufferedWriter buffers the whole body so headers can be added before sending
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+// Write 写入缓冲区 / Write writes into the buffer
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// WriteString 写入缓冲区 / WriteString writes into the buffer
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// Written 是否已写入内容 / Written reports whether anything has been written
+func (w *bufferedWriter) Written() bool {
+	return w.body.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Flush 缓冲期间不向客户端刷新 / Flush does nothing while buffering
+func (w *bufferedWriter) Flush() {}
+
+// flushBuffer 将缓冲内容写入底层响应 / flushBuffer writes the buffered body to the underlying writer
+func (w *bufferedWriter) flushBuffer() {
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	} else {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// ----------------- 内存存储 / In-memory store -----------------
+
+// memoryCacheItem 内存缓存条目 / memoryCacheItem is an in-memory entry
+type memoryCacheItem struct {
+	value    []byte
+	expireAt time.Time
+	tags     []string
+}
+
+// MemoryCacheStore 内存缓存存储，超过容量时淘汰最早过期的条目
+// MemoryCacheStore keeps entries in memory and evicts the soonest-expiring entry when full
+type MemoryCacheStore struct {
+	mu         sync.RWMutex
+	items      map[string]*memoryCacheItem
+	tags       map[string]map[string]struct{}
+	maxEntries int
+}
+
+// NewMemoryCacheStore 创建内存缓存存储 / NewMemoryCacheStore creates an in-memory store
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		items:      make(map[string]*memoryCacheItem),
+		tags:       make(map[string]map[string]struct{}),
+		maxEntries: maxEntries,
+	}
+}
+
+// Get 获取缓存 / Get returns a cached value
+func (s *MemoryCacheStore) Get(key string) ([]byte, bool) {
+	s.mu.RLock()
+	item, ok := s.items[key]
+	s.mu.RUnlock()
+	if !ok || time.Now().After(item.expireAt) {
+		return nil, false
+	}
+	return item.value, true
+}
+
+// Set 写入缓存 / Set stores a value
+func (s *MemoryCacheStore) Set(key string, value []byte, ttl time.Duration, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.items[key]; !exists && s.maxEntries > 0 && len(s.items) >= s.maxEntries {
+		s.evictLocked()
+	}
+	s.deleteLocked(key)
+	s.items[key] = &memoryCacheItem{
+		value:    append([]byte(nil), value...),
+		expireAt: time.Now().Add(ttl),
+		tags:     tags,
+	}
+	for _, tag := range tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = make(map[string]struct{})
+		}
+		s.tags[tag][key] = struct{}{}
+	}
+	return nil
+}
+
+// InvalidateTags 删除带有指定标签的缓存 / InvalidateTags removes entries carrying the tags
+func (s *MemoryCacheStore) InvalidateTags(tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			s.deleteLocked(key)
+		}
+		delete(s.tags, tag)
+	}
+	return nil
+}
+
+// deleteLocked 删除条目及其标签索引 / deleteLocked removes an entry and its tag index
+func (s *MemoryCacheStore) deleteLocked(key string) {
+	item, ok := s.items[key]
+	if !ok {
+		return
+	}
+	delete(s.items, key)
+	for _, tag := range item.tags {
+		delete(s.tags[tag], key)
+	}
+}
+
+// evictLocked 清理过期条目，仍然已满时淘汰最早过期的条目
+// evictLocked drops expired entries, then the soonest-expiring one if still full
+func (s *MemoryCacheStore) evictLocked() {
+	now := time.Now()
+	var (
+		oldestKey string
+		oldestAt  time.Time
+	)
+	for key, item := range s.items {
+		if now.After(item.expireAt) {
+			s.deleteLocked(key)
+			continue
+		}
+		if oldestKey == "" || item.expireAt.Before(oldestAt) {
+			oldestKey, oldestAt = key, item.expireAt
+		}
+	}
+	if len(s.items) >= s.maxEntries && oldestKey != "" {
+		s.deleteLocked(oldestKey)
+	}
+}
+
+// ----------------- Redis 存储 / Redis store -----------------
+
+// RedisCacheStore 基于 aredis 的缓存存储，标签以集合保存
+// RedisCacheStore stores entries in aredis and keeps tags as sets
+type RedisCacheStore struct {
+	Client    *aredis.ClientRedis
+	TagPrefix string
+}
+
+// NewRedisCacheStore 创建 Redis 缓存存储 / NewRedisCacheStore creates a Redis backed store
+func NewRedisCacheStore(client *aredis.ClientRedis) *RedisCacheStore {
+	return &RedisCacheStore{Client: client, TagPrefix: "cache:tag:"}
+}
+
+// Get 获取缓存 / Get returns a cached value
+func (s *RedisCacheStore) Get(key string) ([]byte, bool) {
+	value := s.Client.Get(key)
+	if value == "" {
+		return nil, false
+	}
+	return []byte(value), true
+}
+
+// Set 写入缓存并记录标签 / Set stores a value and records its tags
+func (s *RedisCacheStore) Set(key string, value []byte, ttl time.Duration, tags ...string) error {
+	if err := s.Client.Set(key, value, ttl.Milliseconds()); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		tagKey := s.TagPrefix + tag
+		if err := s.Client.AddSet(tagKey, key); err != nil {
+			return err
+		}
+		// 标签集合比条目多保留一个周期 / Keep the tag set one period longer than the entries
+		if err := s.Client.SetExpiration(tagKey, (2 * ttl).Milliseconds()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateTags 删除带有指定标签的缓存 / InvalidateTags removes entries carrying the tags
+func (s *RedisCacheStore) InvalidateTags(tags ...string) error {
+	for _, tag := range tags {
+		tagKey := s.TagPrefix + tag
+		keys, err := s.Client.GetSet(tagKey)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if _, err = s.Client.Remove(key); err != nil {
+				return err
+			}
+		}
+		if _, err = s.Client.Remove(tagKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ----------------- gorm 失效插件 / gorm invalidation plugin -----------------
+
+// CacheInvalidatePlugin gorm 插件，写操作后按表名失效缓存标签，可配合 adb 的 Use 注册
+// CacheInvalidatePlugin is a gorm plugin that invalidates the table-name tag after writes; register it with adb's Use
+type CacheInvalidatePlugin struct {
+	Store CacheStore
+}
+
+// Name 插件名称 / Name returns the plugin name
+func (p *CacheInvalidatePlugin) Name() string {
+	return "agin:cache_invalidate"
+}
+
+// Initialize 注册写操作回调 / Initialize registers the write callbacks
+func (p *CacheInvalidatePlugin) Initialize(db *gorm.DB) error {
+	if p.Store == nil {
+		p.Store = DefaultCacheStore()
+	}
+	invalidate := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement == nil || tx.Statement.Table == "" {
+			return
+		}
+		if err := p.Store.InvalidateTags(tx.Statement.Table); err != nil {
+			alog.Write.Error("Cache invalidate failed", zap.String("table", tx.Statement.Table), zap.Error(err))
+		}
+	}
+
+	if err := db.Callback().Create().After("gorm:create").Register(p.Name(), invalidate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register(p.Name(), invalidate); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register(p.Name(), invalidate)
+}
@@ -0,0 +1,168 @@
+package agin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestCache 测试缓存命中、ETag 条件请求与按标签失效 / TestCache covers hits, ETag revalidation and tag invalidation
+func TestCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewMemoryCacheStore(100)
+	calls := 0
+
+	app := gin.New()
+	app.GET("/users", Cache(CacheConfig{Store: store, TTL: time.Minute, Tags: []string{"users"}}), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusOK, gin.H{"calls": calls})
+	})
+	app.GET("/private", Cache(CacheConfig{Store: store}), func(c *gin.Context) {
+		calls++
+		c.Header("Cache-Control", "private")
+		c.String(http.StatusOK, "secret")
+	})
+
+	do := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	first := do("/users?b=2&a=1", "")
+	if first.Code != http.StatusOK || first.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("expected MISS, got %d %q", first.Code, first.Header().Get("X-Cache"))
+	}
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+
+	// 查询参数顺序不同但命中同一缓存 / Different query order hits the same entry
+	second := do("/users?a=1&b=2", "")
+	if second.Header().Get("X-Cache") != "HIT" || second.Body.String() != first.Body.String() || calls != 1 {
+		t.Fatalf("expected HIT with identical body, calls=%d", calls)
+	}
+
+	if w := do("/users?a=1&b=2", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected 304 without body, got %d", w.Code)
+	}
+
+	if err := store.InvalidateTags("users"); err != nil {
+		t.Fatalf("invalidate failed: %v", err)
+	}
+	if w := do("/users?a=1&b=2", ""); w.Header().Get("X-Cache") != "MISS" || calls != 2 {
+		t.Errorf("expected MISS after invalidation, calls=%d", calls)
+	}
+
+	do("/private", "")
+	if w := do("/private", ""); w.Body.String() != "secret" || calls != 4 {
+		t.Errorf("private responses must not be cached, calls=%d", calls)
+	}
+}
+
+// TestCacheHeadAndHost 测试 HEAD 不写入缓存、按主机区分缓存与不保存逐请求响应头
+// TestCacheHeadAndHost covers HEAD never filling the cache, per-host entries and dropped per-request headers
+func TestCacheHeadAndHost(t *testing.T) {
+	calls := 0
+	app := gin.New()
+	app.Match([]string{http.MethodGet, http.MethodHead}, "/items", Cache(CacheConfig{Store: NewMemoryCacheStore(10)}), func(c *gin.Context) {
+		calls++
+		c.Header("X-Request-Id", "req-"+string(rune('0'+calls)))
+		c.String(http.StatusOK, "items")
+	})
+	do := func(method, host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/items", nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodHead, "a.example.com"); w.Header().Get("X-Cache") != "" || calls != 1 {
+		t.Fatalf("HEAD miss should pass through uncached, got %q", w.Header().Get("X-Cache"))
+	}
+	if w := do(http.MethodGet, "a.example.com"); w.Header().Get("X-Cache") != "MISS" || w.Body.String() != "items" || calls != 2 {
+		t.Fatalf("HEAD must not fill the GET entry: %q %q", w.Header().Get("X-Cache"), w.Body.String())
+	}
+	w := do(http.MethodGet, "a.example.com")
+	if w.Header().Get("X-Cache") != "HIT" || w.Body.String() != "items" || w.Header().Get("X-Request-Id") != "" {
+		t.Fatalf("unexpected hit %q %q %v", w.Header().Get("X-Cache"), w.Body.String(), w.Header())
+	}
+	if w := do(http.MethodHead, "a.example.com"); w.Header().Get("X-Cache") != "HIT" || w.Body.Len() != 0 || calls != 2 {
+		t.Fatalf("HEAD should be answered from the GET entry, got %q", w.Header().Get("X-Cache"))
+	}
+	if w := do(http.MethodGet, "b.example.com"); w.Header().Get("X-Cache") != "MISS" || calls != 3 {
+		t.Fatalf("virtual hosts must not share entries, got %q", w.Header().Get("X-Cache"))
+	}
+}
+
+// TestCacheAuthAndVary 测试带身份的请求与 Vary 请求头 / TestCacheAuthAndVary covers authenticated requests and Vary
+func TestCacheAuthAndVary(t *testing.T) {
+	calls := 0
+	app := gin.New()
+	handler := func(c *gin.Context) {
+		calls++
+		c.String(http.StatusOK, c.GetHeader("Authorization"))
+	}
+	app.GET("/shared", Cache(CacheConfig{Store: NewMemoryCacheStore(10)}), handler)
+	app.GET("/private", Cache(CacheConfig{Store: NewMemoryCacheStore(10), Private: true}), handler)
+	app.GET("/vary", Cache(CacheConfig{Store: NewMemoryCacheStore(10)}), func(c *gin.Context) {
+		calls++
+		c.Header("Vary", "Accept-Encoding")
+		c.String(http.StatusOK, "encoding="+c.GetHeader("Accept-Encoding"))
+	})
+	app.GET("/any", Cache(CacheConfig{Store: NewMemoryCacheStore(10)}), func(c *gin.Context) {
+		calls++
+		c.Header("Vary", "*")
+		c.String(http.StatusOK, "any")
+	})
+	do := func(path, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	// 默认跳过带身份的请求 / Authenticated requests bypass the cache by default
+	do("/shared", "Authorization", "Bearer alice")
+	if w := do("/shared", "Authorization", "Bearer bob"); w.Body.String() != "Bearer bob" || w.Header().Get("X-Cache") != "" || calls != 2 {
+		t.Fatalf("authenticated requests must bypass the cache, got %q calls=%d", w.Body.String(), calls)
+	}
+
+	// 开启 Private 后按用户分别缓存 / With Private each user gets an own entry
+	calls = 0
+	do("/private", "Authorization", "Bearer alice")
+	if w := do("/private", "Authorization", "Bearer bob"); w.Body.String() != "Bearer bob" || w.Header().Get("X-Cache") != "MISS" || calls != 2 {
+		t.Fatalf("users must not share entries, got %q calls=%d", w.Body.String(), calls)
+	}
+	if w := do("/private", "Authorization", "Bearer alice"); w.Body.String() != "Bearer alice" || w.Header().Get("X-Cache") != "HIT" || calls != 2 {
+		t.Fatalf("expected alice's entry, got %q calls=%d", w.Body.String(), calls)
+	}
+
+	// Vary 的请求头值计入缓存键 / The values of the Vary headers are part of the key
+	calls = 0
+	do("/vary", "Accept-Encoding", "gzip")
+	if w := do("/vary", "", ""); w.Body.String() != "encoding=" || w.Header().Get("X-Cache") != "MISS" || calls != 2 {
+		t.Fatalf("a gzip variant must not be served to other clients, got %q calls=%d", w.Body.String(), calls)
+	}
+	if w := do("/vary", "Accept-Encoding", "gzip"); w.Body.String() != "encoding=gzip" || w.Header().Get("X-Cache") != "HIT" || calls != 2 {
+		t.Fatalf("expected the gzip variant, got %q calls=%d", w.Body.String(), calls)
+	}
+
+	calls = 0
+	do("/any", "", "")
+	if w := do("/any", "", ""); w.Header().Get("X-Cache") != "" || calls != 2 {
+		t.Fatalf("Vary: * must not be cached, calls=%d", calls)
+	}
+}
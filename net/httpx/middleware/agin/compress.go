@@ -0,0 +1,305 @@
+package agin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/i18n"
+	"github.com/small-ek/antgo/net/httpx"
+)
+
+// CompressConfig 压缩中间件配置 / CompressConfig configures the compression middleware
+type CompressConfig struct {
+	Level               int      // 压缩等级，默认 gzip.DefaultCompression / Compression level
+	MinSize             int      // 最小压缩大小（字节），默认 1024 / Minimum body size to compress, defaults to 1024
+	ContentTypes        []string // 允许压缩的类型前缀 / Content-Type prefixes allowed to be compressed
+	MaxDecompressedSize int64    // 请求解压后的最大体积，默认 httpx.AbsoluteBodyMax / Max decompressed request size
+	DisableRequest      bool     // 关闭请求体解压 / Disable request body decompression
+}
+
+var defaultCompressTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/x-www-form-urlencoded",
+	"image/svg+xml",
+}
+
+// compressorPools 按编码与等级复用压缩器 / compressorPools reuses compressors per encoding and level
+var compressorPools sync.Map
+
+// compressor 统一 gzip 与 deflate 写入器，deflate 按 RFC 9110 使用 zlib 封装
+// compressor unifies gzip and deflate writers; deflate is zlib-wrapped as RFC 9110 requires
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// getCompressor 从池中获取压缩器 / getCompressor takes a compressor from the pool
+func getCompressor(encoding string, level int, w io.Writer) (compressor, *sync.Pool) {
+	key := encoding + ":" + strconv.Itoa(level)
+	pool, _ := compressorPools.LoadOrStore(key, &sync.Pool{
+		New: func() interface{} {
+			if encoding == "deflate" {
+				zw, err := zlib.NewWriterLevel(io.Discard, level)
+				if err != nil {
+					zw = zlib.NewWriter(io.Discard)
+				}
+				return zw
+			}
+			gw, err := gzip.NewWriterLevel(io.Discard, level)
+			if err != nil {
+				gw = gzip.NewWriter(io.Discard)
+			}
+			return gw
+		},
+	})
+	p := pool.(*sync.Pool)
+	cw := p.Get().(compressor)
+	cw.Reset(w)
+	return cw, p
+}
+
+// Compress 响应压缩与请求解压中间件 / Compress compresses responses and decompresses request bodies
+func Compress(cfg ...CompressConfig) gin.HandlerFunc {
+	var conf CompressConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	}
+	if conf.Level == 0 {
+		conf.Level = gzip.DefaultCompression
+	}
+	if conf.MinSize <= 0 {
+		conf.MinSize = 1024
+	}
+	if len(conf.ContentTypes) == 0 {
+		conf.ContentTypes = defaultCompressTypes
+	}
+	if conf.MaxDecompressedSize <= 0 {
+		conf.MaxDecompressedSize = httpx.AbsoluteBodyMax
+	}
+
+	return func(c *gin.Context) {
+		if !conf.DisableRequest && !decompressRequest(c, conf.MaxDecompressedSize) {
+			return
+		}
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead || isUpgrade(c.Request) {
+			c.Next()
+			return
+		}
+
+		writer := &compressWriter{
+			ResponseWriter: c.Writer,
+			encoding:       encoding,
+			conf:           &conf,
+		}
+		c.Writer = writer
+		// 追加而非覆盖，保留 CORS 等中间件设置的 Vary / Append so the Vary values set by CORS and others survive
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		defer writer.finish()
+		c.Next()
+	}
+}
+
+// decompressRequest 解压 gzip/deflate 请求体，超过限制时返回 413
+// decompressRequest inflates gzip/deflate request bodies, answering 413 when the limit is exceeded
+func decompressRequest(c *gin.Context, maxSize int64) bool {
+	encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+	if c.Request.Body == nil || (encoding != "gzip" && encoding != "deflate") {
+		return true
+	}
+
+	var (
+		reader io.ReadCloser
+		err    error
+	)
+	if encoding == "gzip" {
+		reader, err = gzip.NewReader(c.Request.Body)
+	} else {
+		reader, err = zlib.NewReader(c.Request.Body)
+	}
+	if err != nil {
+		abortWithFail(c, http.StatusBadRequest, "400", i18n.TDefault(c, "request.body_invalid", "invalid request body"), err.Error())
+		return false
+	}
+
+	body, newRC, err := httpx.ReadBody(reader, maxSize)
+	_ = c.Request.Body.Close()
+	if err != nil {
+		var sizeErr httpx.ErrBodySizeExceeded
+		if errors.As(err, &sizeErr) {
+			abortWithFail(c, http.StatusRequestEntityTooLarge, "413", i18n.TDefault(c, "request.body_too_large", "request body too large"), err.Error())
+			return false
+		}
+		abortWithFail(c, http.StatusBadRequest, "400", i18n.TDefault(c, "request.body_invalid", "invalid request body"), err.Error())
+		return false
+	}
+
+	c.Request.Body = newRC
+	c.Request.ContentLength = int64(len(body))
+	c.Request.Header.Del("Content-Encoding")
+	c.Request.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return true
+}
+
+// negotiateEncoding 根据 Accept-Encoding 选择编码，优先 gzip
+// negotiateEncoding picks the encoding from Accept-Encoding, preferring gzip
+func negotiateEncoding(accept string) string {
+	if accept == "" {
+		return ""
+	}
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		switch name {
+		case "gzip", "*":
+			if q >= bestQ {
+				best, bestQ = "gzip", q
+			}
+		case "deflate":
+			if q > bestQ {
+				best, bestQ = "deflate", q
+			}
+		}
+	}
+	return best
+}
+
+// isUpgrade 是否为协议升级请求（如 websocket） / isUpgrade reports protocol upgrade requests such as websocket
+func isUpgrade(r *http.Request) bool {
+	return strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+// compressWriter 缓冲至最小压缩大小后决定是否压缩
+// compressWriter buffers up to the minimum size before deciding whether to compress
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	conf     *CompressConfig
+	buf      bytes.Buffer
+	decided  bool
+	cw       compressor
+	pool     *sync.Pool
+}
+
+// Write 写入响应数据 / Write writes response data
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		if w.cw != nil {
+			return w.cw.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf.Write(b)
+	if w.buf.Len() >= w.conf.MinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// WriteString 写入字符串 / WriteString writes a string
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written 是否已写入内容 / Written reports whether anything has been written
+func (w *compressWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Flush 刷新压缩器与底层响应 / Flush flushes the compressor and the underlying writer
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(w.buf.Len() >= w.conf.MinSize)
+	}
+	if w.cw != nil {
+		_ = w.cw.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide 确定是否压缩并输出已缓冲的数据 / decide settles compression and writes the buffered data
+func (w *compressWriter) decide(bigEnough bool) error {
+	w.decided = true
+	header := w.Header()
+	if bigEnough && w.shouldCompress(header) {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.cw, w.pool = getCompressor(w.encoding, w.conf.Level, w.ResponseWriter)
+		if w.buf.Len() > 0 {
+			_, err := w.cw.Write(w.buf.Bytes())
+			w.buf.Reset()
+			return err
+		}
+		return nil
+	}
+	if w.buf.Len() > 0 {
+		_, err := w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+		return err
+	}
+	return nil
+}
+
+// shouldCompress 检查状态码、已有编码与内容类型 / shouldCompress checks status, existing encoding and content type
+func (w *compressWriter) shouldCompress(header http.Header) bool {
+	status := w.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(w.buf.Bytes())
+		header.Set("Content-Type", contentType)
+	}
+	contentType = strings.ToLower(contentType)
+	for _, allowed := range w.conf.ContentTypes {
+		if strings.HasPrefix(contentType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// finish 输出剩余数据并回收压缩器 / finish writes the remaining data and recycles the compressor
+func (w *compressWriter) finish() {
+	if !w.decided {
+		_ = w.decide(false)
+		if !w.ResponseWriter.Written() {
+			w.ResponseWriter.WriteHeaderNow()
+		}
+	}
+	if w.cw != nil {
+		_ = w.cw.Close()
+		w.cw.Reset(io.Discard)
+		w.pool.Put(w.cw)
+		w.cw = nil
+	}
+}
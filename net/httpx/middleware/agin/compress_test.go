@@ -0,0 +1,134 @@
+package agin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipBytes 压缩测试数据 / gzipBytes compresses test data
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(data); err != nil {
+		t.Fatalf("gzip write failed: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("gzip close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestCompress 测试响应压缩、最小体积与请求解压限制 / TestCompress covers compression, min size and request limits
+func TestCompress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	large := strings.Repeat("antgo ", 1000)
+
+	app := gin.New()
+	app.Use(Compress(CompressConfig{MinSize: 256, MaxDecompressedSize: 4096}))
+	app.GET("/large", func(c *gin.Context) { c.String(http.StatusOK, large) })
+	app.GET("/small", func(c *gin.Context) { c.String(http.StatusOK, "tiny") })
+	app.GET("/image", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", []byte(large)) })
+	app.POST("/echo", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, "%d", len(body))
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "deflate;q=0.5, gzip")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/large")
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip encoding, got %q", w.Header().Get("Content-Encoding"))
+	}
+	gr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("gzip reader failed: %v", err)
+	}
+	if plain, _ := io.ReadAll(gr); string(plain) != large {
+		t.Error("decompressed body mismatch")
+	}
+
+	if w := get("/small"); w.Header().Get("Content-Encoding") != "" || w.Body.String() != "tiny" {
+		t.Error("small bodies should not be compressed")
+	}
+	if w := get("/image"); w.Header().Get("Content-Encoding") != "" {
+		t.Error("content types outside the allowlist should not be compressed")
+	}
+
+	post := func(data []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(gzipBytes(t, data)))
+		req.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+	if w := post([]byte(strings.Repeat("a", 1000))); w.Code != http.StatusOK || w.Body.String() != "1000" {
+		t.Errorf("expected decompressed length 1000, got %d %q", w.Code, w.Body.String())
+	}
+	if w := post(bytes.Repeat([]byte{0}, 1<<20)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for compression bomb, got %d", w.Code)
+	}
+}
+
+// TestCompressDeflate 测试 deflate 使用 zlib 封装 / TestCompressDeflate covers zlib-wrapped deflate in both directions
+func TestCompressDeflate(t *testing.T) {
+	large := strings.Repeat("antgo ", 1000)
+	app := gin.New()
+	app.Use(Compress(CompressConfig{MinSize: 256}))
+	app.POST("/echo", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, _ = zw.Write([]byte(large))
+	_ = zw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/echo", &buf)
+	req.Header.Set("Content-Encoding", "deflate")
+	req.Header.Set("Accept-Encoding", "deflate")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("expected a deflate response, got %d %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	zr, err := zlib.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("response is not zlib-wrapped: %v", err)
+	}
+	if plain, _ := io.ReadAll(zr); string(plain) != large {
+		t.Error("decompressed body mismatch")
+	}
+}
+
+// TestCompressKeepsVary 测试压缩保留 CORS 设置的 Vary / TestCompressKeepsVary checks compression keeps the Vary values set by CORS
+func TestCompressKeepsVary(t *testing.T) {
+	app := gin.New()
+	app.Use(CORS(CORSConfig{AllowOrigins: []string{"https://a.com"}}), Compress(CompressConfig{MinSize: 1}))
+	app.GET("/data", func(c *gin.Context) { c.String(http.StatusOK, strings.Repeat("antgo ", 100)) })
+
+	req := httptest.NewRequest(http.MethodGet, "/data", nil)
+	req.Header.Set("Origin", "https://a.com")
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	vary := strings.Join(w.Header().Values("Vary"), ",")
+	if w.Header().Get("Content-Encoding") != "gzip" || !strings.Contains(vary, "Origin") || !strings.Contains(vary, "Accept-Encoding") {
+		t.Fatalf("expected Origin and Accept-Encoding in Vary, got %q", vary)
+	}
+}
@@ -0,0 +1,256 @@
+package agin
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"github.com/small-ek/antgo/utils/conv"
+	"go.uber.org/zap"
+)
+
+// CORSConfig 跨域配置，对应配置文件 cors.* / CORSConfig is the CORS configuration under cors.*
+type CORSConfig struct {
+	Prefix           string   `json:"prefix"`            // 覆盖规则的路径前缀 / Path prefix of an override
+	AllowOrigins     []string `json:"allow_origins"`     // 允许的来源，支持 "*"、"https://*.a.com"、"regex:..."（匹配整个来源） / Allowed origins; regex patterns match the whole origin
+	AllowMethods     []string `json:"allow_methods"`     // 允许的方法 / Allowed methods
+	AllowHeaders     []string `json:"allow_headers"`     // 允许的请求头，为空时回显预检请求头 / Allowed headers, echoes the preflight when empty
+	ExposeHeaders    []string `json:"expose_headers"`    // 暴露的响应头 / Exposed response headers
+	AllowCredentials *bool    `json:"allow_credentials"` // 是否允许携带凭证，覆盖规则未设置时沿用基础配置 / Whether credentials are allowed; unset overrides keep the base value
+	MaxAge           int      `json:"max_age"`           // 预检缓存时间（秒） / Preflight cache time in seconds
+}
+
+// corsPolicy 预处理后的跨域策略 / corsPolicy is the pre-processed CORS policy
+type corsPolicy struct {
+	prefix        string
+	allowAll      bool
+	exact         map[string]bool
+	wildcards     [][2]string // [scheme, suffix]
+	patterns      []*regexp.Regexp
+	methods       string
+	headers       string
+	exposeHeaders string
+	credentials   bool
+	maxAge        string
+}
+
+var defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+
+// LoadCORSConfig 从配置文件读取跨域配置及路由组覆盖规则（cors.overrides）
+// LoadCORSConfig reads the CORS configuration and the per-group overrides (cors.overrides)
+func LoadCORSConfig() (CORSConfig, []CORSConfig) {
+	credentials := config.GetBool("cors.allow_credentials")
+	base := CORSConfig{
+		AllowOrigins:     config.GetStringSlice("cors.allow_origins"),
+		AllowMethods:     config.GetStringSlice("cors.allow_methods"),
+		AllowHeaders:     config.GetStringSlice("cors.allow_headers"),
+		ExposeHeaders:    config.GetStringSlice("cors.expose_headers"),
+		AllowCredentials: &credentials,
+		MaxAge:           config.GetInt("cors.max_age"),
+	}
+
+	var overrides []CORSConfig
+	if raw := config.GetMaps("cors.overrides"); len(raw) > 0 {
+		if err := conv.ToStruct(raw, &overrides); err != nil {
+			alog.Write.Error("Parse cors.overrides failed", zap.Error(err))
+		}
+	}
+	return base, overrides
+}
+
+// CORS 跨域中间件，未传入配置时读取 cors.*，overrides 按最长路径前缀覆盖基础配置
+// CORS handles cross-origin requests; reads cors.* when no config is given, overrides apply by longest path prefix
+func CORS(cfg ...CORSConfig) gin.HandlerFunc {
+	var (
+		base      CORSConfig
+		overrides []CORSConfig
+	)
+	if len(cfg) > 0 {
+		base, overrides = cfg[0], cfg[1:]
+	} else {
+		base, overrides = LoadCORSConfig()
+	}
+
+	defaultPolicy := newCORSPolicy(base)
+	policies := make([]*corsPolicy, 0, len(overrides))
+	for _, o := range overrides {
+		if o.Prefix == "" {
+			continue
+		}
+		policies = append(policies, newCORSPolicy(mergeCORSConfig(base, o)))
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return len(policies[i].prefix) > len(policies[j].prefix)
+	})
+
+	return func(c *gin.Context) {
+		policy := defaultPolicy
+		for _, p := range policies {
+			if strings.HasPrefix(c.Request.URL.Path, p.prefix) {
+				policy = p
+				break
+			}
+		}
+		policy.handle(c)
+	}
+}
+
+// mergeCORSConfig 用覆盖规则中非空字段替换基础配置 / mergeCORSConfig replaces base fields with non-empty override fields
+func mergeCORSConfig(base, override CORSConfig) CORSConfig {
+	merged := base
+	merged.Prefix = override.Prefix
+	if len(override.AllowOrigins) > 0 {
+		merged.AllowOrigins = override.AllowOrigins
+	}
+	if len(override.AllowMethods) > 0 {
+		merged.AllowMethods = override.AllowMethods
+	}
+	if len(override.AllowHeaders) > 0 {
+		merged.AllowHeaders = override.AllowHeaders
+	}
+	if len(override.ExposeHeaders) > 0 {
+		merged.ExposeHeaders = override.ExposeHeaders
+	}
+	if override.MaxAge > 0 {
+		merged.MaxAge = override.MaxAge
+	}
+	if override.AllowCredentials != nil {
+		merged.AllowCredentials = override.AllowCredentials
+	}
+	return merged
+}
+
+// newCORSPolicy 预编译来源规则 / newCORSPolicy pre-compiles the origin rules
+func newCORSPolicy(cfg CORSConfig) *corsPolicy {
+	p := &corsPolicy{
+		prefix:        cfg.Prefix,
+		exact:         make(map[string]bool),
+		headers:       strings.Join(cfg.AllowHeaders, ", "),
+		exposeHeaders: strings.Join(cfg.ExposeHeaders, ", "),
+		credentials:   cfg.AllowCredentials != nil && *cfg.AllowCredentials,
+	}
+
+	methods := cfg.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	p.methods = strings.ToUpper(strings.Join(methods, ", "))
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(cfg.MaxAge)
+	}
+
+	for _, origin := range cfg.AllowOrigins {
+		origin = strings.TrimSpace(origin)
+		switch {
+		case origin == "*":
+			p.allowAll = true
+		case strings.HasPrefix(origin, "regex:"):
+			// 锚定整个来源，避免 https://evil.a.com.attacker.net 匹配 https://.*\.a\.com
+			// Anchor the whole origin so https://evil.a.com.attacker.net cannot match https://.*\.a\.com
+			re, err := regexp.Compile("^(?:" + strings.TrimPrefix(origin, "regex:") + ")$")
+			if err != nil {
+				alog.Write.Error("Invalid cors origin pattern", zap.String("origin", origin), zap.Error(err))
+				continue
+			}
+			p.patterns = append(p.patterns, re)
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "://*")
+			p.wildcards = append(p.wildcards, [2]string{strings.ToLower(scheme) + "://", strings.ToLower(host)})
+		case origin != "":
+			p.exact[strings.ToLower(origin)] = true
+		}
+	}
+
+	// 允许任意来源时携带凭证会让任何网站读取用户数据，关闭凭证
+	// Credentials with any origin would let every site read user data, so they are turned off
+	if p.allowAll && p.credentials {
+		alog.Write.Error("CORS allow_credentials cannot be combined with allow_origins \"*\", credentials disabled", zap.String("prefix", cfg.Prefix))
+		p.credentials = false
+	}
+	return p
+}
+
+// allowOrigin 判断来源是否被允许 / allowOrigin reports whether the origin is allowed
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if p.exact[lower] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) && len(lower) > len(w[0])+len(w[1]) {
+			return true
+		}
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// handle 写入跨域响应头，预检请求直接返回 / handle writes the CORS headers and short-circuits preflights
+func (p *corsPolicy) handle(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	header := c.Writer.Header()
+	preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+	// 响应随 Origin 变化，告知缓存 / The response varies by Origin, tell caches
+	if !p.allowAll {
+		header.Add("Vary", "Origin")
+	}
+	if origin == "" {
+		c.Next()
+		return
+	}
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	if !p.allowOrigin(origin) {
+		if preflight {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+		return
+	}
+
+	// 允许任意来源时从不回显 Origin / Any-origin policies never reflect the Origin
+	if p.allowAll {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if p.exposeHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", p.exposeHeaders)
+		}
+		c.Next()
+		return
+	}
+
+	header.Set("Access-Control-Allow-Methods", p.methods)
+	if p.headers != "" {
+		header.Set("Access-Control-Allow-Headers", p.headers)
+	} else if reqHeaders := c.GetHeader("Access-Control-Request-Headers"); reqHeaders != "" {
+		header.Set("Access-Control-Allow-Headers", reqHeaders)
+	}
+	if p.maxAge != "" {
+		header.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
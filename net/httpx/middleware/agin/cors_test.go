@@ -0,0 +1,109 @@
+package agin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestCORS 测试来源匹配、预检与路由组覆盖 / TestCORS covers origin matching, preflight and group overrides
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	credentials := true
+	app := gin.New()
+	app.Use(CORS(
+		CORSConfig{
+			AllowOrigins:     []string{"https://app.example.com", "https://*.example.org", `regex:^https://[a-z]+\.example\.net$`},
+			ExposeHeaders:    []string{"X-Request-Id"},
+			AllowCredentials: &credentials,
+			MaxAge:           600,
+		},
+		CORSConfig{Prefix: "/public", AllowOrigins: []string{"*"}},
+		CORSConfig{Prefix: "/partner", AllowOrigins: []string{"https://partner.example.com"}},
+	))
+	app.GET("/api", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	app.GET("/public/info", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	app.GET("/partner/info", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	do := func(method, path, origin string, preflight bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, origin := range []string{"https://app.example.com", "https://a.b.example.org", "https://api.example.net"} {
+		w := do(http.MethodGet, "/api", origin, false)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != origin {
+			t.Errorf("origin %s: expected echo, got %q", origin, got)
+		}
+		if w.Header().Get("Access-Control-Expose-Headers") != "X-Request-Id" {
+			t.Errorf("origin %s: missing expose headers", origin)
+		}
+	}
+
+	if w := do(http.MethodGet, "/api", "https://evil.com", false); w.Header().Get("Access-Control-Allow-Origin") != "" || w.Code != http.StatusOK {
+		t.Error("disallowed origin should pass through without CORS headers")
+	}
+	if w := do(http.MethodGet, "/api", "https://example.org", false); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("wildcard subdomain should not match the bare domain")
+	}
+
+	w := do(http.MethodOptions, "/api", "https://app.example.com", true)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected preflight 204, got %d", w.Code)
+	}
+	if w.Header().Get("Access-Control-Allow-Headers") != "Content-Type" || w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("unexpected preflight headers: %v", w.Header())
+	}
+	if len(w.Header().Values("Vary")) != 3 {
+		t.Errorf("expected Vary on origin and request method/headers, got %v", w.Header().Values("Vary"))
+	}
+
+	if w := do(http.MethodOptions, "/api", "https://evil.com", true); w.Code != http.StatusForbidden {
+		t.Errorf("expected disallowed preflight 403, got %d", w.Code)
+	}
+
+	// 路由组覆盖允许所有来源，继承的凭证被关闭 / The group override allows any origin and the inherited credentials are turned off
+	w = do(http.MethodGet, "/public/info", "https://evil.com", false)
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("override should allow any origin without credentials, got %v", w.Header())
+	}
+
+	// 只覆盖来源时沿用基础配置的凭证 / An override of the origins only keeps the base credentials
+	w = do(http.MethodGet, "/partner/info", "https://partner.example.com", false)
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://partner.example.com" || w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("override should keep the base credentials, got %v", w.Header())
+	}
+}
+
+// TestCORSRegexAnchored 测试正则来源整体匹配 / TestCORSRegexAnchored checks regex origins match the whole origin
+func TestCORSRegexAnchored(t *testing.T) {
+	app := gin.New()
+	app.Use(CORS(CORSConfig{AllowOrigins: []string{`regex:https://.*\.a\.com`}}))
+	app.GET("/api", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	for origin, allowed := range map[string]bool{
+		"https://app.a.com":                true,
+		"https://evil.a.com.attacker.net":  false,
+		"http://x.https://app.a.com":       false,
+		"https://app.a.com/../attacker.io": false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if got := w.Header().Get("Access-Control-Allow-Origin") == origin; got != allowed {
+			t.Errorf("origin %s: expected allowed=%v", origin, allowed)
+		}
+	}
+}
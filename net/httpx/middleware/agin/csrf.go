@@ -0,0 +1,177 @@
+package agin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/crypto/arand"
+	"github.com/small-ek/antgo/i18n"
+	"github.com/small-ek/antgo/net/httpx"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"go.uber.org/zap"
+)
+
+// ContextKeyCSRFToken CSRF 令牌在上下文中的键名 / Context key of the CSRF token
+const ContextKeyCSRFToken = "csrf_token"
+
+// CSRFConfig 双重提交 Cookie 的 CSRF 配置，零值字段读取 csrf.* 配置
+// CSRFConfig configures the double-submit-cookie CSRF middleware; zero fields fall back to csrf.*
+type CSRFConfig struct {
+	Secret     string        // 令牌签名密钥，设置后 Cookie 无法被子域名伪造 / Signing secret, stops subdomains from planting cookies
+	CookieName string        // Cookie 名称，默认 "csrf_token" / Cookie name, defaults to "csrf_token"
+	HeaderName string        // 请求头名称，默认 "X-CSRF-Token" / Header name, defaults to "X-CSRF-Token"
+	FormField  string        // 表单字段名称，默认 "_csrf" / Form field, defaults to "_csrf"
+	Path       string        // Cookie 路径，默认 "/" / Cookie path, defaults to "/"
+	Domain     string        // Cookie 域名 / Cookie domain
+	MaxAge     int           // Cookie 有效期（秒），默认 12 小时 / Cookie max-age in seconds, defaults to 12 hours
+	Secure     bool          // 仅 HTTPS 发送 / Send over HTTPS only
+	SameSite   http.SameSite // 默认 Lax / Defaults to Lax
+	Exempt     []string      // 免检路径，支持 ":id" 与 "*" / Exempt paths, supports ":id" and "*"
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *CSRFConfig) setDefaults() {
+	if cfg.Secret == "" {
+		cfg.Secret = config.GetString("csrf.secret")
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = config.GetString("csrf.cookie_name")
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "csrf_token"
+	}
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = "X-CSRF-Token"
+	}
+	if cfg.FormField == "" {
+		cfg.FormField = "_csrf"
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.Domain == "" {
+		cfg.Domain = config.GetString("csrf.domain")
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = config.GetInt("csrf.max_age")
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 12 * 3600
+	}
+	if !cfg.Secure {
+		cfg.Secure = config.GetBool("csrf.secure")
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+	if len(cfg.Exempt) == 0 {
+		cfg.Exempt = config.GetStringSlice("csrf.exempt")
+	}
+}
+
+// CSRF 双重提交 Cookie 防护：首次访问下发令牌 Cookie，非安全方法需在请求头或表单中提交相同令牌，否则返回 403。
+// 模板中通过 GetCSRFToken(c) 获取令牌写入隐藏表单字段。
+//
+// CSRF implements double-submit-cookie protection: the token cookie is issued on first visit and unsafe methods
+// must echo it in the header or form field, otherwise 403 is returned. Templates read it with GetCSRFToken(c).
+func CSRF(cfg ...CSRFConfig) gin.HandlerFunc {
+	var conf CSRFConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	}
+	conf.setDefaults()
+
+	return func(c *gin.Context) {
+		token, _ := c.Cookie(conf.CookieName)
+		if token == "" || !conf.validToken(token) {
+			var err error
+			if token, err = conf.newToken(); err != nil {
+				alog.Write.Error("Generate CSRF token failed", zap.Error(err))
+				abortWithFail(c, http.StatusInternalServerError, "500", i18n.TDefault(c, "csrf.token_failed", "failed to generate csrf token"))
+				return
+			}
+			// 不设置 HttpOnly，前端脚本需读取 Cookie 后放入请求头 / Not HttpOnly so scripts can copy it into the header
+			c.SetSameSite(conf.SameSite)
+			c.SetCookie(conf.CookieName, token, conf.MaxAge, conf.Path, conf.Domain, conf.Secure, false)
+		}
+		c.Set(ContextKeyCSRFToken, token)
+
+		if isSafeMethod(c.Request.Method) || conf.exempt(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		submitted := c.GetHeader(conf.HeaderName)
+		if submitted == "" {
+			submitted = c.PostForm(conf.FormField)
+		}
+		if subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+			abortWithFail(c, http.StatusForbidden, "403", i18n.TDefault(c, "csrf.token_invalid", "invalid csrf token"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetCSRFToken 获取当前请求的 CSRF 令牌 / GetCSRFToken returns the request's CSRF token
+func GetCSRFToken(c *gin.Context) string {
+	return c.GetString(ContextKeyCSRFToken)
+}
+
+// newToken 生成令牌，设置密钥时附加签名 / newToken generates a token, signed when a secret is set
+func (cfg *CSRFConfig) newToken() (string, error) {
+	raw, err := arand.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	token := string(raw)
+	if cfg.Secret != "" {
+		token += "." + cfg.sign(token)
+	}
+	return token, nil
+}
+
+// validToken 校验令牌签名 / validToken verifies the token signature
+func (cfg *CSRFConfig) validToken(token string) bool {
+	if cfg.Secret == "" {
+		return true
+	}
+	raw, sign, found := strings.Cut(token, ".")
+	if !found {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(sign), []byte(cfg.sign(raw))) == 1
+}
+
+// sign 计算令牌签名，使用 URL 安全编码以便 Cookie 原样读取
+// sign computes the token signature with URL-safe encoding so the cookie reads back verbatim
+func (cfg *CSRFConfig) sign(raw string) string {
+	mac := hmac.New(sha256.New, []byte(cfg.Secret))
+	mac.Write([]byte(raw))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// exempt 路径是否免检 / exempt reports whether the path skips the check
+func (cfg *CSRFConfig) exempt(path string) bool {
+	for _, pattern := range cfg.Exempt {
+		if httpx.MatchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSafeMethod 是否为安全方法 / isSafeMethod reports methods that must not change state
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package agin
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/small-ek/antgo/crypto/ahash"
+	"github.com/small-ek/antgo/db/aredis"
+	"github.com/small-ek/antgo/i18n"
+	"github.com/small-ek/antgo/net/httpx"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"github.com/small-ek/antgo/utils/conv"
+	"go.uber.org/zap"
+)
+
+const (
+	idempotencyProcessing = "processing"
+	idempotencyDone       = "done"
+)
+
+// IdempotencyStore 幂等记录存储 / IdempotencyStore keeps idempotency records
+type IdempotencyStore interface {
+	// Lock 键不存在时写入并返回 true / Lock stores the value and returns true when the key is absent
+	Lock(key string, value []byte, ttl time.Duration) (bool, error)
+	// Get 读取记录，不存在时返回 nil / Get loads a record, nil when absent
+	Get(key string) ([]byte, error)
+	// Set 写入记录 / Set stores a record
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete 删除记录 / Delete removes a record
+	Delete(key string) error
+}
+
+// RedisIdempotencyStore 基于 aredis 的幂等记录存储 / RedisIdempotencyStore keeps records in aredis
+type RedisIdempotencyStore struct {
+	Client *aredis.ClientRedis
+}
+
+// Lock 使用 SETNX 加锁 / Lock uses SETNX
+func (s RedisIdempotencyStore) Lock(key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.Client.Universal().SetNX(s.Client.Ctx, key, value, ttl).Result()
+}
+
+// Get 读取记录 / Get loads a record
+func (s RedisIdempotencyStore) Get(key string) ([]byte, error) {
+	value, err := s.Client.Universal().Get(s.Client.Ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return value, err
+}
+
+// Set 写入记录 / Set stores a record
+func (s RedisIdempotencyStore) Set(key string, value []byte, ttl time.Duration) error {
+	return s.Client.Universal().Set(s.Client.Ctx, key, value, ttl).Err()
+}
+
+// Delete 删除记录 / Delete removes a record
+func (s RedisIdempotencyStore) Delete(key string) error {
+	return s.Client.Universal().Del(s.Client.Ctx, key).Err()
+}
+
+// IdempotencyConfig 幂等中间件配置，零值字段读取 idempotency.* 配置
+// IdempotencyConfig configures the idempotency middleware; zero fields fall back to idempotency.*
+type IdempotencyConfig struct {
+	Redis   *aredis.ClientRedis                     // 存储所用 Redis，默认 idempotency.redis 指定的连接 / Redis used for storage
+	Store   IdempotencyStore                        // 记录存储，优先于 Redis / Record store, takes precedence over Redis
+	Header  string                                  // 幂等键请求头，默认 "Idempotency-Key" / Header carrying the key
+	Prefix  string                                  // Redis 键前缀，默认 "idempotency:" / Redis key prefix
+	KeyFunc func(c *gin.Context, key string) string // 存储键，默认由调用方、方法、路径与幂等键生成 / Storage key, derived from the caller, method, path and key by default
+	TTL     time.Duration                           // 响应保存时间，默认 24h / How long the first response is kept
+	LockTTL time.Duration                           // 处理中锁的时间，默认 1m / How long the in-flight lock is held
+	Methods []string                                // 需要幂等的方法，默认 POST/PUT/PATCH / Methods guarded by the middleware
+}
+
+// idempotencyRecord 保存在 Redis 中的记录 / idempotencyRecord is the value stored in Redis
+type idempotencyRecord struct {
+	State       string              `json:"state"`
+	Fingerprint string              `json:"fingerprint"`
+	Status      int                 `json:"status,omitempty"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Body        []byte              `json:"body,omitempty"`
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *IdempotencyConfig) setDefaults() {
+	if cfg.Store == nil {
+		if cfg.Redis == nil {
+			cfg.Redis = aredis.Client[config.GetString("idempotency.redis")]
+		}
+		if cfg.Redis != nil {
+			cfg.Store = RedisIdempotencyStore{Client: cfg.Redis}
+		}
+	}
+	if cfg.Header == "" {
+		cfg.Header = "Idempotency-Key"
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "idempotency:"
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = config.GetDuration("idempotency.ttl")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = config.GetDuration("idempotency.lock_ttl")
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = time.Minute
+	}
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = []string{http.MethodPost, http.MethodPut, http.MethodPatch}
+	}
+	if cfg.KeyFunc == nil {
+		prefix := cfg.Prefix
+		cfg.KeyFunc = func(c *gin.Context, key string) string {
+			return prefix + IdempotencyKey(c, key)
+		}
+	}
+}
+
+// IdempotencyKey 按调用方（JWT sub 或验签应用 ID）、方法与路径限定幂等键，避免不同用户互相读取响应
+// IdempotencyKey scopes the key to the caller (JWT sub or signed app ID), method and path so users never see each other's responses
+func IdempotencyKey(c *gin.Context, key string) string {
+	subject := c.GetString(ContextKeyAppID)
+	if claims, ok := GetClaims(c); ok && claims["sub"] != nil {
+		subject = conv.String(claims["sub"])
+	}
+	return ahash.SHA256(subject + "\n" + c.Request.Method + "\n" + c.Request.URL.Path + "\n" + key)
+}
+
+// Idempotency 幂等中间件：相同幂等键与相同请求重放首次响应，处理中或请求不一致返回 409
+// Idempotency replays the first response for repeated keys; in-flight or mismatched requests get 409
+func Idempotency(cfg ...IdempotencyConfig) gin.HandlerFunc {
+	var conf IdempotencyConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	}
+	conf.setDefaults()
+
+	methods := make(map[string]bool, len(conf.Methods))
+	for _, m := range conf.Methods {
+		methods[strings.ToUpper(m)] = true
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(conf.Header)
+		if key == "" || !methods[c.Request.Method] {
+			c.Next()
+			return
+		}
+		if conf.Store == nil {
+			alog.Write.Error("Idempotency store not configured")
+			c.Next()
+			return
+		}
+
+		body, newRC, err := httpx.ReadBody(c.Request.Body, httpx.CalculateMaxSize(c.Request.ContentLength))
+		if err != nil {
+			var sizeErr httpx.ErrBodySizeExceeded
+			if errors.As(err, &sizeErr) {
+				abortWithFail(c, http.StatusRequestEntityTooLarge, "413", i18n.TDefault(c, "request.body_too_large", "request body too large"), err.Error())
+				return
+			}
+			abortWithFail(c, http.StatusBadRequest, "400", i18n.TDefault(c, "request.body_invalid", "invalid request body"), err.Error())
+			return
+		}
+		c.Request.Body = newRC
+
+		fingerprint := ahash.SHA256(c.Request.Method + "\n" + c.Request.URL.RequestURI() + "\n" + string(body))
+		redisKey := conf.KeyFunc(c, key)
+
+		lock, _ := json.Marshal(idempotencyRecord{State: idempotencyProcessing, Fingerprint: fingerprint})
+		locked, err := conf.Store.Lock(redisKey, lock, conf.LockTTL)
+		if err != nil {
+			alog.Write.Error("Idempotency lock failed", zap.String("key", key), zap.Error(err))
+			abortWithFail(c, http.StatusServiceUnavailable, "503", i18n.TDefault(c, "idempotency.unavailable", "idempotency check unavailable"))
+			return
+		}
+		if !locked {
+			raw, err := conf.Store.Get(redisKey)
+			if err != nil {
+				alog.Write.Error("Idempotency load failed", zap.String("key", key), zap.Error(err))
+				abortWithFail(c, http.StatusServiceUnavailable, "503", i18n.TDefault(c, "idempotency.unavailable", "idempotency check unavailable"))
+				return
+			}
+			replayIdempotent(c, raw, fingerprint)
+			return
+		}
+
+		buffer := apiBufferPool.Get().(*bytes.Buffer)
+		buffer.Reset()
+		defer putBackBuffer(buffer)
+		c.Writer = &responseBodyWriter{body: buffer, ResponseWriter: c.Writer}
+
+		c.Next()
+
+		// 服务端错误不缓存，允许客户端重试 / Server errors are not stored so the client may retry
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			if err := conf.Store.Delete(redisKey); err != nil {
+				alog.Write.Error("Idempotency unlock failed", zap.String("key", key), zap.Error(err))
+			}
+			return
+		}
+
+		record, err := json.Marshal(idempotencyRecord{
+			State:       idempotencyDone,
+			Fingerprint: fingerprint,
+			Status:      status,
+			Header:      c.Writer.Header().Clone(),
+			Body:        buffer.Bytes(),
+		})
+		if err == nil {
+			err = conf.Store.Set(redisKey, record, conf.TTL)
+		}
+		if err != nil {
+			alog.Write.Error("Idempotency store response failed", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+// replayIdempotent 根据已存在的记录重放或拒绝请求 / replayIdempotent replays or rejects based on the stored record
+func replayIdempotent(c *gin.Context, raw []byte, fingerprint string) {
+	var record idempotencyRecord
+	if len(raw) == 0 || json.Unmarshal(raw, &record) != nil {
+		abortWithFail(c, http.StatusConflict, "409", i18n.TDefault(c, "idempotency.in_progress", "request is being processed"))
+		return
+	}
+	if record.Fingerprint != fingerprint {
+		abortWithFail(c, http.StatusConflict, "409", i18n.TDefault(c, "idempotency.mismatch", "idempotency key reused with a different request"))
+		return
+	}
+	if record.State != idempotencyDone {
+		abortWithFail(c, http.StatusConflict, "409", i18n.TDefault(c, "idempotency.in_progress", "request is being processed"))
+		return
+	}
+
+	header := c.Writer.Header()
+	for k, values := range record.Header {
+		header[k] = values
+	}
+	header.Set("Idempotent-Replayed", "true")
+	c.Status(record.Status)
+	_, _ = c.Writer.Write(record.Body)
+	c.Abort()
+}
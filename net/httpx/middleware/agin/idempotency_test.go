@@ -0,0 +1,140 @@
+package agin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryIdempotencyStore 测试用的内存存储 / memoryIdempotencyStore is an in-memory store for tests
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string][]byte
+	err     error
+}
+
+func (s *memoryIdempotencyStore) Lock(key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+	if _, ok := s.records[key]; ok {
+		return false, nil
+	}
+	s.records[key] = value
+	return true, nil
+}
+
+func (s *memoryIdempotencyStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[key], s.err
+}
+
+func (s *memoryIdempotencyStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = value
+	return s.err
+}
+
+func (s *memoryIdempotencyStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return s.err
+}
+
+// TestIdempotency 测试首次执行、重放、请求不一致、处理中与调用方隔离
+// TestIdempotency covers the first run, replay, fingerprint mismatch, in-flight requests and caller isolation
+func TestIdempotency(t *testing.T) {
+	store := &memoryIdempotencyStore{records: make(map[string][]byte)}
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	app := gin.New()
+	app.Use(func(c *gin.Context) {
+		c.Set(ContextKeyAppID, c.GetHeader("X-App"))
+	}, Idempotency(IdempotencyConfig{Store: store}))
+	app.POST("/orders", func(c *gin.Context) {
+		n := calls.Add(1)
+		c.Header("X-Call", string(rune('0'+n)))
+		c.String(http.StatusCreated, "order for "+c.GetHeader("X-App"))
+	})
+	app.POST("/slow", func(c *gin.Context) {
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	do := func(path, caller, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		req.Header.Set("X-App", caller)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	first := do("/orders", "alice", "k1", `{"n":1}`)
+	if first.Code != http.StatusCreated || first.Body.String() != "order for alice" {
+		t.Fatalf("unexpected first response %d %q", first.Code, first.Body.String())
+	}
+	replay := do("/orders", "alice", "k1", `{"n":1}`)
+	if replay.Code != http.StatusCreated || replay.Body.String() != "order for alice" || replay.Header().Get("Idempotent-Replayed") != "true" || replay.Header().Get("X-Call") != "1" {
+		t.Fatalf("unexpected replay %d %q %v", replay.Code, replay.Body.String(), replay.Header())
+	}
+	if w := do("/orders", "alice", "k1", `{"n":2}`); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a different body, got %d", w.Code)
+	}
+	// 另一个调用方使用相同的键不会读到别人的响应 / Another caller reusing the key never sees the first response
+	if w := do("/orders", "bob", "k1", `{"n":1}`); w.Code != http.StatusCreated || w.Body.String() != "order for bob" || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("key leaked across callers: %d %q", w.Code, w.Body.String())
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 handler calls, got %d", calls.Load())
+	}
+
+	// 处理中的请求 / A request still in flight
+	done := make(chan int)
+	go func() { done <- do("/slow", "alice", "k2", "").Code }()
+	waitIdempotencyLock(t, store, 3)
+	if w := do("/slow", "alice", "k2", ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 while in flight, got %d", w.Code)
+	}
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("unexpected in-flight response %d", code)
+	}
+
+	// 存储不可用 / Store unavailable
+	store.err = errors.New("redis down")
+	if w := do("/orders", "alice", "k3", ""); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when the store fails, got %d", w.Code)
+	}
+}
+
+// waitIdempotencyLock 等待存储中出现 n 条记录 / waitIdempotencyLock waits until the store holds n records
+func waitIdempotencyLock(t *testing.T, store *memoryIdempotencyStore, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		store.mu.Lock()
+		count := len(store.records)
+		store.mu.Unlock()
+		if count >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the lock")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
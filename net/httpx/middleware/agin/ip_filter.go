@@ -0,0 +1,171 @@
+package agin
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/i18n"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"go.uber.org/zap"
+)
+
+// IPFilterConfig IP 访问控制配置，未设置 Allow/Deny 时读取 ip_filter.groups.<Group>.* 并定时重新加载
+// IPFilterConfig configures the IP filter; without Allow/Deny it reads ip_filter.groups.<Group>.* and reloads periodically
+type IPFilterConfig struct {
+	Group          string        // 配置中的规则组名，默认 "default" / Rule group name in the config, defaults to "default"
+	Allow          []string      // 允许列表（IP 或 CIDR），为空则允许全部 / Allow list of IPs or CIDRs, empty allows all
+	Deny           []string      // 拒绝列表，优先于允许列表 / Deny list, checked before the allow list
+	ReloadInterval time.Duration // 重新加载间隔，默认 ip_filter.reload_interval 或 30s / Reload interval, defaults to ip_filter.reload_interval or 30s
+}
+
+// ipFilterList 解析后的访问列表 / ipFilterList is the parsed access list
+type ipFilterList struct {
+	allow   []netip.Prefix
+	deny    []netip.Prefix
+	invalid bool // 存在无效项时拒绝全部请求 / Deny every request when any entry is invalid
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *IPFilterConfig) setDefaults() {
+	if cfg.Group == "" {
+		cfg.Group = "default"
+	}
+	if cfg.ReloadInterval <= 0 {
+		cfg.ReloadInterval = config.GetDuration("ip_filter.reload_interval")
+	}
+	if cfg.ReloadInterval <= 0 {
+		cfg.ReloadInterval = 30 * time.Second
+	}
+}
+
+// IPFilter IP 黑白名单中间件，按 c.ClientIP() 匹配（依赖 system.trusted_proxies 防止伪造），不允许的请求返回 403。
+// 使用配置文件规则时，修改配置后在重新加载间隔内生效；列表中存在无效项时拒绝全部请求。
+//
+// IPFilter enforces CIDR allow/deny lists against c.ClientIP() (system.trusted_proxies prevents spoofing) and answers 403.
+// Rules read from the config take effect within the reload interval after a change; any invalid entry denies all requests.
+func IPFilter(cfg ...IPFilterConfig) gin.HandlerFunc {
+	var conf IPFilterConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	}
+	conf.setDefaults()
+
+	var (
+		list       atomic.Pointer[ipFilterList]
+		nextReload atomic.Int64
+	)
+	fromConfig := len(conf.Allow) == 0 && len(conf.Deny) == 0
+	if fromConfig {
+		list.Store(loadIPFilterList(conf.Group))
+		nextReload.Store(time.Now().Add(conf.ReloadInterval).UnixNano())
+	} else {
+		list.Store(newIPFilterList(conf.Group, conf.Allow, conf.Deny))
+	}
+
+	return func(c *gin.Context) {
+		if fromConfig {
+			// 到期后由一个请求负责重新加载 / Once due, a single request reloads the rules
+			now := time.Now().UnixNano()
+			if next := nextReload.Load(); now >= next && nextReload.CompareAndSwap(next, now+int64(conf.ReloadInterval)) {
+				list.Store(loadIPFilterList(conf.Group))
+			}
+		}
+
+		ip := c.ClientIP()
+		if !list.Load().allowed(ip) {
+			alog.Write.Warn("IP access denied",
+				zap.String("ip", ip),
+				zap.String("path", c.Request.URL.Path),
+				zap.String("request_id", getRequestID(c)),
+			)
+			abortWithFail(c, http.StatusForbidden, "403", i18n.TDefault(c, "auth.ip_denied", "access denied"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// loadIPFilterList 读取配置中的规则组 / loadIPFilterList reads a rule group from the config
+func loadIPFilterList(group string) *ipFilterList {
+	prefix := "ip_filter.groups." + group
+	return newIPFilterList(group, config.GetStringSlice(prefix+".allow"), config.GetStringSlice(prefix+".deny"))
+}
+
+// newIPFilterList 解析访问列表，任一项无效时记录错误并拒绝全部请求，避免全部写错的允许列表变成放行全部
+// newIPFilterList parses the lists; any invalid entry is logged and the filter denies everything,
+// so an allow list made only of typos never turns into allow-all
+func newIPFilterList(group string, allow, deny []string) *ipFilterList {
+	list := &ipFilterList{}
+	var err error
+	if list.allow, err = parseIPList(allow); err != nil {
+		alog.Write.Error("Invalid IP filter allow list, denying all requests", zap.String("group", group), zap.Error(err))
+		list.invalid = true
+	}
+	if list.deny, err = parseIPList(deny); err != nil {
+		alog.Write.Error("Invalid IP filter deny list, denying all requests", zap.String("group", group), zap.Error(err))
+		list.invalid = true
+	}
+	return list
+}
+
+// parseIPList 解析 IP 或 CIDR 列表，返回有效项与所有无效项的错误
+// parseIPList parses IPs or CIDRs, returning the valid entries and an error covering every invalid one
+func parseIPList(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	var errs []error
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, errors.Join(errs...)
+}
+
+// allowed 先匹配拒绝列表，允许列表为空时放行 / allowed checks the deny list first; an empty allow list permits all
+func (l *ipFilterList) allowed(ip string) bool {
+	if l.invalid {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return len(l.allow) == 0 && len(l.deny) == 0
+	}
+	addr = addr.Unmap()
+	for _, prefix := range l.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	if len(l.allow) == 0 {
+		return true
+	}
+	for _, prefix := range l.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
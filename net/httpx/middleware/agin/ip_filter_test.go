@@ -0,0 +1,70 @@
+package agin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/os/config"
+)
+
+// TestIPFilter 测试黑白名单、可信代理与配置热加载 / TestIPFilter covers the lists, trusted proxies and config reload
+func TestIPFilter(t *testing.T) {
+	do := func(app *gin.Engine, remote, forwarded string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote + ":1234"
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w.Code
+	}
+	newApp := func(cfg IPFilterConfig) *gin.Engine {
+		app := gin.New()
+		if err := app.SetTrustedProxies([]string{"127.0.0.1"}); err != nil {
+			t.Fatal(err)
+		}
+		app.Use(IPFilter(cfg))
+		app.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return app
+	}
+
+	app := newApp(IPFilterConfig{Allow: []string{"10.0.0.0/8", "192.168.1.5"}, Deny: []string{"10.0.0.13"}})
+	cases := []struct {
+		remote, forwarded string
+		code              int
+	}{
+		{"10.1.2.3", "", http.StatusOK},
+		{"192.168.1.5", "", http.StatusOK},
+		{"10.0.0.13", "", http.StatusForbidden},
+		{"8.8.8.8", "", http.StatusForbidden},
+		{"8.8.8.8", "10.1.2.3", http.StatusForbidden}, // 不可信代理的转发头被忽略 / Untrusted proxies are ignored
+		{"127.0.0.1", "10.1.2.3", http.StatusOK},
+	}
+	for _, tc := range cases {
+		if code := do(app, tc.remote, tc.forwarded); code != tc.code {
+			t.Errorf("%s via %q: expected %d, got %d", tc.remote, tc.forwarded, tc.code, code)
+		}
+	}
+
+	// 任一项无效时拒绝全部请求 / Any invalid entry denies every request
+	app = newApp(IPFilterConfig{Allow: []string{"10.0.0.0/33", "10.1.2.3.4"}})
+	if code := do(app, "10.1.2.3", ""); code != http.StatusForbidden {
+		t.Errorf("invalid allow list: expected 403, got %d", code)
+	}
+
+	config.New()
+	config.SetKey("ip_filter.groups.admin.allow", []string{"10.0.0.0/8"})
+	app = newApp(IPFilterConfig{Group: "admin", ReloadInterval: 10 * time.Millisecond})
+	if code := do(app, "172.16.0.1", ""); code != http.StatusForbidden {
+		t.Fatalf("expected 403 before reload, got %d", code)
+	}
+	config.SetKey("ip_filter.groups.admin.allow", []string{"172.16.0.0/12"})
+	time.Sleep(20 * time.Millisecond)
+	if code := do(app, "172.16.0.1", ""); code != http.StatusOK {
+		t.Errorf("expected 200 after reload, got %d", code)
+	}
+}
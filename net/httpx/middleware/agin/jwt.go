@@ -0,0 +1,192 @@
+package agin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/small-ek/antgo/crypto/ajwt"
+	"github.com/small-ek/antgo/db/aredis"
+	"github.com/small-ek/antgo/i18n"
+	"github.com/small-ek/antgo/os/alog"
+	"go.uber.org/zap"
+)
+
+const (
+	// ContextKeyClaims JWT 声明在 gin.Context 中的键名，标准库 context 使用 ClaimsFromContext 读取
+	// ContextKeyClaims is the gin.Context key of the JWT claims; use ClaimsFromContext for context.Context
+	ContextKeyClaims = "jwt_claims"
+	// ContextKeyToken 原始 Token 在上下文中的键名 / Context key of the raw token
+	ContextKeyToken = "jwt_token"
+
+	defaultDenyListPrefix = "jwt:deny:"
+)
+
+// claimsContextKey JWT 声明在 context.Context 中的键 / claimsContextKey is the context.Context key of the JWT claims
+type claimsContextKey struct{}
+
+// JWTConfig JWT 认证中间件配置 / JWTConfig configures the JWT authentication middleware
+type JWTConfig struct {
+	Manager        *ajwt.JwtManager    // JWT 管理器，默认 ajwt.New() / JWT manager, defaults to ajwt.New()
+	TokenLookup    []string            // Token 来源，如 "header:Authorization"、"cookie:token"、"query:token" / Token sources
+	AuthScheme     string              // 请求头中的认证方案，默认 "Bearer" / Auth scheme in header, defaults to "Bearer"
+	Optional       bool                // 可选认证：无 Token 时放行 / Optional auth: pass through when no token is present
+	Redis          *aredis.ClientRedis // 吊销名单所在的 Redis，为空则不检查 / Redis holding the deny-list, skipped when nil
+	DenyListPrefix string              // 吊销名单键前缀，默认 "jwt:deny:" / Deny-list key prefix
+	FailOpen       bool                // Redis 不可用时放行而不是返回 503 / Accept tokens instead of answering 503 when Redis is unavailable
+	IDClaim        string              // Token ID 声明名，默认 "jti" / Claim holding the token ID, defaults to "jti"
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *JWTConfig) setDefaults() {
+	if cfg.Manager == nil {
+		cfg.Manager = ajwt.New()
+	}
+	if len(cfg.TokenLookup) == 0 {
+		cfg.TokenLookup = []string{"header:Authorization"}
+	}
+	if cfg.AuthScheme == "" {
+		cfg.AuthScheme = "Bearer"
+	}
+	if cfg.DenyListPrefix == "" {
+		cfg.DenyListPrefix = defaultDenyListPrefix
+	}
+	if cfg.IDClaim == "" {
+		cfg.IDClaim = "jti"
+	}
+}
+
+// JWTAuth JWT 认证中间件 / JWTAuth authenticates requests with ajwt.JwtManager
+func JWTAuth(cfg JWTConfig) gin.HandlerFunc {
+	cfg.setDefaults()
+
+	return func(c *gin.Context) {
+		token := cfg.extractToken(c)
+		if token == "" {
+			if cfg.Optional {
+				c.Next()
+				return
+			}
+			abortWithFail(c, http.StatusUnauthorized, "401", i18n.TDefault(c, "auth.token_missing", "missing token"))
+			return
+		}
+
+		claims, err := cfg.Manager.Parse(token)
+		if err != nil {
+			if errors.Is(err, ajwt.ErrTokenExpired) {
+				abortWithFail(c, http.StatusUnauthorized, "401", i18n.TDefault(c, "auth.token_expired", "token expired"), err.Error())
+				return
+			}
+			abortWithFail(c, http.StatusUnauthorized, "401", i18n.TDefault(c, "auth.token_invalid", "invalid token"), err.Error())
+			return
+		}
+
+		revoked, err := cfg.isRevoked(c.Request.Context(), claims)
+		if err != nil {
+			alog.Write.Error("Check jwt deny-list failed", zap.Error(err))
+			if !cfg.FailOpen {
+				abortWithFail(c, http.StatusServiceUnavailable, "503", i18n.TDefault(c, "auth.unavailable", "authentication unavailable"))
+				return
+			}
+		}
+		if revoked {
+			abortWithFail(c, http.StatusUnauthorized, "401", i18n.TDefault(c, "auth.token_revoked", "token revoked"))
+			return
+		}
+
+		// 同时写入 gin.Context 与标准库 context.Context
+		// Store into both gin.Context and the standard context.Context
+		c.Set(ContextKeyClaims, claims)
+		c.Set(ContextKeyToken, token)
+		ctx := context.WithValue(c.Request.Context(), claimsContextKey{}, claims)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// extractToken 按顺序从请求头、Cookie、查询参数提取 Token
+// extractToken extracts the token from header, cookie or query in order
+func (cfg *JWTConfig) extractToken(c *gin.Context) string {
+	for _, lookup := range cfg.TokenLookup {
+		source, name, found := strings.Cut(lookup, ":")
+		if !found || name == "" {
+			continue
+		}
+
+		var value string
+		switch strings.ToLower(strings.TrimSpace(source)) {
+		case "header":
+			value = c.GetHeader(name)
+			if cfg.AuthScheme != "" {
+				prefix := cfg.AuthScheme + " "
+				if len(value) > len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+					value = value[len(prefix):]
+				}
+			}
+		case "cookie":
+			value, _ = c.Cookie(name)
+		case "query":
+			value = c.Query(name)
+		}
+
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// isRevoked 检查 Token ID 是否在吊销名单中，Redis 出错时返回错误
+// isRevoked checks whether the token ID is on the deny-list, returning Redis errors
+func (cfg *JWTConfig) isRevoked(ctx context.Context, claims jwt.MapClaims) (bool, error) {
+	if cfg.Redis == nil {
+		return false, nil
+	}
+	jti, ok := claims[cfg.IDClaim].(string)
+	if !ok || jti == "" {
+		return false, nil
+	}
+	err := cfg.Redis.Universal().Get(ctx, cfg.DenyListPrefix+jti).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RevokeJWT 将 Token ID 加入吊销名单，ttl 通常为 Token 剩余有效期
+// RevokeJWT puts the token ID on the deny-list; ttl is usually the token's remaining lifetime
+func RevokeJWT(client *aredis.ClientRedis, jti string, ttl time.Duration, prefix ...string) error {
+	key := defaultDenyListPrefix
+	if len(prefix) > 0 && prefix[0] != "" {
+		key = prefix[0]
+	}
+	if err := client.Set(key+jti, 1, ttl.Milliseconds()); err != nil {
+		alog.Write.Error("Revoke jwt failed", zap.String("jti", jti), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// GetClaims 从 gin.Context 获取 JWT 声明 / GetClaims returns the JWT claims from gin.Context
+func GetClaims(c *gin.Context) (jwt.MapClaims, bool) {
+	value, exists := c.Get(ContextKeyClaims)
+	if !exists {
+		return nil, false
+	}
+	claims, ok := value.(jwt.MapClaims)
+	return claims, ok
+}
+
+// ClaimsFromContext 从 context.Context 获取 JWT 声明 / ClaimsFromContext returns the JWT claims from context.Context
+func ClaimsFromContext(ctx context.Context) (jwt.MapClaims, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	claims, ok := ctx.Value(claimsContextKey{}).(jwt.MapClaims)
+	return claims, ok
+}
@@ -0,0 +1,118 @@
+package agin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/small-ek/antgo/crypto/ajwt"
+	"github.com/small-ek/antgo/db/aredis"
+)
+
+// newTestJwtManager 生成测试用的密钥对 / newTestJwtManager generates a key pair for tests
+func newTestJwtManager(t *testing.T) *ajwt.JwtManager {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	privDER := x509.MarshalPKCS1PrivateKey(key)
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key failed: %v", err)
+	}
+	return ajwt.New().
+		SetPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: privDER})).
+		SetPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+}
+
+// TestJWTAuth 测试 Token 提取、校验与可选认证 / TestJWTAuth covers extraction, validation and optional auth
+func TestJWTAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jm := newTestJwtManager(t)
+	token, err := jm.Generate(map[string]interface{}{"user_id": "42"})
+	if err != nil {
+		t.Fatalf("generate token failed: %v", err)
+	}
+
+	handler := func(c *gin.Context) {
+		claims, ok := GetClaims(c)
+		if !ok {
+			c.String(http.StatusOK, "anonymous")
+			return
+		}
+		if ctxClaims, ok := ClaimsFromContext(c.Request.Context()); !ok || ctxClaims["user_id"] != claims["user_id"] {
+			t.Error("claims missing from request context")
+		}
+		c.String(http.StatusOK, claims["user_id"].(string))
+	}
+
+	app := gin.New()
+	app.GET("/strict", JWTAuth(JWTConfig{Manager: jm, TokenLookup: []string{"header:Authorization", "query:token"}}), handler)
+	app.GET("/optional", JWTAuth(JWTConfig{Manager: jm, Optional: true}), handler)
+
+	cases := []struct {
+		name   string
+		url    string
+		header string
+		status int
+		body   string
+	}{
+		{"bearer header", "/strict", "Bearer " + token, http.StatusOK, "42"},
+		{"query", "/strict?token=" + token, "", http.StatusOK, "42"},
+		{"missing", "/strict", "", http.StatusUnauthorized, ""},
+		{"invalid", "/strict", "Bearer bad.token.value", http.StatusUnauthorized, ""},
+		{"optional anonymous", "/optional", "", http.StatusOK, "anonymous"},
+		{"optional with token", "/optional", "Bearer " + token, http.StatusOK, "42"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, w.Code)
+			}
+			if tc.body != "" && w.Body.String() != tc.body {
+				t.Errorf("expected body %q, got %q", tc.body, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestJWTAuthDenyListUnavailable 测试 Redis 不可用时的吊销检查 / TestJWTAuthDenyListUnavailable covers the deny-list check during a Redis outage
+func TestJWTAuthDenyListUnavailable(t *testing.T) {
+	jm := newTestJwtManager(t)
+	token, err := jm.Generate(map[string]interface{}{"user_id": "42", "jti": "t1"})
+	if err != nil {
+		t.Fatalf("generate token failed: %v", err)
+	}
+	// 没有服务监听的地址 / An address nobody listens on
+	down := &aredis.ClientRedis{Mode: true, Ctx: context.Background(), Clients: redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})}
+	defer down.Close()
+
+	app := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	app.GET("/closed", JWTAuth(JWTConfig{Manager: jm, Redis: down}), ok)
+	app.GET("/open", JWTAuth(JWTConfig{Manager: jm, Redis: down, FailOpen: true}), ok)
+
+	for path, status := range map[string]int{"/closed": http.StatusServiceUnavailable, "/open": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("%s: expected status %d, got %d", path, status, w.Code)
+		}
+	}
+}
@@ -23,12 +23,15 @@ var json = jsoniter.ConfigFastest
 // responseBodyWriter 用于捕获响应体 / responseBodyWriter for capturing response body
 type responseBodyWriter struct {
 	gin.ResponseWriter
-	body *bytes.Buffer
+	body      *bytes.Buffer
+	minStatus int // 状态码低于该值时不捕获，日志不会写出 / Skip capturing below this status, no entry is written
 }
 
 // Write 写入响应数据并捕获 / Write response data and capture
 func (r *responseBodyWriter) Write(b []byte) (int, error) {
-	r.body.Write(b)
+	if r.Status() >= r.minStatus {
+		r.body.Write(b)
+	}
 	return r.ResponseWriter.Write(b)
 }
 
@@ -116,6 +119,15 @@ func Logger() gin.HandlerFunc {
 	enableRequestBody := config.GetBool("log.request_body")   // 是否启用请求体Body
 	enableResponseBody := config.GetBool("log.response_body") // 是否启用Debug日志 / enable debug logs
 
+	// 开启独立访问日志时，访问记录写入 log.access.path，应用日志仅保留 4xx 与 5xx 请求
+	// With the dedicated access log enabled, access lines go to log.access.path and the app log keeps only 4xx and 5xx requests
+	var access *accessLogger
+	minStatus := 0
+	if config.GetBool("log.access.enable") {
+		access = newAccessLogger(LoadAccessLogConfig())
+		minStatus = http.StatusBadRequest
+	}
+
 	// 转换跳过方法为map提高查询效率 / Convert skip methods to map for faster lookup
 	skipMethodsMap := make(map[string]bool, len(skipMethods))
 	for _, m := range skipMethods {
@@ -163,25 +175,32 @@ func Logger() gin.HandlerFunc {
 			}
 		}
 
-		// 读取请求体（限制大小） / Read request body (with size limit)
-		maxSize := httpx.CalculateMaxSize(c.Request.ContentLength)
-		requestBody, newRC, err := httpx.ReadBody(c.Request.Body, maxSize)
-		if err != nil {
-			alog.Write.Error("Read request body failed", zap.Error(err))
+		// 读取请求体（限制大小），未开启请求体日志时不缓冲 / Read request body (with size limit), only when it is logged
+		var requestBody []byte
+		if enableRequestBody {
+			maxSize := httpx.CalculateMaxSize(c.Request.ContentLength)
+			body, newRC, err := httpx.ReadBody(c.Request.Body, maxSize)
+			if err != nil {
+				alog.Write.Error("Read request body failed", zap.Error(err))
+			}
+			// 重新构造 c.Request.Body 以便后续的中间件或处理函数使用
+			c.Request.Body = newRC
+			requestBody = body
 		}
-		// 重新构造 c.Request.Body 以便后续的中间件或处理函数使用
-		c.Request.Body = newRC
-
-		// 获取响应体缓冲区 / Get response body buffer
-		buffer := apiBufferPool.Get().(*bytes.Buffer)
-		buffer.Reset()
-		// 注意：这里不再 defer 直接放回池（避免中途被提前 Put）
-		// 包装响应写入器 / Wrap response writer
-		w := &responseBodyWriter{
-			body:           buffer,
-			ResponseWriter: c.Writer,
+
+		// 获取响应体缓冲区，仅在开启响应体日志时包装写入器 / Buffer the response only when it is logged
+		var buffer *bytes.Buffer
+		if enableResponseBody {
+			buffer = apiBufferPool.Get().(*bytes.Buffer)
+			buffer.Reset()
+			// 注意：这里不再 defer 直接放回池（避免中途被提前 Put）
+			// 包装响应写入器 / Wrap response writer
+			c.Writer = &responseBodyWriter{
+				body:           buffer,
+				ResponseWriter: c.Writer,
+				minStatus:      minStatus,
+			}
 		}
-		c.Writer = w
 
 		// 处理请求 / Process request
 		c.Next()
@@ -189,6 +208,13 @@ func Logger() gin.HandlerFunc {
 
 		// 准备日志字段 / Prepare log fields
 		statusCode := c.Writer.Status()
+		if access != nil {
+			access.write(c, startTime, endTime.Sub(startTime))
+			if statusCode < minStatus {
+				putBackBuffer(buffer)
+				return
+			}
+		}
 		path, _ := url.QueryUnescape(c.Request.URL.RequestURI())
 
 		// 预分配字段切片，减少扩容
@@ -206,9 +232,8 @@ func Logger() gin.HandlerFunc {
 		logFields = append(logFields, prepared...)
 
 		// 记录响应体（限制大小） / Record response body (with size limit)
-		responseBody := w.body.Bytes()
-
 		if enableResponseBody {
+			responseBody := buffer.Bytes()
 			var parsedBody interface{}
 			// 尝试解析 JSON
 			if err := json.Unmarshal(responseBody, &parsedBody); err != nil {
@@ -0,0 +1,64 @@
+package agin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestLoggerWithAccessLog 测试开启访问日志后应用日志保留 4xx 与 5xx / TestLoggerWithAccessLog keeps 4xx and 5xx in the app log
+func TestLoggerWithAccessLog(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	alog.Write = zap.New(core)
+	t.Cleanup(func() { alog.Write = zap.NewNop() })
+
+	config.New()
+	config.SetKey("log.access.enable", true)
+	config.SetKey("log.access.path", filepath.Join(t.TempDir(), "access.log"))
+	config.SetKey("log.response_body", true)
+	t.Cleanup(func() {
+		config.SetKey("log.access.enable", false)
+		config.SetKey("log.response_body", false)
+	})
+
+	app := gin.New()
+	app.Use(Logger())
+	app.GET("/:status", func(c *gin.Context) {
+		switch c.Param("status") {
+		case "ok":
+			c.String(http.StatusOK, "fine")
+		case "missing":
+			c.String(http.StatusNotFound, "missing")
+		default:
+			c.String(http.StatusInternalServerError, "boom")
+		}
+	})
+	for _, path := range []string{"/ok", "/missing", "/fail"} {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for logs.Len() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("expected the 4xx and 5xx entries only, got %d", len(entries))
+	}
+	messages := map[string]interface{}{}
+	for _, entry := range entries {
+		messages[entry.Message] = entry.ContextMap()["response_body"]
+	}
+	if messages["HTTP Client Error"] != "missing" || messages["HTTP Server Error"] != "boom" {
+		t.Errorf("unexpected entries %v", messages)
+	}
+}
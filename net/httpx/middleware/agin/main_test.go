@@ -0,0 +1,17 @@
+package agin
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/os/alog"
+	"go.uber.org/zap"
+)
+
+// TestMain 初始化测试所需的日志与 gin 模式 / TestMain sets up logging and gin mode for the tests
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	alog.Write = zap.NewNop()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,12 @@
+package agin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/utils/response"
+)
+
+// abortWithFail 以统一的失败结构终止请求
+// abortWithFail aborts the request with the unified failure body
+func abortWithFail(c *gin.Context, status int, code, msg string, err ...string) {
+	c.AbortWithStatusJSON(status, response.Fail(code, msg, err...))
+}
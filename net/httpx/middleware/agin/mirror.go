@@ -0,0 +1,314 @@
+package agin
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/net/httpx"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"go.uber.org/zap"
+)
+
+// MirrorHeader 影子请求携带的请求头，影子服务可据此跳过外部副作用
+// MirrorHeader marks shadow requests so the shadow service can skip external side effects
+const MirrorHeader = "X-Mirror-Request"
+
+// MirrorConfig 流量镜像配置，对应配置文件 mirror.*
+// MirrorConfig configures traffic mirroring under mirror.*
+type MirrorConfig struct {
+	Upstream     string            `json:"upstream"`      // 影子服务地址，为空时不镜像 / Shadow base URL, empty disables mirroring
+	Percent      float64           `json:"percent"`       // 采样百分比 0-100 / Sampled percentage, 0-100
+	Compare      bool              `json:"compare"`       // 比较状态码与响应体并记录差异 / Compare status and body and log differences
+	IgnoreFields []string          `json:"ignore_fields"` // 比较 JSON 时忽略的顶层字段 / Top-level JSON fields ignored when comparing
+	MaxBodySize  int64             `json:"max_body_size"` // 请求体或响应体超过该大小时不镜像或不比较，默认 1MB / Larger bodies are not mirrored or compared, defaults to 1MB
+	Timeout      time.Duration     `json:"timeout"`       // 影子请求超时，默认 5s / Shadow request timeout, defaults to 5s
+	Concurrency  int               `json:"concurrency"`   // 同时进行的影子请求上限，超出时丢弃，默认 100 / Max in-flight shadow requests, extra ones are dropped, defaults to 100
+	Transport    http.RoundTripper `json:"-"`             // 影子请求使用的传输，默认 http.DefaultTransport / Transport for shadow requests
+}
+
+// LoadMirrorConfig 从配置文件读取流量镜像配置 / LoadMirrorConfig reads mirror.* from the config
+func LoadMirrorConfig() MirrorConfig {
+	return MirrorConfig{
+		Upstream:     config.GetString("mirror.upstream"),
+		Percent:      config.GetFloat64("mirror.percent"),
+		Compare:      config.GetBool("mirror.compare"),
+		IgnoreFields: config.GetStringSlice("mirror.ignore_fields"),
+		MaxBodySize:  config.GetInt64("mirror.max_body_size"),
+		Timeout:      config.GetDuration("mirror.timeout"),
+		Concurrency:  config.GetInt("mirror.concurrency"),
+	}
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *MirrorConfig) setDefaults() {
+	cfg.Upstream = strings.TrimRight(cfg.Upstream, "/")
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = httpx.DefaultBodyMaxSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 100
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport
+	}
+}
+
+// mirrorResult 主请求的响应 / mirrorResult is the primary response
+type mirrorResult struct {
+	status    int
+	body      []byte
+	truncated bool
+}
+
+// Mirror 流量镜像中间件，按比例将请求异步复制到影子服务并丢弃其响应，未传入配置时读取 mirror.*。
+// 影子请求在独立协程中执行，并发已满时直接丢弃，不影响主请求的延迟；开启 Compare 时比较状态码与响应体并记录差异。
+// 未知长度、超过 MaxBodySize 的请求体以及协议升级请求不镜像。
+//
+// Mirror asynchronously copies a sampled share of requests to a shadow upstream and discards its response,
+// reading mirror.* when no config is given. Shadow requests run on their own goroutines and are dropped when the
+// concurrency limit is reached, so primary latency is unaffected; with Compare the status and body are compared and
+// differences are logged. Bodies of unknown length or above MaxBodySize and protocol upgrades are not mirrored.
+func Mirror(cfg ...MirrorConfig) gin.HandlerFunc {
+	var conf MirrorConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	} else {
+		conf = LoadMirrorConfig()
+	}
+	conf.setDefaults()
+	if conf.Upstream == "" || conf.Percent <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	client := &http.Client{
+		Transport: conf.Transport,
+		// 重定向原样返回，与主请求保持一致 / Redirects are returned as-is, like the primary response
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	sem := make(chan struct{}, conf.Concurrency)
+
+	return func(c *gin.Context) {
+		req := c.Request
+		if rand.Float64()*100 >= conf.Percent || req.Header.Get("Upgrade") != "" ||
+			req.ContentLength < 0 || req.ContentLength > conf.MaxBodySize {
+			c.Next()
+			return
+		}
+		select {
+		case sem <- struct{}{}:
+		default:
+			c.Next()
+			return
+		}
+
+		body, rc, err := httpx.ReadBody(req.Body, conf.MaxBodySize)
+		if err != nil {
+			<-sem
+			c.Request.Body = io.NopCloser(&errorReader{err: err})
+			c.Next()
+			return
+		}
+		c.Request.Body = rc
+
+		// 在处理函数修改请求之前取出影子请求需要的数据 / Capture the shadow request before handlers can modify it
+		shadow := &mirrorRequest{
+			method:    req.Method,
+			url:       conf.Upstream + req.URL.RequestURI(),
+			header:    mirrorHeader(req.Header),
+			body:      body,
+			requestID: getRequestID(c),
+		}
+		var primary chan mirrorResult
+		if conf.Compare {
+			primary = make(chan mirrorResult, 1)
+			writer := &mirrorWriter{ResponseWriter: c.Writer, limit: conf.MaxBodySize}
+			c.Writer = writer
+			// 发生 panic 时同样交出结果，避免影子协程一直等待 / Hand over the result even on panic so the shadow goroutine never waits forever
+			defer func() {
+				c.Writer = writer.ResponseWriter
+				primary <- mirrorResult{status: writer.Status(), body: writer.body.Bytes(), truncated: writer.truncated}
+			}()
+		}
+
+		go func() {
+			defer func() { <-sem }()
+			shadow.send(client, &conf, primary)
+		}()
+		c.Next()
+	}
+}
+
+// mirrorRequest 影子请求 / mirrorRequest is a shadow request
+type mirrorRequest struct {
+	method    string
+	url       string
+	header    http.Header
+	body      []byte
+	requestID string
+}
+
+// send 发送影子请求，需要时与主请求比较 / send issues the shadow request and compares it with the primary response when asked
+func (m *mirrorRequest) send(client *http.Client, conf *MirrorConfig, primary <-chan mirrorResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.Timeout)
+	defer cancel()
+
+	fields := []zap.Field{
+		zap.String("method", m.method),
+		zap.String("url", m.url),
+		zap.String("request_id", m.requestID),
+	}
+	req, err := http.NewRequestWithContext(ctx, m.method, m.url, bytes.NewReader(m.body))
+	if err != nil {
+		alog.Write.Warn("Mirror request failed", append(fields, zap.Error(err))...)
+		return
+	}
+	req.Header = m.header
+	resp, err := client.Do(req)
+	if err != nil {
+		alog.Write.Warn("Mirror request failed", append(fields, zap.Error(err))...)
+		return
+	}
+	defer resp.Body.Close()
+
+	if primary == nil {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, conf.MaxBodySize))
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, conf.MaxBodySize+1))
+	if err != nil {
+		alog.Write.Warn("Mirror request failed", append(fields, zap.Error(err))...)
+		return
+	}
+
+	result := <-primary
+	// 任一响应体被截断时只比较状态码 / Only the status is compared when either body was truncated
+	compareBody := !result.truncated && int64(len(body)) <= conf.MaxBodySize
+	var diff []string
+	bodyEqual := true
+	if compareBody {
+		diff, bodyEqual = mirrorDiff(result.body, body, conf.IgnoreFields)
+	}
+	if result.status == resp.StatusCode && bodyEqual {
+		return
+	}
+
+	fields = append(fields,
+		zap.Int("primary_status", result.status),
+		zap.Int("shadow_status", resp.StatusCode),
+	)
+	if len(diff) > 0 {
+		fields = append(fields, zap.Strings("fields", diff))
+	} else if !bodyEqual {
+		fields = append(fields,
+			zap.String("primary_body", truncateBody(result.body)),
+			zap.String("shadow_body", truncateBody(body)),
+		)
+	}
+	alog.Write.Warn("Mirror response mismatch", fields...)
+}
+
+// mirrorDiff 比较响应体，两者均为 JSON 对象时返回不同的顶层字段
+// mirrorDiff compares bodies and returns the differing top-level fields when both are JSON objects
+func mirrorDiff(primary, shadow []byte, ignore []string) ([]string, bool) {
+	var a, b interface{}
+	if json.Unmarshal(primary, &a) != nil || json.Unmarshal(shadow, &b) != nil {
+		return nil, bytes.Equal(primary, shadow)
+	}
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		return nil, reflect.DeepEqual(a, b)
+	}
+	for _, field := range ignore {
+		delete(am, field)
+		delete(bm, field)
+	}
+
+	var diff []string
+	for key, value := range am {
+		if other, ok := bm[key]; !ok || !reflect.DeepEqual(value, other) {
+			diff = append(diff, key)
+		}
+	}
+	for key := range bm {
+		if _, ok := am[key]; !ok {
+			diff = append(diff, key)
+		}
+	}
+	sort.Strings(diff)
+	return diff, len(diff) == 0
+}
+
+// mirrorHeader 复制请求头并去掉逐跳头 / mirrorHeader copies the header without hop-by-hop fields
+func mirrorHeader(src http.Header) http.Header {
+	header := src.Clone()
+	for _, key := range []string{"Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade"} {
+		header.Del(key)
+	}
+	header.Set(MirrorHeader, "1")
+	return header
+}
+
+// truncateBody 截断日志中的响应体 / truncateBody shortens a body for logging
+func truncateBody(body []byte) string {
+	const max = 256
+	if len(body) > max {
+		return string(body[:max]) + "..."
+	}
+	return string(body)
+}
+
+// mirrorWriter 在写出响应的同时保留副本用于比较 / mirrorWriter keeps a copy of the response for comparison while writing it
+type mirrorWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int64
+	truncated bool
+}
+
+// Write 写入响应数据并保留副本 / Write writes response data and keeps a copy
+func (w *mirrorWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// WriteString 写入字符串并保留副本 / WriteString writes a string and keeps a copy
+func (w *mirrorWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// capture 保留不超过上限的副本 / capture keeps a copy up to the limit
+func (w *mirrorWriter) capture(b []byte) {
+	if w.truncated {
+		return
+	}
+	if int64(w.body.Len()+len(b)) > w.limit {
+		w.truncated = true
+		return
+	}
+	w.body.Write(b)
+}
+
+// errorReader 读取时返回原始错误，使处理函数看到与未镜像时相同的失败
+// errorReader returns the original read error so handlers see the same failure as without mirroring
+type errorReader struct {
+	err error
+}
+
+// Read 返回读取错误 / Read returns the read error
+func (r *errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,108 @@
+package agin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/os/alog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestMirror 测试影子请求内容、差异记录与不阻塞主请求 / TestMirror covers shadow requests, mismatch logs and non-blocking primaries
+func TestMirror(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	defer func(old *zap.Logger) { alog.Write = old }(alog.Write)
+	alog.Write = zap.New(core)
+
+	received := make(chan string, 4)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.Method + " " + r.URL.RequestURI() + " " + r.Header.Get(MirrorHeader) + " " + string(body)
+		time.Sleep(200 * time.Millisecond)
+		if strings.Contains(string(body), "same") {
+			_, _ = io.WriteString(w, `{"id":1,"time":9}`)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, `{"id":2,"time":9,"extra":true}`)
+	}))
+	defer shadow.Close()
+
+	app := gin.New()
+	app.Use(Mirror(MirrorConfig{Upstream: shadow.URL + "/", Percent: 100, Compare: true, IgnoreFields: []string{"time"}}))
+	app.POST("/items", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Header("X-Primary-Body", string(body))
+		c.String(http.StatusOK, `{"id":1,"time":1}`)
+	})
+
+	send := func(body string) {
+		start := time.Now()
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items?x=1", strings.NewReader(body)))
+		if time.Since(start) > 100*time.Millisecond {
+			t.Errorf("primary request waited for the shadow: %s", time.Since(start))
+		}
+		if w.Header().Get("X-Primary-Body") != body {
+			t.Errorf("primary handler lost the body: %q", w.Header().Get("X-Primary-Body"))
+		}
+		if got := <-received; got != "POST /items?x=1 1 "+body {
+			t.Errorf("unexpected shadow request %q", got)
+		}
+	}
+	waitLogs := func(n int) {
+		deadline := time.Now().Add(2 * time.Second)
+		for logs.Len() < n && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	send(`{"name":"diff"}`)
+	waitLogs(1)
+	entries := logs.TakeAll()
+	if len(entries) != 1 || entries[0].Message != "Mirror response mismatch" {
+		t.Fatalf("expected one mismatch log, got %v", entries)
+	}
+	fields := entries[0].ContextMap()
+	if fields["primary_status"] != int64(200) || fields["shadow_status"] != int64(201) {
+		t.Errorf("unexpected statuses %v", fields)
+	}
+	if diff, _ := fields["fields"].([]interface{}); len(diff) != 2 || diff[0] != "extra" || diff[1] != "id" {
+		t.Errorf("unexpected differing fields %v", fields["fields"])
+	}
+
+	send(`{"name":"same"}`)
+	time.Sleep(300 * time.Millisecond)
+	if logs.Len() != 0 {
+		t.Errorf("expected no log for matching responses, got %v", logs.All())
+	}
+
+	disabled := gin.New()
+	disabled.Use(Mirror(MirrorConfig{Upstream: shadow.URL, Percent: 0}))
+	disabled.POST("/items", func(c *gin.Context) { c.Status(http.StatusOK) })
+	disabled.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/items", strings.NewReader("{}")))
+	select {
+	case got := <-received:
+		t.Errorf("unexpected shadow request %q with 0%% sampling", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestMirrorDiff 测试响应体比较 / TestMirrorDiff covers body comparison
+func TestMirrorDiff(t *testing.T) {
+	if _, equal := mirrorDiff([]byte(`[1,2]`), []byte(`[1, 2]`), nil); !equal {
+		t.Error("expected equal JSON arrays")
+	}
+	if _, equal := mirrorDiff([]byte("a"), []byte("b"), nil); equal {
+		t.Error("expected different plain bodies")
+	}
+	if diff, equal := mirrorDiff([]byte(`{"a":1,"b":{"c":1}}`), []byte(`{"a":1,"b":{"c":2}}`), nil); equal || len(diff) != 1 || diff[0] != "b" {
+		t.Errorf("unexpected diff %v", diff)
+	}
+}
@@ -0,0 +1,196 @@
+package agin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/small-ek/antgo/aemail"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"go.uber.org/zap"
+)
+
+// PanicEvent panic 通知内容 / PanicEvent describes a recovered panic
+type PanicEvent struct {
+	RequestID string    `json:"request_id"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	ClientIP  string    `json:"client_ip"`
+	Panic     string    `json:"panic"`
+	PanicAt   string    `json:"panic_at"`
+	Stack     []string  `json:"stack"`
+	Time      time.Time `json:"time"`
+	// Suppressed 上次通知后被去重或限流的次数 / Number of events suppressed since the last notification
+	Suppressed int `json:"suppressed"`
+}
+
+// PanicNotifier panic 通知接口 / PanicNotifier delivers panic alerts
+type PanicNotifier interface {
+	Notify(ctx context.Context, event PanicEvent) error
+}
+
+// PanicNotifierFunc 函数形式的通知 / PanicNotifierFunc adapts a function to PanicNotifier
+type PanicNotifierFunc func(ctx context.Context, event PanicEvent) error
+
+// Notify 发送通知 / Notify delivers the alert
+func (f PanicNotifierFunc) Notify(ctx context.Context, event PanicEvent) error {
+	return f(ctx, event)
+}
+
+// MailNotifier 邮件通知，To 为空时读取 email.to / MailNotifier sends alerts by mail, To defaults to email.to
+type MailNotifier struct {
+	Mailer *aemail.Mailer
+	To     []string
+}
+
+// Notify 发送邮件 / Notify sends the mail
+func (n MailNotifier) Notify(_ context.Context, event PanicEvent) error {
+	to := n.To
+	if len(to) == 0 {
+		to = config.GetStringSlice("email.to")
+	}
+	subject := fmt.Sprintf("[%s] panic: %s", config.GetString("system.app_name"), event.Panic)
+	return n.Mailer.QuickSend(to, subject, formatPanicEvent(event))
+}
+
+// WebhookNotifier 以 JSON POST 发送通知 / WebhookNotifier posts the event as JSON
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client // 默认 5 秒超时 / Defaults to a 5 second timeout
+}
+
+// Notify 发送请求 / Notify posts the event
+func (n WebhookNotifier) Notify(ctx context.Context, event PanicEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// formatPanicEvent 格式化为文本 / formatPanicEvent renders the event as text
+func formatPanicEvent(event PanicEvent) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Time: %s\n", event.Time.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Request: %s %s\n", event.Method, event.Path)
+	fmt.Fprintf(&b, "Request ID: %s\n", event.RequestID)
+	fmt.Fprintf(&b, "Client IP: %s\n", event.ClientIP)
+	fmt.Fprintf(&b, "Panic: %s\n", event.Panic)
+	fmt.Fprintf(&b, "Panic at: %s\n", event.PanicAt)
+	if event.Suppressed > 0 {
+		fmt.Fprintf(&b, "Suppressed: %d\n", event.Suppressed)
+	}
+	b.WriteString("\n")
+	b.WriteString(strings.Join(event.Stack, "\n"))
+	return b.String()
+}
+
+// panicAlerter 按 panic 位置去重并限流后异步发送通知
+// panicAlerter de-duplicates by panic location, rate limits and delivers asynchronously
+type panicAlerter struct {
+	notifiers []PanicNotifier
+	window    time.Duration
+	limit     int
+
+	mu          sync.Mutex
+	lastSent    map[string]time.Time
+	minute      time.Time
+	sentInMin   int
+	suppressed  map[string]int
+	lastCleanup time.Time
+}
+
+// newPanicAlerter 创建通知器 / newPanicAlerter creates the alerter
+func newPanicAlerter(notifiers []PanicNotifier, window time.Duration, limit int) *panicAlerter {
+	return &panicAlerter{
+		notifiers:  notifiers,
+		window:     window,
+		limit:      limit,
+		lastSent:   make(map[string]time.Time),
+		suppressed: make(map[string]int),
+	}
+}
+
+// allow 判断是否发送，返回被抑制的次数 / allow decides whether to send and returns the suppressed count
+func (a *panicAlerter) allow(key string, now time.Time) (bool, int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// 定期清理过期的去重记录 / Periodically drop expired de-duplication entries
+	if now.Sub(a.lastCleanup) > a.window {
+		for k, t := range a.lastSent {
+			if now.Sub(t) > a.window {
+				delete(a.lastSent, k)
+			}
+		}
+		a.lastCleanup = now
+	}
+
+	if last, ok := a.lastSent[key]; ok && now.Sub(last) < a.window {
+		a.suppressed[key]++
+		return false, 0
+	}
+	if minute := now.Truncate(time.Minute); !minute.Equal(a.minute) {
+		a.minute, a.sentInMin = minute, 0
+	}
+	if a.sentInMin >= a.limit {
+		a.suppressed[key]++
+		return false, 0
+	}
+
+	a.sentInMin++
+	a.lastSent[key] = now
+	suppressed := a.suppressed[key]
+	delete(a.suppressed, key)
+	return true, suppressed
+}
+
+// notify 异步发送通知 / notify delivers the alert asynchronously
+func (a *panicAlerter) notify(event PanicEvent) {
+	if len(a.notifiers) == 0 {
+		return
+	}
+	ok, suppressed := a.allow(event.PanicAt, event.Time)
+	if !ok {
+		return
+	}
+	event.Suppressed = suppressed
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				alog.Write.Error("Panic notifier panicked", zap.Any("panic", r))
+			}
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		for _, n := range a.notifiers {
+			if err := n.Notify(ctx, event); err != nil {
+				alog.Write.Error("Panic notification failed", zap.String("panic_at", event.PanicAt), zap.Error(err))
+			}
+		}
+	}()
+}
@@ -6,10 +6,15 @@ import (
 	"io"
 	"net/http"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/i18n"
 	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"github.com/small-ek/antgo/utils/response"
 	"go.uber.org/zap"
 )
 
@@ -18,11 +23,59 @@ const (
 	bodyKey     = "recovery_body"
 )
 
-// Recovery 捕获 panic 并记录结构化日志
-func Recovery() gin.HandlerFunc {
+// RecoveryConfig panic 恢复配置，零值字段读取 system.debug 与 recovery.* 配置
+// RecoveryConfig configures panic recovery; zero fields fall back to system.debug and recovery.*
+type RecoveryConfig struct {
+	Debug        bool            // 响应中返回 panic 信息与 panic_at 位置，默认 system.debug / Return the panic and panic_at in the body
+	Status       int             // 响应状态码，默认 500 / Response status, defaults to 500
+	Code         string          // 业务状态码，默认与状态码一致 / Business code, defaults to the status
+	Message      string          // 响应提示，默认翻译 server.error / Message, defaults to the server.error translation
+	Notifiers    []PanicNotifier // panic 通知 / Panic notifiers
+	DedupeWindow time.Duration   // 同一位置的通知间隔，默认 recovery.dedupe_window 或 10m / Per-location notification interval
+	RateLimit    int             // 每分钟最多通知次数，默认 recovery.rate_limit 或 10 / Max notifications per minute
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *RecoveryConfig) setDefaults() {
+	if !cfg.Debug {
+		cfg.Debug = config.GetBool("system.debug")
+	}
+	if cfg.Status == 0 {
+		cfg.Status = http.StatusInternalServerError
+	}
+	if cfg.Code == "" {
+		cfg.Code = strconv.Itoa(cfg.Status)
+	}
+	if cfg.DedupeWindow <= 0 {
+		cfg.DedupeWindow = config.GetDuration("recovery.dedupe_window")
+	}
+	if cfg.DedupeWindow <= 0 {
+		cfg.DedupeWindow = 10 * time.Minute
+	}
+	if cfg.RateLimit <= 0 {
+		cfg.RateLimit = config.GetInt("recovery.rate_limit")
+	}
+	if cfg.RateLimit <= 0 {
+		cfg.RateLimit = 10
+	}
+}
+
+// Recovery 捕获 panic 并记录结构化日志，返回带 request_id 的 response.Fail 响应，
+// 调试模式下附带 panic 信息与 panic_at 位置，并按位置去重、限流后发送通知。
+//
+// Recovery recovers panics, logs them and answers with a response.Fail body carrying the request_id.
+// Debug mode adds the panic and its panic_at location; notifiers are de-duplicated by location and rate limited.
+func Recovery(cfg ...RecoveryConfig) gin.HandlerFunc {
+	var conf RecoveryConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	}
+	conf.setDefaults()
+	alerter := newPanicAlerter(conf.Notifiers, conf.DedupeWindow, conf.RateLimit)
+
 	return func(c *gin.Context) {
 		cacheRequestBody(c)
-		defer handlePanic(c)
+		defer handlePanic(c, &conf, alerter)
 		c.Next()
 	}
 }
@@ -43,13 +96,45 @@ func cacheRequestBody(c *gin.Context) {
 }
 
 // handlePanic 统一处理 panic
-func handlePanic(c *gin.Context) {
-	if err := recover(); err != nil {
-		stack := debug.Stack()
-		fields := buildLogFields(c, err, stack)
-		alog.Write.Error("Recovery from panic", fields...)
-		c.AbortWithStatus(http.StatusInternalServerError)
+func handlePanic(c *gin.Context, conf *RecoveryConfig, alerter *panicAlerter) {
+	err := recover()
+	if err == nil {
+		return
+	}
+	stack := debug.Stack()
+	panicAt := extractPanicLocation(stack)
+	fields := buildLogFields(c, err, stack)
+	alog.Write.Error("Recovery from panic", fields...)
+
+	alerter.notify(PanicEvent{
+		RequestID: getRequestID(c),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		ClientIP:  c.ClientIP(),
+		Panic:     fmt.Sprint(err),
+		PanicAt:   panicAt,
+		Stack:     SplitStack(stack),
+		Time:      time.Now(),
+	})
+
+	// 已开始写响应时无法再输出错误结构 / The error body cannot be sent once the response has started
+	if c.Writer.Written() {
+		c.Abort()
+		return
+	}
+
+	msg := conf.Message
+	if msg == "" {
+		msg = i18n.TDefault(c, "server.error", "internal server error")
+	}
+	data := map[string]interface{}{"request_id": getRequestID(c)}
+	result := response.Fail(conf.Code, msg)
+	if conf.Debug {
+		data["panic_at"] = panicAt
+		result.Error = fmt.Sprint(err)
 	}
+	result.Data = data
+	c.AbortWithStatusJSON(conf.Status, result)
 }
 
 // buildLogFields 构建日志字段
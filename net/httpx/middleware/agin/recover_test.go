@@ -0,0 +1,86 @@
+package agin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRecovery 测试 panic 响应体与调试信息 / TestRecovery covers the panic body and debug details
+func TestRecovery(t *testing.T) {
+	for _, debug := range []bool{false, true} {
+		app := gin.New()
+		app.Use(Recovery(RecoveryConfig{Debug: debug}))
+		app.GET("/", func(c *gin.Context) { panic("boom") })
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-Id", "req-1")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500, got %d", w.Code)
+		}
+
+		var body struct {
+			Code  string                 `json:"code"`
+			Data  map[string]interface{} `json:"data"`
+			Error string                 `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unexpected body %q", w.Body.String())
+		}
+		if body.Code != "500" || body.Data["request_id"] != "req-1" {
+			t.Errorf("unexpected body %q", w.Body.String())
+		}
+		if _, ok := body.Data["panic_at"]; ok != debug || (body.Error == "boom") != debug {
+			t.Errorf("debug=%v: unexpected details %q", debug, w.Body.String())
+		}
+	}
+}
+
+// TestPanicAlerter 测试通知去重与限流 / TestPanicAlerter covers de-duplication and rate limiting
+func TestPanicAlerter(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []PanicEvent
+		wg     sync.WaitGroup
+	)
+	notifier := PanicNotifierFunc(func(_ context.Context, event PanicEvent) error {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+		wg.Done()
+		return nil
+	})
+	alerter := newPanicAlerter([]PanicNotifier{notifier}, time.Minute, 2)
+
+	now := time.Now()
+	send := func(at string, offset time.Duration) {
+		alerter.notify(PanicEvent{PanicAt: at, Time: now.Add(offset)})
+	}
+
+	wg.Add(2)
+	send("a.go:1", 0)
+	send("a.go:1", time.Second) // 去重 / de-duplicated
+	send("b.go:2", 0)
+	send("c.go:3", 0) // 限流 / rate limited
+	wg.Wait()
+
+	wg.Add(1)
+	send("a.go:1", 2*time.Minute)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 3 {
+		t.Fatalf("expected 3 notifications, got %d", len(events))
+	}
+	if last := events[2]; last.PanicAt != "a.go:1" || last.Suppressed != 1 {
+		t.Errorf("expected the suppressed count on the repeated location, got %+v", last)
+	}
+}
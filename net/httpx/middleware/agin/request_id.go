@@ -3,13 +3,19 @@ package agin
 import (
 	"context"
 	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/crypto/auuid"
 )
 
-// WithContextRequestID 设置请求 ID上下文
-// WithContextRequestID sets the request ID context
+// WithContextRequestID 设置请求 ID上下文，请求未携带 X-Request-Id 时生成并写回请求头，供日志与上游转发使用
+// WithContextRequestID sets the request ID context, generating one into the X-Request-Id header when missing
+// so logs and forwarded requests carry it
 func WithContextRequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requestID := c.GetHeader("x-request-id")
+		requestID := c.GetHeader("X-Request-Id")
+		if requestID == "" {
+			requestID = auuid.New().String()
+			c.Request.Header.Set("X-Request-Id", requestID)
+		}
 		c.Set("request_id", requestID)
 
 		// 将参数存入标准库的 context.Context
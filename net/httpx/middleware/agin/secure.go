@@ -0,0 +1,171 @@
+package agin
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/crypto/arand"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"go.uber.org/zap"
+)
+
+// ContextKeyCSPNonce CSP nonce 在上下文中的键名 / Context key of the CSP nonce
+const ContextKeyCSPNonce = "csp_nonce"
+
+// cspNoncePlaceholder CSP 中的 nonce 占位符 / Nonce placeholder inside the CSP
+const cspNoncePlaceholder = "{nonce}"
+
+// SecureConfig 安全响应头配置，对应配置文件 secure.*，字段为 "-" 时不输出该响应头
+// SecureConfig configures the security headers under secure.*; a "-" value omits the header
+type SecureConfig struct {
+	HSTSMaxAge            int    `json:"hsts_max_age"`            // HSTS 有效期（秒），0 为关闭 / HSTS max-age in seconds, 0 disables it
+	HSTSIncludeSubdomains bool   `json:"hsts_include_subdomains"` // HSTS 是否包含子域名 / Add includeSubDomains
+	HSTSPreload           bool   `json:"hsts_preload"`            // HSTS 是否加入 preload / Add preload
+	CSP                   string `json:"csp"`                     // 内容安全策略，"{nonce}" 替换为每个请求的随机值 / Content-Security-Policy, "{nonce}" is replaced per request
+	CSPReportOnly         bool   `json:"csp_report_only"`         // 仅报告模式 / Send Content-Security-Policy-Report-Only instead
+	ContentTypeOptions    string `json:"content_type_options"`    // 默认 "nosniff" / Defaults to "nosniff"
+	FrameOptions          string `json:"frame_options"`           // 默认 "SAMEORIGIN" / Defaults to "SAMEORIGIN"
+	ReferrerPolicy        string `json:"referrer_policy"`         // 默认 "strict-origin-when-cross-origin" / Defaults to "strict-origin-when-cross-origin"
+	PermissionsPolicy     string `json:"permissions_policy"`      // 权限策略 / Permissions-Policy
+	// 可信代理 IP 或 CIDR，仅信任其转发的 X-Forwarded-Proto，默认 system.trusted_proxies
+	// Proxy IPs or CIDRs whose X-Forwarded-Proto is trusted, defaults to system.trusted_proxies
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+// LoadSecureConfig 从配置文件读取安全响应头配置 / LoadSecureConfig reads the security headers from secure.*
+func LoadSecureConfig() SecureConfig {
+	return SecureConfig{
+		HSTSMaxAge:            config.GetInt("secure.hsts_max_age"),
+		HSTSIncludeSubdomains: config.GetBool("secure.hsts_include_subdomains"),
+		HSTSPreload:           config.GetBool("secure.hsts_preload"),
+		CSP:                   config.GetString("secure.csp"),
+		CSPReportOnly:         config.GetBool("secure.csp_report_only"),
+		ContentTypeOptions:    config.GetString("secure.content_type_options"),
+		FrameOptions:          config.GetString("secure.frame_options"),
+		ReferrerPolicy:        config.GetString("secure.referrer_policy"),
+		PermissionsPolicy:     config.GetString("secure.permissions_policy"),
+	}
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *SecureConfig) setDefaults() {
+	if cfg.ContentTypeOptions == "" {
+		cfg.ContentTypeOptions = "nosniff"
+	}
+	if cfg.FrameOptions == "" {
+		cfg.FrameOptions = "SAMEORIGIN"
+	}
+	if cfg.ReferrerPolicy == "" {
+		cfg.ReferrerPolicy = "strict-origin-when-cross-origin"
+	}
+	if cfg.TrustedProxies == nil {
+		cfg.TrustedProxies = config.GetStringSlice("system.trusted_proxies")
+	}
+}
+
+// Secure 安全响应头中间件，未传入配置时读取 secure.*。
+// CSP 中包含 "{nonce}" 时为每个请求生成 nonce，模板中通过 GetCSPNonce(c) 获取。
+//
+// Secure sets the security headers, reading secure.* when no config is given.
+// When the CSP contains "{nonce}" a nonce is generated per request; templates read it with GetCSPNonce(c).
+func Secure(cfg ...SecureConfig) gin.HandlerFunc {
+	var conf SecureConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	} else {
+		conf = LoadSecureConfig()
+	}
+	conf.setDefaults()
+
+	static := make(map[string]string, 4)
+	for name, value := range map[string]string{
+		"X-Content-Type-Options": conf.ContentTypeOptions,
+		"X-Frame-Options":        conf.FrameOptions,
+		"Referrer-Policy":        conf.ReferrerPolicy,
+		"Permissions-Policy":     conf.PermissionsPolicy,
+	} {
+		if value != "" && value != "-" {
+			static[name] = value
+		}
+	}
+
+	hsts := ""
+	if conf.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(conf.HSTSMaxAge)
+		if conf.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if conf.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	cspHeader := "Content-Security-Policy"
+	if conf.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	useNonce := strings.Contains(conf.CSP, cspNoncePlaceholder)
+	// 无效的代理地址不被信任 / Invalid proxy entries are simply not trusted
+	proxies, err := parseIPList(conf.TrustedProxies)
+	if err != nil {
+		alog.Write.Error("Invalid secure trusted proxies", zap.Error(err))
+	}
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		for name, value := range static {
+			header.Set(name, value)
+		}
+		// HSTS 仅在 HTTPS 下发送 / HSTS is only sent over HTTPS
+		if hsts != "" && isHTTPS(c, proxies) {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+
+		if conf.CSP != "" && conf.CSP != "-" {
+			csp := conf.CSP
+			if useNonce {
+				nonce, err := arand.RandomString(24)
+				if err != nil {
+					alog.Write.Error("Generate CSP nonce failed", zap.Error(err))
+				} else {
+					c.Set(ContextKeyCSPNonce, string(nonce))
+					csp = strings.ReplaceAll(csp, cspNoncePlaceholder, string(nonce))
+				}
+			}
+			header.Set(cspHeader, csp)
+		}
+		c.Next()
+	}
+}
+
+// GetCSPNonce 获取当前请求的 CSP nonce，用于模板中的 <script nonce="...">
+// GetCSPNonce returns the request's CSP nonce for <script nonce="..."> in templates
+func GetCSPNonce(c *gin.Context) string {
+	return c.GetString(ContextKeyCSPNonce)
+}
+
+// isHTTPS 判断请求是否通过 HTTPS，X-Forwarded-Proto 仅在直连地址属于可信代理时生效，与 gin 的 SetTrustedProxies 规则一致
+// isHTTPS reports whether the request came over HTTPS; X-Forwarded-Proto only counts when the peer is a trusted proxy,
+// matching gin's SetTrustedProxies rules
+func isHTTPS(c *gin.Context, proxies []netip.Prefix) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+	if !strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		return false
+	}
+	addr, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
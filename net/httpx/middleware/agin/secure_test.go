@@ -0,0 +1,110 @@
+package agin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestSecure 测试安全响应头与 CSP nonce / TestSecure covers the security headers and the CSP nonce
+func TestSecure(t *testing.T) {
+	app := gin.New()
+	app.Use(Secure(SecureConfig{
+		HSTSMaxAge:     31536000,
+		CSP:            "script-src 'self' 'nonce-{nonce}'",
+		FrameOptions:   "DENY",
+		ReferrerPolicy: "-",
+		TrustedProxies: []string{"192.0.2.0/24"},
+	}))
+	app.GET("/", func(c *gin.Context) { c.String(http.StatusOK, GetCSPNonce(c)) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	nonce := w.Body.String()
+	if nonce == "" || w.Header().Get("Content-Security-Policy") != "script-src 'self' 'nonce-"+nonce+"'" {
+		t.Errorf("unexpected CSP %q with nonce %q", w.Header().Get("Content-Security-Policy"), nonce)
+	}
+	if w.Header().Get("Strict-Transport-Security") != "max-age=31536000" {
+		t.Errorf("unexpected HSTS %q", w.Header().Get("Strict-Transport-Security"))
+	}
+	if w.Header().Get("X-Frame-Options") != "DENY" || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("missing frame/content type options")
+	}
+	if w.Header().Get("Referrer-Policy") != "" {
+		t.Error("\"-\" should omit the header")
+	}
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("HSTS should only be sent over HTTPS")
+	}
+	if w.Body.String() == nonce {
+		t.Error("nonce should differ per request")
+	}
+
+	// 非可信代理伪造的 X-Forwarded-Proto 不生效 / A forged X-Forwarded-Proto from an untrusted peer is ignored
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:4321"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("HSTS should ignore X-Forwarded-Proto from untrusted peers")
+	}
+}
+
+// TestCSRF 测试双重提交 Cookie 校验 / TestCSRF covers the double-submit cookie check
+func TestCSRF(t *testing.T) {
+	app := gin.New()
+	app.Use(CSRF(CSRFConfig{Secret: "secret", Exempt: []string{"/hooks/*"}}))
+	app.GET("/form", func(c *gin.Context) { c.String(http.StatusOK, GetCSRFToken(c)) })
+	app.POST("/form", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	app.POST("/hooks/pay", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/form", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != w.Body.String() {
+		t.Fatalf("expected the token cookie, got %v", cookies)
+	}
+	cookie := cookies[0]
+
+	post := func(path, header, form string, cookie *http.Cookie) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			req.Header.Set("X-CSRF-Token", header)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post("/form", cookie.Value, "", cookie); code != http.StatusOK {
+		t.Errorf("header token: expected 200, got %d", code)
+	}
+	if code := post("/form", "", "_csrf="+url.QueryEscape(cookie.Value), cookie); code != http.StatusOK {
+		t.Errorf("form token: expected 200, got %d", code)
+	}
+	if code := post("/form", "wrong", "", cookie); code != http.StatusForbidden {
+		t.Errorf("wrong token: expected 403, got %d", code)
+	}
+	forged := &http.Cookie{Name: cookie.Name, Value: "forged.sign"}
+	if code := post("/form", "forged.sign", "", forged); code != http.StatusForbidden {
+		t.Errorf("unsigned cookie: expected 403, got %d", code)
+	}
+	if code := post("/hooks/pay", "", "", nil); code != http.StatusOK {
+		t.Errorf("exempt path: expected 200, got %d", code)
+	}
+}
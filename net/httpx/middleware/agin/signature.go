@@ -0,0 +1,172 @@
+package agin
+
+import (
+	"crypto/hmac"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/db/aredis"
+	"github.com/small-ek/antgo/i18n"
+	"github.com/small-ek/antgo/net/httpx"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"go.uber.org/zap"
+)
+
+// ContextKeyAppID 验签通过的应用 ID 在上下文中的键名 / Context key of the verified app ID
+const ContextKeyAppID = "app_id"
+
+// SecretStore 按应用 ID 查询签名密钥 / SecretStore looks up the signing secret of an app
+type SecretStore interface {
+	Secret(appID string) (string, error)
+}
+
+// SecretStoreFunc 函数形式的密钥查询 / SecretStoreFunc adapts a function to SecretStore
+type SecretStoreFunc func(appID string) (string, error)
+
+// Secret 查询密钥 / Secret looks up the secret
+func (f SecretStoreFunc) Secret(appID string) (string, error) {
+	return f(appID)
+}
+
+// ConfigSecretStore 从配置文件读取密钥，键为 <Key>.<appID>，默认 signature.apps
+// ConfigSecretStore reads secrets from <Key>.<appID> in the config, defaulting to signature.apps
+type ConfigSecretStore struct {
+	Key string
+}
+
+// Secret 查询密钥 / Secret looks up the secret
+func (s ConfigSecretStore) Secret(appID string) (string, error) {
+	key := s.Key
+	if key == "" {
+		key = "signature.apps"
+	}
+	return config.GetString(key + "." + appID), nil
+}
+
+// NonceStore 记录已使用的随机串，首次使用返回 true，存储不可用时返回错误
+// NonceStore records used nonces, returning true on first use and an error when the store is unavailable
+type NonceStore interface {
+	Claim(key string, ttl time.Duration) (bool, error)
+}
+
+// RedisNonceStore 基于 Redis SETNX 的随机串存储 / RedisNonceStore tracks nonces with Redis SETNX
+type RedisNonceStore struct {
+	Client *aredis.ClientRedis
+}
+
+// Claim 记录随机串 / Claim records the nonce
+func (s RedisNonceStore) Claim(key string, ttl time.Duration) (bool, error) {
+	return s.Client.Universal().SetNX(s.Client.Ctx, key, 1, ttl).Result()
+}
+
+// SignatureConfig 签名校验配置，零值字段读取 signature.* 配置
+// SignatureConfig configures signature verification; zero fields fall back to signature.*
+type SignatureConfig struct {
+	Secrets     SecretStore   // 密钥查询，默认 ConfigSecretStore / Secret lookup, defaults to ConfigSecretStore
+	Nonces      NonceStore    // 随机串存储，默认 signature.redis 指定连接的 RedisNonceStore / Nonce store, defaults to Redis from signature.redis
+	NoncePrefix string        // 随机串键前缀，默认 "signature:nonce:" / Nonce key prefix
+	MaxSkew     time.Duration // 允许的时间偏差，默认 signature.max_skew 或 5m / Allowed clock skew, defaults to signature.max_skew or 5m
+}
+
+// setDefaults 设置默认值 / setDefaults fills in default values
+func (cfg *SignatureConfig) setDefaults() {
+	if cfg.Secrets == nil {
+		cfg.Secrets = ConfigSecretStore{}
+	}
+	if cfg.Nonces == nil {
+		if client := aredis.Client[config.GetString("signature.redis")]; client != nil {
+			cfg.Nonces = RedisNonceStore{Client: client}
+		}
+	}
+	if cfg.NoncePrefix == "" {
+		cfg.NoncePrefix = "signature:nonce:"
+	}
+	if cfg.MaxSkew <= 0 {
+		cfg.MaxSkew = config.GetDuration("signature.max_skew")
+	}
+	if cfg.MaxSkew <= 0 {
+		cfg.MaxSkew = 5 * time.Minute
+	}
+}
+
+// Signature HMAC 请求签名校验中间件，签名方式见 httpx.CanonicalString 与 httpx.Sign。
+// 时间戳（Unix 秒）超出允许偏差或随机串重复时返回 401，验签通过后应用 ID 写入上下文 "app_id"。
+//
+// Signature verifies HMAC-signed requests as described by httpx.CanonicalString and httpx.Sign.
+// Stale timestamps (Unix seconds) and replayed nonces get 401; the verified app ID is stored under "app_id".
+func Signature(cfg ...SignatureConfig) gin.HandlerFunc {
+	var conf SignatureConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	}
+	conf.setDefaults()
+
+	return func(c *gin.Context) {
+		appID := c.GetHeader(httpx.HeaderAppID)
+		timestamp := c.GetHeader(httpx.HeaderTimestamp)
+		nonce := c.GetHeader(httpx.HeaderNonce)
+		signature := c.GetHeader(httpx.HeaderSignature)
+		if appID == "" || timestamp == "" || nonce == "" || signature == "" {
+			abortWithFail(c, http.StatusUnauthorized, "401", i18n.TDefault(c, "signature.missing", "missing signature"))
+			return
+		}
+
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || time.Since(time.Unix(ts, 0)).Abs() > conf.MaxSkew {
+			abortWithFail(c, http.StatusUnauthorized, "401", i18n.TDefault(c, "signature.expired", "signature expired"))
+			return
+		}
+
+		secret, err := conf.Secrets.Secret(appID)
+		if err != nil || secret == "" {
+			if err != nil {
+				alog.Write.Error("Signature secret lookup failed", zap.String("app_id", appID), zap.Error(err))
+			}
+			abortWithFail(c, http.StatusUnauthorized, "401", i18n.TDefault(c, "signature.invalid", "invalid signature"))
+			return
+		}
+
+		body, newRC, err := httpx.ReadBody(c.Request.Body, httpx.CalculateMaxSize(c.Request.ContentLength))
+		if err != nil {
+			var sizeErr httpx.ErrBodySizeExceeded
+			if errors.As(err, &sizeErr) {
+				abortWithFail(c, http.StatusRequestEntityTooLarge, "413", i18n.TDefault(c, "request.body_too_large", "request body too large"), err.Error())
+				return
+			}
+			abortWithFail(c, http.StatusBadRequest, "400", i18n.TDefault(c, "request.body_invalid", "invalid request body"), err.Error())
+			return
+		}
+		c.Request.Body = newRC
+
+		expected := httpx.Sign(secret, c.Request.Method, c.Request.URL.Path, c.Request.URL.Query(), body, timestamp, nonce)
+		if !hmac.Equal([]byte(signature), []byte(expected)) {
+			abortWithFail(c, http.StatusUnauthorized, "401", i18n.TDefault(c, "signature.invalid", "invalid signature"))
+			return
+		}
+
+		// 随机串在时间窗口内唯一，签名通过后才记录，避免伪造请求占用随机串
+		// Nonces are unique within the skew window and only recorded after the signature checks out
+		if conf.Nonces == nil {
+			alog.Write.Error("Signature nonce store not configured")
+			abortWithFail(c, http.StatusInternalServerError, "500", i18n.TDefault(c, "signature.unavailable", "signature verification unavailable"))
+			return
+		}
+		claimed, err := conf.Nonces.Claim(conf.NoncePrefix+appID+":"+nonce, 2*conf.MaxSkew)
+		if err != nil {
+			alog.Write.Error("Signature nonce claim failed", zap.String("app_id", appID), zap.Error(err))
+			abortWithFail(c, http.StatusServiceUnavailable, "503", i18n.TDefault(c, "signature.unavailable", "signature verification unavailable"))
+			return
+		}
+		if !claimed {
+			abortWithFail(c, http.StatusUnauthorized, "401", i18n.TDefault(c, "signature.replayed", "request replayed"))
+			return
+		}
+
+		c.Set(ContextKeyAppID, appID)
+		c.Next()
+	}
+}
@@ -0,0 +1,118 @@
+package agin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/small-ek/antgo/db/aredis"
+	"github.com/small-ek/antgo/net/ahttp"
+	"github.com/small-ek/antgo/net/httpx"
+)
+
+// memoryNonceStore 测试用随机串存储 / memoryNonceStore is an in-memory nonce store for tests
+type memoryNonceStore struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func (s *memoryNonceStore) Claim(key string, _ time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen[key] {
+		return false, nil
+	}
+	s.seen[key] = true
+	return true, nil
+}
+
+// TestSignature 测试签名校验、过期时间戳与重放 / TestSignature covers verification, stale timestamps and replays
+func TestSignature(t *testing.T) {
+	app := gin.New()
+	app.Use(Signature(SignatureConfig{
+		Secrets: SecretStoreFunc(func(appID string) (string, error) {
+			if appID == "partner" {
+				return "secret", nil
+			}
+			return "", nil
+		}),
+		Nonces: &memoryNonceStore{seen: map[string]bool{}},
+	}))
+	app.POST("/orders", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, c.GetString(ContextKeyAppID)+":"+string(body))
+	})
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/orders?b=2&a=1&a=0", strings.NewReader(`{"id":1}`))
+		if err := (ahttp.Signer{AppID: "partner", Secret: "secret"}).SignRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	do := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	req := newRequest()
+	replay := req.Clone(req.Context())
+	replay.Body = io.NopCloser(strings.NewReader(`{"id":1}`))
+	if w := do(req); w.Code != http.StatusOK || w.Body.String() != `partner:{"id":1}` {
+		t.Fatalf("expected 200, got %d %q", w.Code, w.Body.String())
+	}
+	if w := do(replay); w.Code != http.StatusUnauthorized {
+		t.Errorf("replayed nonce: expected 401, got %d", w.Code)
+	}
+
+	tampered := newRequest()
+	tampered.Body = io.NopCloser(strings.NewReader(`{"id":2}`))
+	if w := do(tampered); w.Code != http.StatusUnauthorized {
+		t.Errorf("tampered body: expected 401, got %d", w.Code)
+	}
+
+	stale := newRequest()
+	ts := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	stale.Header.Set(httpx.HeaderTimestamp, ts)
+	stale.Header.Set(httpx.HeaderSignature, httpx.Sign("secret", http.MethodPost, "/orders", stale.URL.Query(), []byte(`{"id":1}`), ts, stale.Header.Get(httpx.HeaderNonce)))
+	if w := do(stale); w.Code != http.StatusUnauthorized {
+		t.Errorf("stale timestamp: expected 401, got %d", w.Code)
+	}
+
+	unknown := newRequest()
+	unknown.Header.Set(httpx.HeaderAppID, "other")
+	if w := do(unknown); w.Code != http.StatusUnauthorized {
+		t.Errorf("unknown app: expected 401, got %d", w.Code)
+	}
+}
+
+// TestSignatureNonceStoreError 测试随机串存储不可用时返回 503 / TestSignatureNonceStoreError answers 503 when the nonce store is down
+func TestSignatureNonceStoreError(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	app := gin.New()
+	app.Use(Signature(SignatureConfig{
+		Secrets: SecretStoreFunc(func(appID string) (string, error) { return "secret", nil }),
+		Nonces:  RedisNonceStore{Client: &aredis.ClientRedis{Clients: client, Ctx: context.Background(), Mode: true}},
+	}))
+	app.POST("/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"id":1}`))
+	if err := (ahttp.Signer{AppID: "partner", Secret: "secret"}).SignRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when the nonce store fails, got %d", w.Code)
+	}
+}
@@ -0,0 +1,227 @@
+package agin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/small-ek/antgo/i18n"
+	"github.com/small-ek/antgo/net/httpx"
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/os/config"
+	"github.com/small-ek/antgo/utils/conv"
+	"github.com/small-ek/antgo/utils/response"
+	"go.uber.org/zap"
+)
+
+// TimeoutRule 路由超时规则，对应配置 timeout.routes / TimeoutRule is a per-route deadline under timeout.routes
+type TimeoutRule struct {
+	Method  string `json:"method"`  // 请求方法，空或 "*" 匹配全部 / HTTP method, empty or "*" matches any
+	Path    string `json:"path"`    // 路径模式，支持 ":id" 与 "*" / Path pattern, supports ":id" and "*"
+	Timeout string `json:"timeout"` // 超时时间，如 "3s" / Deadline such as "3s"
+
+	duration time.Duration
+}
+
+// Timeout 请求超时中间件：为请求上下文设置截止时间，超时后返回 504 且丢弃后续写入。
+// d 为 0 时读取 timeout.default，未传入规则时读取 timeout.routes。
+// 处理函数需将 c.Request.Context() 传递给 gorm（WithContext）与 ahttp 调用以便及时取消。
+//
+// Timeout sets a deadline on the request context, answers 504 once it passes and drops later writes.
+// A zero d reads timeout.default and rules default to timeout.routes.
+// Handlers should pass c.Request.Context() to gorm (WithContext) and ahttp so work is cancelled in time.
+func Timeout(d time.Duration, rules ...TimeoutRule) gin.HandlerFunc {
+	if d <= 0 {
+		d = config.GetDuration("timeout.default")
+	}
+	if len(rules) == 0 {
+		if raw := config.GetMaps("timeout.routes"); len(raw) > 0 {
+			if err := conv.ToStruct(raw, &rules); err != nil {
+				alog.Write.Error("Parse timeout.routes failed", zap.Error(err))
+			}
+		}
+	}
+	// 无法解析的规则直接跳过，使匹配的路由回落到默认超时而不是关闭超时
+	// Unparsable rules are skipped so matching routes fall back to the default instead of having no deadline
+	valid := make([]TimeoutRule, 0, len(rules))
+	for _, rule := range rules {
+		parsed, err := time.ParseDuration(rule.Timeout)
+		if err != nil {
+			alog.Write.Error("Invalid route timeout", zap.String("path", rule.Path), zap.Error(err))
+			continue
+		}
+		rule.duration = parsed
+		valid = append(valid, rule)
+	}
+	rules = valid
+
+	status := config.GetInt("timeout.status")
+	if status != http.StatusServiceUnavailable {
+		status = http.StatusGatewayTimeout
+	}
+
+	return func(c *gin.Context) {
+		timeout := d
+		for _, rule := range rules {
+			if rule.Method != "" && rule.Method != "*" && !strings.EqualFold(rule.Method, c.Request.Method) {
+				continue
+			}
+			if httpx.MatchPath(rule.Path, c.Request.URL.Path) {
+				timeout = rule.duration
+				break
+			}
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		// 预先取出日志字段，避免在另一个协程中读取 c.Request
+		// Capture the log fields up front so c.Request is not read from another goroutine
+		logFields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.Duration("timeout", timeout),
+			zap.String("request_id", getRequestID(c)),
+		}
+
+		tw := &timeoutWriter{ResponseWriter: c.Writer, header: make(http.Header), ctx: ctx}
+		tw.onTimeout = func() {
+			alog.Write.Warn("HTTP request timeout", logFields...)
+			if !tw.ResponseWriter.Written() {
+				tw.writeTimeout(status, i18n.TDefault(c, "request.timeout", "request timeout"))
+			}
+		}
+		c.Writer = tw
+
+		// 截止时间到达时在另一个协程中写出超时响应，写入与处理函数通过锁串行化
+		// When the deadline passes the timeout response is written from another goroutine, serialized by the lock
+		stop := context.AfterFunc(ctx, func() {
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.expiredLocked()
+		})
+
+		c.Next()
+
+		stop()
+		tw.mu.Lock()
+		timedOut := tw.expiredLocked()
+		tw.done = true
+		if !timedOut {
+			tw.syncHeader()
+		}
+		tw.mu.Unlock()
+		c.Writer = tw.ResponseWriter
+		if timedOut {
+			c.Abort()
+		}
+	}
+}
+
+// timeoutWriter 超时后丢弃写入的响应写入器，处理函数使用独立的响应头，写出时才同步
+// timeoutWriter drops writes once the deadline has passed; handlers get a private header map synced on write
+type timeoutWriter struct {
+	gin.ResponseWriter
+	header    http.Header
+	ctx       context.Context
+	onTimeout func() // 超时处理，调用时已持有锁 / Timeout handler, called with the lock held
+	mu        sync.Mutex
+	timedOut  bool
+	done      bool
+}
+
+// expiredLocked 检查截止时间并在首次超时时写出超时响应，调用方需持有锁
+// expiredLocked checks the deadline and writes the timeout response once; the caller holds the lock
+func (w *timeoutWriter) expiredLocked() bool {
+	if !w.timedOut && !w.done && w.ctx.Err() == context.DeadlineExceeded {
+		w.timedOut = true
+		w.onTimeout()
+	}
+	return w.timedOut
+}
+
+// Header 返回处理函数使用的响应头 / Header returns the header map used by handlers
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+// syncHeader 将响应头同步到底层写入器，调用方需持有锁 / syncHeader copies the header to the underlying writer; the caller holds the lock
+func (w *timeoutWriter) syncHeader() {
+	dst := w.ResponseWriter.Header()
+	for k, values := range w.header {
+		dst[k] = values
+	}
+}
+
+// Write 写入响应数据 / Write writes response data
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expiredLocked() {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.syncHeader()
+	return w.ResponseWriter.Write(b)
+}
+
+// WriteString 写入字符串 / WriteString writes a string
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expiredLocked() {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.syncHeader()
+	return w.ResponseWriter.WriteString(s)
+}
+
+// WriteHeader 设置状态码 / WriteHeader sets the status code
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expiredLocked() {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// WriteHeaderNow 立即写出响应头 / WriteHeaderNow writes the header immediately
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expiredLocked() {
+		return
+	}
+	w.syncHeader()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Flush 刷新响应 / Flush flushes the response
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expiredLocked() {
+		return
+	}
+	w.syncHeader()
+	w.ResponseWriter.Flush()
+}
+
+// writeTimeout 写出超时响应，调用方需持有锁 / writeTimeout writes the timeout body; the caller holds the lock
+func (w *timeoutWriter) writeTimeout(status int, msg string) {
+	body, err := json.Marshal(response.Fail(strconv.Itoa(status), msg))
+	if err != nil {
+		return
+	}
+	w.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.ResponseWriter.WriteHeader(status)
+	_, _ = w.ResponseWriter.Write(body)
+}
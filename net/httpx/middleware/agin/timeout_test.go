@@ -0,0 +1,62 @@
+package agin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestTimeout 测试超时响应、路由规则与超时后丢弃写入 / TestTimeout covers the timeout body, route rules and dropped writes
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	app.Use(Timeout(50*time.Millisecond, TimeoutRule{Method: "GET", Path: "/slow/*", Timeout: "300ms"},
+		TimeoutRule{Path: "/ignore", Timeout: "soon"}))
+
+	app.GET("/ctx", func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			c.String(http.StatusOK, "cancelled")
+		case <-time.After(time.Second):
+			c.String(http.StatusOK, "finished")
+		}
+	})
+	app.GET("/ignore", func(c *gin.Context) {
+		time.Sleep(100 * time.Millisecond)
+		c.Header("X-Late", "1")
+		c.String(http.StatusOK, "late")
+	})
+	app.GET("/slow/report", func(c *gin.Context) {
+		time.Sleep(100 * time.Millisecond)
+		c.Header("X-Done", "1")
+		c.String(http.StatusOK, "done")
+	})
+
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// "/ignore" 的规则无法解析，仍使用默认超时 / The "/ignore" rule does not parse, so the default still applies
+	for _, path := range []string{"/ctx", "/ignore"} {
+		w := do(path)
+		if w.Code != http.StatusGatewayTimeout {
+			t.Errorf("%s: expected 504, got %d", path, w.Code)
+		}
+		if w.Header().Get("X-Late") != "" {
+			t.Errorf("%s: headers written after the deadline", path)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["status"] != float64(1) {
+			t.Errorf("%s: expected response.Fail body, got %q", path, w.Body.String())
+		}
+	}
+
+	if w := do("/slow/report"); w.Code != http.StatusOK || w.Body.String() != "done" || w.Header().Get("X-Done") != "1" {
+		t.Errorf("route rule should extend the deadline, got %d %q", w.Code, w.Body.String())
+	}
+}
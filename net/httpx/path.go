@@ -0,0 +1,32 @@
+package httpx
+
+import "strings"
+
+// MatchPath 路径模式匹配，":name" 匹配单段，末尾 "*" 匹配剩余部分
+// MatchPath matches a path pattern; ":name" matches one segment and a trailing "*" matches the rest
+func MatchPath(pattern, path string) bool {
+	if pattern == path {
+		return true
+	}
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, part := range patternParts {
+		if part == "*" || strings.HasPrefix(part, "*") {
+			return i == len(patternParts)-1
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if strings.HasPrefix(part, ":") {
+			if pathParts[i] == "" {
+				return false
+			}
+			continue
+		}
+		if part != pathParts[i] {
+			return false
+		}
+	}
+	return len(patternParts) == len(pathParts)
+}
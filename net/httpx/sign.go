@@ -0,0 +1,77 @@
+package httpx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/small-ek/antgo/crypto/ahash"
+)
+
+// 签名请求头 / Signature request headers
+const (
+	HeaderAppID     = "X-App-Id"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+// CanonicalString 生成待签名字符串，各部分以换行连接：
+// 方法、路径、按键与值排序的查询串、请求体 SHA256（十六进制）、时间戳、随机串。
+//
+// CanonicalString builds the string to sign, joining with newlines:
+// method, path, query sorted by key and value, hex SHA256 of the body, timestamp and nonce.
+func CanonicalString(method, path string, query url.Values, body []byte, timestamp, nonce string) string {
+	if path == "" {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(method))
+	b.WriteByte('\n')
+	b.WriteString(path)
+	b.WriteByte('\n')
+	b.WriteString(canonicalQuery(query))
+	b.WriteByte('\n')
+	b.WriteString(hex.EncodeToString(bodyHash[:]))
+	b.WriteByte('\n')
+	b.WriteString(timestamp)
+	b.WriteByte('\n')
+	b.WriteString(nonce)
+	return b.String()
+}
+
+// Sign 使用 HMAC-SHA256 对请求签名，返回 Base64 签名 / Sign signs the request with HMAC-SHA256 and returns it in Base64
+func Sign(secret, method, path string, query url.Values, body []byte, timestamp, nonce string) string {
+	return ahash.SignSHA256(CanonicalString(method, path, query, body, timestamp, nonce), secret)
+}
+
+// canonicalQuery 按键与值排序并编码查询参数 / canonicalQuery encodes the query sorted by key and value
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(k))
+			b.WriteByte('=')
+			b.WriteString(url.QueryEscape(v))
+		}
+	}
+	return b.String()
+}
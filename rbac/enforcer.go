@@ -0,0 +1,138 @@
+package rbac
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/small-ek/antgo/os/alog"
+	"github.com/small-ek/antgo/utils/conv"
+	"go.uber.org/zap"
+)
+
+// Enforcer 权限校验器，缓存已加载的策略并支持刷新
+// Enforcer checks permissions against a cached policy and supports refreshing
+type Enforcer struct {
+	loader      Loader
+	policy      atomic.Pointer[compiledPolicy]
+	fingerprint []byte
+	mu          sync.Mutex // 保护加载过程 / Guards reloading
+}
+
+var defaultEnforcer atomic.Pointer[Enforcer]
+
+// New 创建校验器并立即加载策略 / New creates an enforcer and loads the policy immediately
+func New(loader Loader) (*Enforcer, error) {
+	e := &Enforcer{loader: loader}
+	if _, err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// SetDefault 设置全局默认校验器 / SetDefault sets the global default enforcer
+func SetDefault(e *Enforcer) {
+	defaultEnforcer.Store(e)
+}
+
+// Default 获取全局默认校验器 / Default returns the global default enforcer
+func Default() *Enforcer {
+	return defaultEnforcer.Load()
+}
+
+// Reload 重新加载策略，策略未变化时不替换缓存，返回是否发生变化
+// Reload reloads the policy, keeping the cache when nothing changed; reports whether it changed
+func (e *Enforcer) Reload() (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	policy, err := e.loader.Load()
+	if err != nil {
+		return false, err
+	}
+	fingerprint, err := conv.ToJSON(policy)
+	if err != nil {
+		return false, err
+	}
+	if e.policy.Load() != nil && bytes.Equal(fingerprint, e.fingerprint) {
+		return false, nil
+	}
+
+	e.policy.Store(compile(policy))
+	e.fingerprint = fingerprint
+	return true, nil
+}
+
+// Watch 按间隔轮询策略源，变化时刷新缓存，返回停止函数
+// Watch polls the policy source at the interval and refreshes on change; returns a stop function
+func (e *Enforcer) Watch(interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				changed, err := e.Reload()
+				if err != nil {
+					alog.Write.Error("RBAC policy reload failed", zap.Error(err))
+					continue
+				}
+				if changed {
+					alog.Write.Info("RBAC policy reloaded")
+				}
+			}
+		}
+	}()
+	return cancel
+}
+
+// IsSuper 是否为超级管理员 / IsSuper reports whether any of the roles bypasses all checks
+func (e *Enforcer) IsSuper(roles []string) bool {
+	cp := e.policy.Load()
+	return cp != nil && cp.isSuper(roles)
+}
+
+// Allow 判断角色集合是否拥有全部权限，超级管理员直接放行
+// Allow reports whether the roles hold all permissions; super roles always pass
+func (e *Enforcer) Allow(roles []string, perms ...string) bool {
+	cp := e.policy.Load()
+	if cp == nil {
+		return false
+	}
+	if cp.isSuper(roles) {
+		return true
+	}
+	for _, perm := range perms {
+		if !cp.allowed(roles, perm) {
+			return false
+		}
+	}
+	return true
+}
+
+// RoutePermission 获取路由所需权限 / RoutePermission returns the permission required by the route
+func (e *Enforcer) RoutePermission(method, path string) (string, bool) {
+	cp := e.policy.Load()
+	if cp == nil {
+		return "", false
+	}
+	return cp.matchRoute(method, path)
+}
+
+// DataScope 获取角色集合的数据范围提示，超级管理员为 ScopeAll
+// DataScope returns the data scope hint of the roles; super roles get ScopeAll
+func (e *Enforcer) DataScope(roles []string) string {
+	cp := e.policy.Load()
+	if cp == nil {
+		return ""
+	}
+	if cp.isSuper(roles) {
+		return ScopeAll
+	}
+	return cp.dataScope(roles)
+}
@@ -0,0 +1,131 @@
+package rbac
+
+import (
+	"errors"
+
+	"github.com/small-ek/antgo/os/config"
+	"github.com/small-ek/antgo/utils/conv"
+	"gorm.io/gorm"
+)
+
+// Loader 策略加载器 / Loader loads the authorization policy from a source
+type Loader interface {
+	Load() (*Policy, error)
+}
+
+// LoaderFunc 函数式加载器 / LoaderFunc adapts a function to the Loader interface
+type LoaderFunc func() (*Policy, error)
+
+// Load 实现 Loader 接口 / Load implements Loader
+func (f LoaderFunc) Load() (*Policy, error) {
+	return f()
+}
+
+// ConfigLoader 从配置文件加载策略，默认读取 "rbac" 节点
+// ConfigLoader loads the policy from the configuration, reading the "rbac" key by default
+type ConfigLoader struct {
+	Key string
+}
+
+// Load 实现 Loader 接口 / Load implements Loader
+func (l ConfigLoader) Load() (*Policy, error) {
+	key := l.Key
+	if key == "" {
+		key = "rbac"
+	}
+	raw := config.Get(key)
+	if raw == nil {
+		return nil, errors.New("rbac: config key not found: " + key)
+	}
+
+	policy := &Policy{}
+	if err := conv.ToStruct(raw, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// 数据库表默认名称 / Default table names
+const (
+	DefaultRoleTable       = "rbac_roles"
+	DefaultPermissionTable = "rbac_role_permissions"
+	DefaultRouteTable      = "rbac_routes"
+)
+
+// RoleRow 角色表行 / RoleRow is a row of the role table
+type RoleRow struct {
+	Name      string `gorm:"column:name"`       // 角色名 / Role name
+	Parent    string `gorm:"column:parent"`     // 父角色，可为空 / Parent role, optional
+	DataScope string `gorm:"column:data_scope"` // 数据范围 / Data scope
+	IsSuper   bool   `gorm:"column:is_super"`   // 是否超级管理员 / Whether it bypasses all checks
+}
+
+// PermissionRow 角色权限表行 / PermissionRow is a row of the role-permission table
+type PermissionRow struct {
+	Role       string `gorm:"column:role"`
+	Permission string `gorm:"column:permission"`
+}
+
+// DBLoader 通过 adb 的 gorm 连接从数据库加载策略
+// DBLoader loads the policy from database tables through an adb gorm connection
+type DBLoader struct {
+	DB              *gorm.DB
+	RoleTable       string
+	PermissionTable string
+	RouteTable      string
+}
+
+// Load 实现 Loader 接口 / Load implements Loader
+func (l DBLoader) Load() (*Policy, error) {
+	if l.DB == nil {
+		return nil, errors.New("rbac: db is nil")
+	}
+	roleTable, permTable, routeTable := l.RoleTable, l.PermissionTable, l.RouteTable
+	if roleTable == "" {
+		roleTable = DefaultRoleTable
+	}
+	if permTable == "" {
+		permTable = DefaultPermissionTable
+	}
+	if routeTable == "" {
+		routeTable = DefaultRouteTable
+	}
+
+	// 固定排序，保证策略指纹只随数据变化 / Stable ordering keeps the fingerprint tied to the data
+	var roles []RoleRow
+	if err := l.DB.Table(roleTable).Order("name, parent").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	var perms []PermissionRow
+	if err := l.DB.Table(permTable).Order("role, permission").Find(&perms).Error; err != nil {
+		return nil, err
+	}
+	// 路由按首个匹配生效，路径降序使具体路径排在 ":id" 与 "*" 之前
+	// Routes match first-wins; descending paths put literal segments ahead of ":id" and "*"
+	var routes []RouteRule
+	if err := l.DB.Table(routeTable).Order("path DESC, method DESC").Find(&routes).Error; err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{
+		Roles:      make(map[string][]string),
+		Inherits:   make(map[string][]string),
+		DataScopes: make(map[string]string),
+		Routes:     routes,
+	}
+	for _, row := range roles {
+		if row.IsSuper {
+			policy.SuperRoles = append(policy.SuperRoles, row.Name)
+		}
+		if row.Parent != "" {
+			policy.Inherits[row.Name] = append(policy.Inherits[row.Name], row.Parent)
+		}
+		if row.DataScope != "" {
+			policy.DataScopes[row.Name] = row.DataScope
+		}
+	}
+	for _, row := range perms {
+		policy.Roles[row.Role] = append(policy.Roles[row.Role], row.Permission)
+	}
+	return policy, nil
+}
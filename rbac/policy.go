@@ -0,0 +1,155 @@
+package rbac
+
+import (
+	"strings"
+
+	"github.com/small-ek/antgo/net/httpx"
+)
+
+// 数据范围常量，范围越大优先级越高 / Data scope constants, wider scopes take precedence
+const (
+	ScopeAll          = "all"            // 全部数据 / All data
+	ScopeDeptAndChild = "dept_and_child" // 本部门及下级 / Own department and children
+	ScopeDept         = "dept"           // 本部门 / Own department
+	ScopeSelf         = "self"           // 仅本人 / Own data only
+)
+
+// scopeRank 数据范围排序 / Rank of the built-in data scopes
+var scopeRank = map[string]int{
+	ScopeSelf:         1,
+	ScopeDept:         2,
+	ScopeDeptAndChild: 3,
+	ScopeAll:          4,
+}
+
+// Policy 权限策略定义 / Policy is the raw authorization policy
+type Policy struct {
+	SuperRoles []string            `json:"super_roles"` // 超级管理员角色 / Roles bypassing all checks
+	Roles      map[string][]string `json:"roles"`       // 角色 -> 权限 / Role -> permissions
+	Inherits   map[string][]string `json:"inherits"`    // 角色 -> 父角色 / Role -> parent roles
+	DataScopes map[string]string   `json:"data_scopes"` // 角色 -> 数据范围 / Role -> data scope
+	Routes     []RouteRule         `json:"routes"`      // 路由权限表 / Route permission table
+}
+
+// RouteRule 路由权限规则 / RouteRule binds a method and path pattern to a permission
+type RouteRule struct {
+	Method     string `json:"method" gorm:"column:method"`         // 请求方法，空或 "*" 匹配全部 / HTTP method, empty or "*" matches any
+	Path       string `json:"path" gorm:"column:path"`             // 路径模式，支持 ":id" 与 "*" / Path pattern, supports ":id" and "*"
+	Permission string `json:"permission" gorm:"column:permission"` // 所需权限 / Required permission
+}
+
+// compiledPolicy 预处理后的策略 / compiledPolicy is the pre-processed policy used for checks
+type compiledPolicy struct {
+	super      map[string]bool
+	exact      map[string]map[string]bool // 角色 -> 精确权限 / Role -> exact permissions
+	wildcard   map[string][]string        // 角色 -> 通配权限前缀 / Role -> wildcard permission prefixes
+	dataScopes map[string]string
+	routes     []RouteRule
+}
+
+// compile 展开继承关系并预处理通配符 / compile expands inheritance and pre-processes wildcards
+func compile(p *Policy) *compiledPolicy {
+	cp := &compiledPolicy{
+		super:      make(map[string]bool, len(p.SuperRoles)),
+		exact:      make(map[string]map[string]bool, len(p.Roles)),
+		wildcard:   make(map[string][]string),
+		dataScopes: make(map[string]string, len(p.DataScopes)),
+		routes:     p.Routes,
+	}
+	for _, role := range p.SuperRoles {
+		cp.super[role] = true
+	}
+	for role, scope := range p.DataScopes {
+		cp.dataScopes[role] = scope
+	}
+
+	roles := make(map[string]bool, len(p.Roles)+len(p.Inherits))
+	for role := range p.Roles {
+		roles[role] = true
+	}
+	for role := range p.Inherits {
+		roles[role] = true
+	}
+
+	for role := range roles {
+		exact := make(map[string]bool)
+		visited := make(map[string]bool)
+		collectPermissions(p, role, visited, func(perm string) {
+			if perm == "*" || strings.HasSuffix(perm, ":*") {
+				cp.wildcard[role] = append(cp.wildcard[role], strings.TrimSuffix(perm, "*"))
+				return
+			}
+			exact[perm] = true
+		})
+		cp.exact[role] = exact
+	}
+	return cp
+}
+
+// collectPermissions 递归收集角色及父角色权限，visited 防止循环继承
+// collectPermissions walks the role and its parents; visited guards against cycles
+func collectPermissions(p *Policy, role string, visited map[string]bool, fn func(perm string)) {
+	if visited[role] {
+		return
+	}
+	visited[role] = true
+	for _, perm := range p.Roles[role] {
+		fn(perm)
+	}
+	for _, parent := range p.Inherits[role] {
+		collectPermissions(p, parent, visited, fn)
+	}
+}
+
+// isSuper 是否包含超级管理员角色 / isSuper reports whether any role is a super role
+func (cp *compiledPolicy) isSuper(roles []string) bool {
+	for _, role := range roles {
+		if cp.super[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed 判断角色集合是否拥有权限 / allowed reports whether any role grants the permission
+func (cp *compiledPolicy) allowed(roles []string, perm string) bool {
+	for _, role := range roles {
+		if cp.exact[role][perm] {
+			return true
+		}
+		for _, prefix := range cp.wildcard[role] {
+			if strings.HasPrefix(perm, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dataScope 返回角色集合中最大的数据范围 / dataScope returns the widest data scope among the roles
+func (cp *compiledPolicy) dataScope(roles []string) string {
+	scope, rank := "", -1
+	for _, role := range roles {
+		s, ok := cp.dataScopes[role]
+		if !ok {
+			continue
+		}
+		if r := scopeRank[s]; r > rank {
+			scope, rank = s, r
+		}
+	}
+	return scope
+}
+
+// matchRoute 查找匹配的路由权限 / matchRoute finds the permission required by the route
+func (cp *compiledPolicy) matchRoute(method, path string) (string, bool) {
+	for _, rule := range cp.routes {
+		if rule.Method != "" && rule.Method != "*" && !strings.EqualFold(rule.Method, method) {
+			continue
+		}
+		if httpx.MatchPath(rule.Path, path) {
+			return rule.Permission, true
+		}
+	}
+	return "", false
+}
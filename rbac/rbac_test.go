@@ -0,0 +1,118 @@
+package rbac
+
+import (
+	"testing"
+)
+
+// testPolicy 测试用策略 / testPolicy is the policy used by the tests
+func testPolicy() *Policy {
+	return &Policy{
+		SuperRoles: []string{"root"},
+		Roles: map[string][]string{
+			"viewer": {"article:read"},
+			"editor": {"article:*"},
+			"ops":    {"user:read"},
+		},
+		Inherits: map[string][]string{
+			"editor": {"viewer"},
+			"lead":   {"editor", "ops"},
+			"viewer": {"lead"}, // 循环继承 / Cyclic inheritance
+		},
+		DataScopes: map[string]string{
+			"viewer": ScopeSelf,
+			"lead":   ScopeDept,
+		},
+		Routes: []RouteRule{
+			{Method: "GET", Path: "/api/articles/:id", Permission: "article:read"},
+			{Method: "*", Path: "/api/admin/*", Permission: "admin:manage"},
+		},
+	}
+}
+
+// TestAllow 测试权限、通配符、继承与超级管理员 / TestAllow covers permissions, wildcards, inheritance and super roles
+func TestAllow(t *testing.T) {
+	e, err := New(LoaderFunc(func() (*Policy, error) { return testPolicy(), nil }))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	cases := []struct {
+		roles []string
+		perm  string
+		want  bool
+	}{
+		{[]string{"viewer"}, "article:read", true},
+		{[]string{"viewer"}, "article:delete", true}, // viewer -> lead -> editor
+		{[]string{"ops"}, "article:read", false},
+		{[]string{"editor"}, "article:delete", true},
+		{[]string{"lead"}, "user:read", true},
+		{[]string{"root"}, "anything", true},
+		{nil, "article:read", false},
+	}
+	for _, tc := range cases {
+		if got := e.Allow(tc.roles, tc.perm); got != tc.want {
+			t.Errorf("Allow(%v, %q) = %v, want %v", tc.roles, tc.perm, got, tc.want)
+		}
+	}
+
+	if scope := e.DataScope([]string{"viewer", "lead"}); scope != ScopeDept {
+		t.Errorf("expected widest scope %q, got %q", ScopeDept, scope)
+	}
+	if scope := e.DataScope([]string{"root"}); scope != ScopeAll {
+		t.Errorf("expected super scope %q, got %q", ScopeAll, scope)
+	}
+}
+
+// TestRoutePermission 测试路由匹配 / TestRoutePermission covers route pattern matching
+func TestRoutePermission(t *testing.T) {
+	e, err := New(LoaderFunc(func() (*Policy, error) { return testPolicy(), nil }))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if perm, ok := e.RoutePermission("GET", "/api/articles/12"); !ok || perm != "article:read" {
+		t.Errorf("unexpected route permission %q %v", perm, ok)
+	}
+	if _, ok := e.RoutePermission("POST", "/api/articles/12"); ok {
+		t.Error("method mismatch should not match")
+	}
+	if perm, ok := e.RoutePermission("DELETE", "/api/admin/users/3"); !ok || perm != "admin:manage" {
+		t.Errorf("unexpected wildcard route permission %q %v", perm, ok)
+	}
+	if _, ok := e.RoutePermission("GET", "/api/articles/12/comments"); ok {
+		t.Error("extra segments should not match")
+	}
+}
+
+// TestReload 测试策略变化检测 / TestReload covers change detection on reload
+func TestReload(t *testing.T) {
+	policy := testPolicy()
+	e, err := New(LoaderFunc(func() (*Policy, error) { return policy, nil }))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if changed, _ := e.Reload(); changed {
+		t.Error("unchanged policy should not be reported as changed")
+	}
+
+	policy = testPolicy()
+	policy.Roles["ops"] = append(policy.Roles["ops"], "article:read")
+	if changed, _ := e.Reload(); !changed {
+		t.Error("changed policy should be reported")
+	}
+	if !e.Allow([]string{"ops"}, "article:read") {
+		t.Error("reloaded policy not applied")
+	}
+}
+
+// TestEnforcerWithoutPolicy 测试未加载策略的校验器拒绝全部请求 / TestEnforcerWithoutPolicy covers an enforcer whose policy was never loaded
+func TestEnforcerWithoutPolicy(t *testing.T) {
+	var e Enforcer
+	if e.Allow([]string{"root"}, "article:read") || e.IsSuper([]string{"root"}) || e.DataScope([]string{"root"}) != "" {
+		t.Error("an enforcer without a policy must deny")
+	}
+	if _, ok := e.RoutePermission("GET", "/api/admin/users"); ok {
+		t.Error("an enforcer without a policy has no routes")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/small-ek/antgo/net/awebsocket"
@@ -18,7 +19,27 @@ var upGrader = (&websocket.Upgrader{
 	},
 })
 
+// hub 全部连接共用的管理器
+var hub = awebsocket.NewClient()
+
+// router 按事件分发消息
+var router = awebsocket.NewRouter(hub)
+
+// chatMessage 聊天消息
+type chatMessage struct {
+	Text string `json:"text"`
+}
+
 func main() {
+	go hub.Run(context.Background())
+	router.Use(awebsocket.Recovery(), awebsocket.Logger())
+	// 客户端发送 {"event":"chat.send","id":"1","data":{"text":"hi"}}，带 id 时收到 {"event":"ack","id":"1",...}
+	router.On("chat.send", awebsocket.Handle(func(c *awebsocket.Context, msg chatMessage) (interface{}, error) {
+		// 转发给房间内的其他连接
+		n, err := hub.EmitRoom("lobby", "chat.message", msg, c.Connection)
+		log.Println(msg.Text)
+		return map[string]int{"delivered": n}, err
+	}))
 	bindAddress := "127.0.0.1:1111"
 	r := gin.Default()
 	r.GET("/ping", ping)
@@ -31,24 +52,15 @@ func ping(c *gin.Context) {
 		websocket *websocket.Conn
 		err       error
 		conn      *awebsocket.Connection
-		data      []byte
 	)
 	// 完成ws协议的握手操作
 	if websocket, err = upGrader.Upgrade(c.Writer, c.Request, nil); err != nil {
 		return
 	}
 	conn = awebsocket.New(websocket, c.ClientIP(), uint64(time.Now().Unix()))
-	for {
-		if data, err = conn.ReadMessage(); err != nil {
-			goto ERR
-		}
-		if err = conn.WriteMessage(data); err != nil {
-			goto ERR
-		}
-		log.Println(string(data))
-	}
-	awebsocket.NewClient().Register <- conn
-
-ERR:
+	// 直接注册，保证加入房间前连接已存在
+	hub.OnRegister(conn)
+	hub.Join("lobby", conn)
+	router.Serve(conn)
 	conn.Close()
 }